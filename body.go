@@ -0,0 +1,234 @@
+package formparser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrBodyTooLarge DecodeBody、DecodeRequest读取的数据超过大小上限
+var ErrBodyTooLarge = errors.New(pkgName + ": Body too large")
+
+// defaultMaxBodyBytes DecodeBody默认的大小上限, 与net/http的ParseForm保持一致
+const defaultMaxBodyBytes = 10 << 20
+
+// DecodeBody 从r中流式读取application/x-www-form-urlencoded数据并解码到dst中, 参见Decode.
+//
+// 数据按"&"(设置WithSemicolonSeparator后还包括";")逐对解析, 不会先将整个body读入内存;
+// 超过WithMaxBodyBytes设置的上限(默认10MB)时返回ErrBodyTooLarge
+func (p *FormParser) DecodeBody(r io.Reader, dst interface{}) error {
+	values, err := readValues(r, p.bodyLimit(), p.maxKeys, p.semicolonSep)
+	if err != nil {
+		return err
+	}
+	return p.Decode(values, dst)
+}
+
+// DecodeRequest 将r的query参数及body中的表单一起解码到dst中, 同名的参数body中的值在前, 与http.Request.Form一致.
+// body按Content-Type解析application/x-www-form-urlencoded或multipart/form-data(忽略其中的文件), 其余类型的body被忽略;
+// body超过WithMaxBodyBytes设置的上限(默认10MB)时返回ErrBodyTooLarge
+func (p *FormParser) DecodeRequest(r *http.Request, dst interface{}) error {
+	values := make(url.Values)
+	if r.Body != nil && r.Body != http.NoBody {
+		var body url.Values
+		var err error
+		mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "application/x-www-form-urlencoded":
+			body, err = readValues(r.Body, p.bodyLimit(), p.maxKeys, p.semicolonSep)
+		case "multipart/form-data":
+			body, err = readMultipart(r.Body, params["boundary"], p.bodyLimit(), p.maxKeys)
+		}
+		if err != nil {
+			return err
+		}
+		for k, vs := range body {
+			values[k] = append(values[k], vs...)
+		}
+	}
+	if r.URL != nil {
+		query, err := readValues(strings.NewReader(r.URL.RawQuery), int64(len(r.URL.RawQuery)), p.maxKeys, p.semicolonSep)
+		if err != nil {
+			return err
+		}
+		for k, vs := range query {
+			values[k] = append(values[k], vs...)
+		}
+	}
+	return p.Decode(values, dst)
+}
+
+// bodyLimit 返回读取body的大小上限
+func (p *FormParser) bodyLimit() int64 {
+	if p.maxBodyBytes > 0 {
+		return p.maxBodyBytes
+	}
+	return defaultMaxBodyBytes
+}
+
+// readMultipart 读取multipart/form-data中的表单字段, 文件被跳过但仍计入大小, 读取超过max字节时返回ErrBodyTooLarge,
+// 字段数超过maxKeys(>0时)时返回ErrDecodeLimit
+func readMultipart(r io.Reader, boundary string, max int64, maxKeys int) (url.Values, error) {
+	if boundary == "" {
+		return nil, fmt.Errorf("%s: Missing boundary of multipart body", pkgName)
+	}
+	lr := &io.LimitedReader{R: r, N: max + 1}
+	mr := multipart.NewReader(lr, boundary)
+	values := make(url.Values)
+	n := 0
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return values, nil
+		}
+		if lr.N <= 0 {
+			return nil, ErrBodyTooLarge
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: Invalid multipart body, %w", pkgName, err)
+		}
+		b, err := io.ReadAll(part)
+		if lr.N <= 0 {
+			return nil, ErrBodyTooLarge
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: Invalid multipart body, %w", pkgName, err)
+		}
+		if name := part.FormName(); name != "" && part.FileName() == "" {
+			if n++; maxKeys > 0 && n > maxKeys {
+				return nil, tooManyKeys(maxKeys)
+			}
+			values.Add(name, string(b))
+		}
+	}
+}
+
+// DecodeQuery 解析原始的query串(不含"?", 如r.URL.RawQuery)并解码到dst中, 分隔符的处理同DecodeBody.
+// 与url.ParseQuery不同, 设置WithSemicolonSeparator后";"按分隔符处理而不是报错
+func (p *FormParser) DecodeQuery(query string, dst interface{}) error {
+	values, err := readValues(strings.NewReader(query), int64(len(query)), p.maxKeys, p.semicolonSep)
+	if err != nil {
+		return err
+	}
+	return p.Decode(values, dst)
+}
+
+// readValues 逐对读取r中的urlencoded数据, semicolon为true时";"同样作为分隔符, 读取超过max字节时返回ErrBodyTooLarge,
+// 参数个数超过maxKeys(>0时)时立即返回ErrDecodeLimit, 不再读取剩余的数据
+func readValues(r io.Reader, max int64, maxKeys int, semicolon bool) (url.Values, error) {
+	lr := &io.LimitedReader{R: r, N: max + 1}
+	br := bufio.NewReader(lr)
+	values := make(url.Values)
+	n := 0
+	add := func(pair []byte) error {
+		added, err := addPair(values, pair)
+		if added {
+			if n++; maxKeys > 0 && n > maxKeys {
+				return tooManyKeys(maxKeys)
+			}
+		}
+		return err
+	}
+	for {
+		pair, err := br.ReadBytes('&')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if lr.N <= 0 {
+			return nil, ErrBodyTooLarge
+		}
+		if semicolon {
+			for _, sub := range bytes.Split(pair, []byte{';'}) {
+				if err := add(sub); err != nil {
+					return nil, err
+				}
+			}
+		} else if err := add(pair); err != nil {
+			return nil, err
+		}
+		if err == io.EOF {
+			return values, nil
+		}
+	}
+}
+
+// addPair 解析形如"k=v&"的一对数据并加入values, 空串直接忽略, added表示是否加入了values
+func addPair(values url.Values, pair []byte) (added bool, err error) {
+	if n := len(pair); n > 0 && pair[n-1] == '&' {
+		pair = pair[:n-1]
+	}
+	if len(pair) == 0 {
+		return false, nil
+	}
+	s := string(pair)
+	k, v := s, ""
+	if i := strings.IndexByte(s, '='); i >= 0 {
+		k, v = s[:i], s[i+1:]
+	}
+	key, err := url.QueryUnescape(k)
+	if err != nil {
+		return false, fmt.Errorf("%s: Invalid key %q in body, %w", pkgName, k, err)
+	}
+	value, err := url.QueryUnescape(v)
+	if err != nil {
+		return false, fmt.Errorf("%s: Invalid value of key %q in body, %w", pkgName, key, err)
+	}
+	values.Add(key, value)
+	return true, nil
+}
+
+// tooManyKeys 读取时参数个数超过maxKeys的错误
+func tooManyKeys(maxKeys int) error {
+	return fmt.Errorf("%w: more than %d parameters", ErrDecodeLimit, maxKeys)
+}
+
+// EncodeBody 按contentType编码v作为HTTP请求的body, 返回body及最终的Content-Type:
+// application/x-www-form-urlencoded(contentType为空时的默认值)、multipart/form-data(未指定boundary时随机生成)
+// 或application/json(同ToFlatJSON). 其余类型返回错误
+func (p *FormParser) EncodeBody(v interface{}, contentType string, opts ...Option) (io.Reader, string, error) {
+	mediaType, params := "application/x-www-form-urlencoded", map[string]string(nil)
+	if contentType != "" {
+		var err error
+		if mediaType, params, err = mime.ParseMediaType(contentType); err != nil {
+			return nil, "", fmt.Errorf("%s: Invalid content type %q, %v", pkgName, contentType, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		p := p.with(opts)
+		if err := p.EncodeTo(v, &WriterSink{w: &buf, first: true, escape: p.queryEscape}); err != nil {
+			return nil, "", err
+		}
+		return &buf, mediaType, nil
+	case "multipart/form-data":
+		w := multipart.NewWriter(&buf)
+		if boundary, ok := params["boundary"]; ok {
+			if err := w.SetBoundary(boundary); err != nil {
+				return nil, "", fmt.Errorf("%s: Invalid boundary %q, %v", pkgName, boundary, err)
+			}
+		}
+		if err := p.EncodeTo(v, MultipartSink{W: w}, opts...); err != nil {
+			return nil, "", err
+		}
+		if err := w.Close(); err != nil {
+			return nil, "", err
+		}
+		return &buf, w.FormDataContentType(), nil
+	case "application/json":
+		b, err := p.ToFlatJSON(v, opts...)
+		if err != nil {
+			return nil, "", err
+		}
+		return bytes.NewReader(b), mime.FormatMediaType(mediaType, params), nil
+	}
+	return nil, "", fmt.Errorf("%s: Unsupported content type %q", pkgName, mediaType)
+}
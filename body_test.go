@@ -0,0 +1,254 @@
+package formparser
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBody(t *testing.T) {
+	p := New("a", "-")
+	src := newDecodeDemo()
+	m, err := p.ToMap(reflect.ValueOf(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(url.Values, len(m))
+	for k, v := range m {
+		values.Set(k, v)
+	}
+	var dst decodeDemo
+	if err := p.DecodeBody(strings.NewReader(values.Encode()), &dst); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(src, dst) {
+		t.Fatalf("Got %+v, want %+v", dst, src)
+	}
+}
+
+func TestReadValues(t *testing.T) {
+	values, err := readValues(strings.NewReader("a=1&&b=x+y%21&a=2&c&d="), 64, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{"a": {"1", "2"}, "b": {"x y!"}, "c": {""}, "d": {""}}
+	if !reflect.DeepEqual(map[string][]string(values), want) {
+		t.Fatalf("Got %v, want %v", values, want)
+	}
+
+	if _, err := readValues(strings.NewReader("a=1&b=2"), 7, 0, false); err != nil {
+		t.Fatalf("Unexpected error at the limit, %v", err)
+	}
+	if _, err := readValues(strings.NewReader("a=1&b=23"), 7, 0, false); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("Got %v, want ErrBodyTooLarge", err)
+	}
+	if _, err := readValues(strings.NewReader("a=%zz"), 64, 0, false); err == nil {
+		t.Fatal("Expect error for invalid escape")
+	}
+
+	// 参数个数超过上限时立即停止, 不读取剩余的数据
+	r := &countingReader{r: strings.NewReader(strings.Repeat("x=1&", 1<<16))}
+	if _, err := readValues(r, 1<<20, 3, false); !errors.Is(err, ErrDecodeLimit) {
+		t.Fatalf("Got %v, want ErrDecodeLimit", err)
+	}
+	if r.n >= 1<<16 {
+		t.Fatalf("Read %d bytes, want to stop soon after the limit", r.n)
+	}
+}
+
+// countingReader 记录已读取的字节数
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += n
+	return n, err
+}
+
+func TestSemicolonSeparator(t *testing.T) {
+	values, err := readValues(strings.NewReader("a=1;b=2&a=3;;c"), 64, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{"a": {"1", "3"}, "b": {"2"}, "c": {""}}
+	if !reflect.DeepEqual(map[string][]string(values), want) {
+		t.Fatalf("Got %v, want %v", values, want)
+	}
+	if values, err = readValues(strings.NewReader("a=1;b=2"), 64, 0, false); err != nil || values.Get("a") != "1;b=2" {
+		t.Fatalf("Got %v, %v", values, err)
+	}
+
+	type Demo struct {
+		A string `a:"a"`
+		B []int  `a:"b"`
+	}
+	var dst Demo
+	p := New("a", "-", WithSemicolonSeparator(true))
+	if err := p.DecodeQuery("a=x+y;b.0=1;b.1=2", &dst); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dst, Demo{A: "x y", B: []int{1, 2}}) {
+		t.Fatalf("Got %+v", dst)
+	}
+	dst = Demo{}
+	if err := p.DecodeBody(strings.NewReader("b=3;b=4"), &dst); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dst, Demo{B: []int{3, 4}}) {
+		t.Fatalf("Got %+v", dst)
+	}
+}
+
+func TestDecodeBodyTooLarge(t *testing.T) {
+	body := io.MultiReader(strings.NewReader("b="), strings.NewReader(strings.Repeat("x", defaultMaxBodyBytes)))
+	var dst decodeDemo
+	if err := New("a", "-").DecodeBody(body, &dst); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("Got %v, want ErrBodyTooLarge", err)
+	}
+}
+
+func TestEncodeBody(t *testing.T) {
+	type Demo struct {
+		B string `a:"b"`
+		A []int  `a:"a"`
+	}
+	v := Demo{B: "x y", A: []int{1, 2}}
+	p := New("a", "-")
+	read := func(r io.Reader) string {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	for _, ct := range []string{"", "application/x-www-form-urlencoded"} {
+		body, gotCT, err := p.EncodeBody(v, ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := read(body); got != "b=x+y&a.0=1&a.1=2" || gotCT != "application/x-www-form-urlencoded" {
+			t.Fatalf("Got %s (%s)", got, gotCT)
+		}
+	}
+
+	body, gotCT, err := p.EncodeBody(v, "application/json; charset=utf-8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := read(body); got != `{"a.0":"1","a.1":"2","b":"x y"}` || gotCT != "application/json; charset=utf-8" {
+		t.Fatalf("Got %s (%s)", got, gotCT)
+	}
+
+	body, gotCT, err = p.EncodeBody(v, "multipart/form-data; boundary=xyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotCT != "multipart/form-data; boundary=xyz" {
+		t.Fatalf("Got content type %s", gotCT)
+	}
+	form, err := multipart.NewReader(body, "xyz").ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (map[string][]string{"b": {"x y"}, "a.0": {"1"}, "a.1": {"2"}}); !reflect.DeepEqual(form.Value, want) {
+		t.Fatalf("Got %v, want %v", form.Value, want)
+	}
+	if _, gotCT, err = p.EncodeBody(v, "multipart/form-data"); err != nil || !strings.HasPrefix(gotCT, "multipart/form-data; boundary=") {
+		t.Fatalf("Got %s, %v", gotCT, err)
+	}
+
+	for _, ct := range []string{"text/plain", "multipart/form-data; boundary=\"\"", ";;"} {
+		if _, _, err := p.EncodeBody(v, ct); err == nil {
+			t.Fatalf("Expect error for %s", ct)
+		}
+	}
+	if _, _, err := p.EncodeBody(1, ""); err == nil {
+		t.Fatal("Expect error for invalid param")
+	}
+}
+
+func TestDecodeRequest(t *testing.T) {
+	type Demo struct {
+		A string `a:"a"`
+		B []int  `a:"b"`
+		C string `a:"c"`
+	}
+	p := New("a", "-")
+
+	r := httptest.NewRequest("POST", "/x?a=query&b=3", strings.NewReader("a=body&b=1&b=2"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var dst Demo
+	if err := p.DecodeRequest(r, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Demo{A: "body", B: []int{1, 2, 3}}); !reflect.DeepEqual(dst, want) {
+		t.Fatalf("Got %+v, want %+v", dst, want)
+	}
+
+	var buf strings.Builder
+	w := multipart.NewWriter(&buf)
+	w.WriteField("a", "part")
+	fw, _ := w.CreateFormFile("c", "c.txt")
+	fw.Write([]byte("file content"))
+	w.Close()
+	r = httptest.NewRequest("POST", "/x?c=query", strings.NewReader(buf.String()))
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	dst = Demo{}
+	if err := p.DecodeRequest(r, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Demo{A: "part", C: "query"}); !reflect.DeepEqual(dst, want) {
+		t.Fatalf("Got %+v, want %+v", dst, want)
+	}
+
+	// 其余类型的body被忽略
+	r = httptest.NewRequest("POST", "/x?a=1", strings.NewReader(`{"a":"json"}`))
+	r.Header.Set("Content-Type", "application/json")
+	dst = Demo{}
+	if err := p.DecodeRequest(r, &dst); err != nil || dst.A != "1" {
+		t.Fatalf("Got %+v, %v", dst, err)
+	}
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	type Demo struct {
+		A string `a:"a"`
+	}
+	p := New("a", "-", WithMaxBodyBytes(8))
+	if err := p.DecodeBody(strings.NewReader("a=123456"), &Demo{}); err != nil {
+		t.Fatalf("Unexpected error at the limit, %v", err)
+	}
+	if err := p.DecodeBody(strings.NewReader("a=1234567"), &Demo{}); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("Got %v, want ErrBodyTooLarge", err)
+	}
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader("a=1234567"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := p.DecodeRequest(r, &Demo{}); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("Got %v, want ErrBodyTooLarge", err)
+	}
+
+	var buf strings.Builder
+	w := multipart.NewWriter(&buf)
+	w.WriteField("a", "1")
+	w.Close()
+	r = httptest.NewRequest("POST", "/", strings.NewReader(buf.String()))
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	if err := p.DecodeRequest(r, &Demo{}); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("Got %v, want ErrBodyTooLarge", err)
+	}
+	r = httptest.NewRequest("POST", "/", strings.NewReader(buf.String()))
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	if err := New("a", "-").DecodeRequest(r, &Demo{}); err != nil {
+		t.Fatal(err)
+	}
+}
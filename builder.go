@@ -0,0 +1,91 @@
+package formparser
+
+import (
+	"net/url"
+)
+
+// Builder 以链式调用组合struct编码得到的参数与手动设置的参数, 如
+//
+//	values, err := p.NewBuilder().AddStruct(req).Add("Action", "Describe").AddIf(dryRun, "DryRun", "true").Sign("Signature", signer).Values()
+//
+// 任一步骤出错后其余步骤不再生效, 错误由KVs、Values返回
+type Builder struct {
+	p    *FormParser
+	opts []Option
+	kvs  []KV
+	err  error
+}
+
+// NewBuilder 创建Builder, opts作用于其中所有的AddStruct
+func (p *FormParser) NewBuilder(opts ...Option) *Builder {
+	return &Builder{p: p, opts: opts}
+}
+
+// AddStruct 追加v编码得到的KV, v的要求同Encode
+func (b *Builder) AddStruct(v interface{}) *Builder {
+	if b.err != nil {
+		return b
+	}
+	kvs, err := b.p.Encode(v, b.opts...)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	b.kvs = append(b.kvs, kvs...)
+	return b
+}
+
+// Add 追加一个KV, 不做任何转义或校验
+func (b *Builder) Add(key, value string) *Builder {
+	if b.err == nil {
+		b.kvs = append(b.kvs, KV{key, value})
+	}
+	return b
+}
+
+// AddIf cond为true时才追加该KV
+func (b *Builder) AddIf(cond bool, key, value string) *Builder {
+	if cond {
+		b.Add(key, value)
+	}
+	return b
+}
+
+// Sign 以当前所有KV按key排序后计算签名, 并以key追加签名. 之后再追加的KV不参与签名
+func (b *Builder) Sign(key string, signer Signer) *Builder {
+	if b.err != nil {
+		return b
+	}
+	sig, err := sign(b.kvs, signer)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	b.kvs = append(b.kvs, KV{key, sig})
+	return b
+}
+
+// Err 返回第一个出错步骤的错误
+func (b *Builder) Err() error {
+	return b.err
+}
+
+// KVs 按追加的顺序返回所有KV
+func (b *Builder) KVs() ([]KV, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return append([]KV(nil), b.kvs...), nil
+}
+
+// Values 以url.Values返回所有KV, 重复的key保留所有值
+func (b *Builder) Values() (url.Values, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	values := make(url.Values, len(b.kvs))
+	for _, kv := range b.kvs {
+		values.Add(kv.K, kv.V)
+	}
+	return values, nil
+}
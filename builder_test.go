@@ -0,0 +1,64 @@
+package formparser
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+type failSigner struct{}
+
+func (failSigner) Sign(kvs []KV) (string, error) {
+	return "", errors.New("sign failed")
+}
+
+func TestBuilder(t *testing.T) {
+	type Request struct {
+		Region string `a:"region"`
+		IDs    []int  `a:"id"`
+	}
+	p := New("a", "-")
+	b := p.NewBuilder(WithIndexBase(1)).
+		AddStruct(&Request{Region: "hz", IDs: []int{7}}).
+		Add("action", "list").
+		AddIf(false, "dry", "true").
+		AddIf(true, "page", "1").
+		Sign("sig", joinSigner{}).
+		Add("extra", "x")
+	kvs, err := b.KVs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KV{{"region", "hz"}, {"id.1", "7"}, {"action", "list"}, {"page", "1"},
+		{"sig", "action=list;id.1=7;page=1;region=hz"}, {"extra", "x"}}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Got %v, want %v", kvs, want)
+	}
+	values, err := b.Add("page", "2").Values()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := values["page"]; !reflect.DeepEqual(got, []string{"1", "2"}) || len(values) != 6 {
+		t.Fatalf("Got %v", values)
+	}
+}
+
+func TestBuilderError(t *testing.T) {
+	p := New("a", "-")
+	b := p.NewBuilder().AddStruct(1).Add("k", "v")
+	if b.Err() == nil {
+		t.Fatal("Expect error for invalid struct")
+	}
+	if _, err := b.Values(); err == nil {
+		t.Fatal("Expect error from Values")
+	}
+	b = p.NewBuilder().Add("k", "v").Sign("sig", failSigner{}).Add("k2", "v2")
+	if _, err := b.KVs(); err == nil || err.Error() != "sign failed" {
+		t.Fatalf("Got %v, want sign error", err)
+	}
+	var values url.Values
+	if values, _ = p.NewBuilder().Values(); values == nil || len(values) != 0 {
+		t.Fatalf("Got %v, want empty values", values)
+	}
+}
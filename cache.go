@@ -0,0 +1,83 @@
+package formparser
+
+import (
+	"reflect"
+	"sync"
+)
+
+// EncodeCache 按指针缓存编码结果, 通过WithEncodeCache启用, 适用于反复提交且不会修改的参数struct(如鉴权信息、固定的过滤条件).
+// 仅缓存以非nil指针传入的顶层对象, 指针所指的值被修改后须调用Invalidate, 否则仍返回旧的结果.
+// 缓存会持有被缓存的对象, 直到Invalidate或Reset; 并发安全.
+// 可由多个解析器共用, 各解析器的结果分开缓存, 不会返回按其它解析器的配置(标签、key风格、转义等)编码的结果
+type EncodeCache struct {
+	m sync.Map
+}
+
+func NewEncodeCache() *EncodeCache {
+	return &EncodeCache{}
+}
+
+// Invalidate 删除v(须为编码时传入的指针)在所有解析器下的缓存
+func (c *EncodeCache) Invalidate(v interface{}) {
+	ptr, ok := cacheKey(valueOf(v))
+	if !ok {
+		return
+	}
+	c.m.Range(func(key, _ interface{}) bool {
+		if key.(cacheEntry).ptr == ptr {
+			c.m.Delete(key)
+		}
+		return true
+	})
+}
+
+// Reset 删除所有缓存
+func (c *EncodeCache) Reset() {
+	c.m.Range(func(key, _ interface{}) bool {
+		c.m.Delete(key)
+		return true
+	})
+}
+
+// load 返回rv在scope下的缓存结果的副本, c为nil时总是返回false
+func (c *EncodeCache) load(scope *cacheScope, rv reflect.Value) ([]KV, bool) {
+	if c == nil {
+		return nil, false
+	}
+	ptr, ok := cacheKey(rv)
+	if !ok {
+		return nil, false
+	}
+	kvs, ok := c.m.Load(cacheEntry{scope, ptr})
+	if !ok {
+		return nil, false
+	}
+	return append([]KV(nil), kvs.([]KV)...), true
+}
+
+// store 缓存rv在scope下的编码结果的副本, c为nil时忽略
+func (c *EncodeCache) store(scope *cacheScope, rv reflect.Value, kvs []KV) {
+	if c == nil {
+		return
+	}
+	if ptr, ok := cacheKey(rv); ok {
+		c.m.Store(cacheEntry{scope, ptr}, append([]KV(nil), kvs...))
+	}
+}
+
+// cacheScope 标识通过WithEncodeCache设置了缓存的解析器, 非零大小以保证每次new得到不同的指针
+type cacheScope struct{ _ byte }
+
+// cacheEntry 缓存的key, 同一指针在不同解析器下对应不同的key
+type cacheEntry struct {
+	scope *cacheScope
+	ptr   interface{}
+}
+
+// cacheKey 以指针本身作为key, 相同类型的同一指针对应同一个key
+func cacheKey(rv reflect.Value) (interface{}, bool) {
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || !rv.CanInterface() {
+		return nil, false
+	}
+	return rv.Interface(), true
+}
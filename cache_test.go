@@ -0,0 +1,95 @@
+package formparser
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeCache(t *testing.T) {
+	type Auth struct {
+		Key    string `a:"key"`
+		Secret string `a:"secret"`
+	}
+	cache := NewEncodeCache()
+	p := New("a", "-", WithEncodeCache(cache))
+	auth := &Auth{Key: "k", Secret: "s"}
+	want := []KV{{"key", "k"}, {"secret", "s"}}
+
+	kvs, err := p.Encode(auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Got %v, want %v", kvs, want)
+	}
+	kvs[0].V = "changed"
+
+	// 修改后未Invalidate时返回缓存的结果, 且不受调用方修改返回值的影响
+	auth.Key = "k2"
+	for _, encode := range []func() ([]KV, error){
+		func() ([]KV, error) { return p.Encode(auth) },
+		func() ([]KV, error) { return p.EncodeContext(context.Background(), auth) },
+	} {
+		if kvs, err = encode(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(kvs, want) {
+			t.Fatalf("Got %v, want cached %v", kvs, want)
+		}
+	}
+
+	// 值与带有opts的调用不使用缓存
+	if kvs, _ = p.Encode(*auth); kvs[0].V != "k2" {
+		t.Fatalf("Got %v, want uncached result", kvs)
+	}
+	if kvs, _ = p.Encode(auth, WithRootKey("r")); kvs[0] != (KV{"r.key", "k2"}) {
+		t.Fatalf("Got %v, want uncached result", kvs)
+	}
+
+	cache.Invalidate(auth)
+	if kvs, _ = p.Encode(auth); kvs[0].V != "k2" {
+		t.Fatalf("Got %v after Invalidate", kvs)
+	}
+	auth.Key = "k3"
+	cache.Reset()
+	if m, _ := p.ToMap(reflect.ValueOf(auth)); m["key"] != "k3" {
+		t.Fatalf("Got %v after Reset", m)
+	}
+	if !p.Options().EncodeCache {
+		t.Fatal("Expect EncodeCache in Options")
+	}
+}
+
+func TestEncodeCacheShared(t *testing.T) {
+	type Item struct {
+		Name string `a:"name" b:"title"`
+	}
+	type Demo struct {
+		Item Item `a:"item" b:"item"`
+	}
+	cache := NewEncodeCache()
+	p1 := New("a", "-", WithEncodeCache(cache))
+	p2 := New("b", "-", WithKeyStyle(KeyStyleBracket), WithEncodeCache(cache))
+	v := &Demo{Item{"x"}}
+
+	// 共用同一缓存的解析器各自按自己的配置编码
+	for i := 0; i < 2; i++ {
+		if kvs, _ := p1.Encode(v); !reflect.DeepEqual(kvs, []KV{{"item.name", "x"}}) {
+			t.Fatalf("Got %v from p1", kvs)
+		}
+		if kvs, _ := p2.Encode(v); !reflect.DeepEqual(kvs, []KV{{"item[title]", "x"}}) {
+			t.Fatalf("Got %v from p2", kvs)
+		}
+	}
+
+	// Invalidate对所有解析器生效
+	v.Item.Name = "y"
+	cache.Invalidate(v)
+	if kvs, _ := p1.Encode(v); kvs[0].V != "y" {
+		t.Fatalf("Got %v from p1 after Invalidate", kvs)
+	}
+	if kvs, _ := p2.Encode(v); kvs[0].V != "y" {
+		t.Fatalf("Got %v from p2 after Invalidate", kvs)
+	}
+}
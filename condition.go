@@ -0,0 +1,65 @@
+package formparser
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FieldPredicate 决定parent中的字段field是否参与编码, 返回false时忽略该字段
+type FieldPredicate func(parent reflect.Value, field reflect.StructField) bool
+
+// included 判断parent的第i个字段是否满足omitunless选项及WithFieldPredicate设置的条件.
+// omitunless=Field=value表示仅当同级字段Field(标签名或字段名)编码后等于value时才输出,
+// 多个候选值以"|"分隔, 如`zwf:"level,omitunless=type=advanced|expert"`
+func (p *FormParser) included(parent reflect.Value, i int, opts tagOptions) (bool, error) {
+	if p.fieldPredicate != nil && !p.fieldPredicate(parent, parent.Type().Field(i)) {
+		return false, nil
+	}
+	cond, ok := opts.Get("omitunless")
+	if !ok {
+		return true, nil
+	}
+	j := strings.IndexByte(cond, '=')
+	if j <= 0 {
+		return false, fmt.Errorf("%s: Invalid omitunless option %q, Field=value is needed", pkgName, cond)
+	}
+	name, want := cond[:j], cond[j+1:]
+	sibling, ok := p.sibling(parent, name)
+	if !ok {
+		return false, fmt.Errorf("%s: Field %q referenced by omitunless is not found in %v", pkgName, name, parent.Type())
+	}
+	for sibling.Kind() == reflect.Ptr || sibling.Kind() == reflect.Interface {
+		sibling = sibling.Elem()
+	}
+	if !sibling.IsValid() {
+		return false, nil
+	}
+	kvs, err := p.encode(sibling, name, "")
+	if err != nil {
+		return false, err
+	}
+	if len(kvs) != 1 {
+		return false, nil
+	}
+	for _, v := range strings.Split(want, "|") {
+		if kvs[0].V == v {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// sibling 按标签名查找parent中的字段, 找不到时按字段名查找
+func (p *FormParser) sibling(parent reflect.Value, name string) (reflect.Value, bool) {
+	t := parent.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if tagK, _, drop := p.fieldTag(t.Field(i)); !drop && tagK == name {
+			return parent.Field(i), true
+		}
+	}
+	if _, ok := t.FieldByName(name); ok {
+		return parent.FieldByName(name), true
+	}
+	return reflect.Value{}, false
+}
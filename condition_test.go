@@ -0,0 +1,57 @@
+package formparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOmitUnless(t *testing.T) {
+	type Demo struct {
+		Type  string `a:"type"`
+		Level *int   `a:"level,omitunless=type=advanced|expert"`
+		Debug bool   `a:"debug,omitunless=Type=expert"`
+	}
+	cases := []struct {
+		v    Demo
+		want []KV
+	}{
+		{Demo{Type: "basic", Level: IntPtr(1), Debug: true}, []KV{{"type", "basic"}}},
+		{Demo{Type: "advanced", Level: IntPtr(1), Debug: true}, []KV{{"type", "advanced"}, {"level", "1"}}},
+		{Demo{Type: "expert", Level: IntPtr(2), Debug: true}, []KV{{"type", "expert"}, {"level", "2"}, {"debug", "true"}}},
+	}
+	p := New("a", "-")
+	for _, c := range cases {
+		got, err := p.Encode(c.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("Got %v, want %v", got, c.want)
+		}
+	}
+
+	type Bad struct {
+		A string `a:"a,omitunless=missing=1"`
+		B string `a:"b,omitunless=x"`
+	}
+	if _, err := p.Encode(Bad{}); err == nil {
+		t.Fatal("Expect error for unknown sibling")
+	}
+}
+
+func TestFieldPredicate(t *testing.T) {
+	type Demo struct {
+		A string `a:"a"`
+		B string `a:"b" scope:"internal"`
+	}
+	p := New("a", "-", WithFieldPredicate(func(parent reflect.Value, f reflect.StructField) bool {
+		return f.Tag.Get("scope") != "internal"
+	}))
+	got, err := p.Encode(Demo{A: "1", B: "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []KV{{"a", "1"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+}
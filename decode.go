@@ -0,0 +1,748 @@
+package formparser
+
+import (
+	"database/sql"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	scannerType           = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// Decode 将表单数据解码到dst中, dst须为非nil的*struct, 是ToMap的逆过程.
+//
+// key的拼接风格默认自动识别: 同时支持"a.b.0.c"与"a[b][0][c]"两种写法, 也可通过WithDecodeKeyStyle明确指定.
+// slice字段既可以来自带下标的key("e.0=1&e.1=2"), 也可以来自重复出现的同一个key("e=1&e=2"或PHP风格的"e[]=1&e[]=2");
+// 带下标时按下标从小到大排列, 下标从WithIndexBase设置的值开始, 不连续的下标依次紧凑排列.
+// 例如设置WithIndexBase(1)后即可解析AWS风格的"Filter.1.Name=a&Filter.1.Value.1=x".
+// 带有"repeat"选项(或由WithRepeatedKeys默认开启)的slice只接受重复的key, 带下标的key被忽略.
+// 缺失的参数可以通过选项"default"指定默认值, 如`zwf:"page,default=1"`, 默认值中不能含有英文逗号;
+// 带有"required"选项且没有默认值的参数缺失时, 解码完成后返回列出所有缺失参数的*MissingError.
+// 除alias选项的别名外, 还可以通过选项"accept"声明只用于解码的别名, 便于参数改名期间同时接受新旧两种写法, 如`zwf:"page_size,accept=pageSize"`.
+// 标签为"..."(或inline选项)的字段与编码时一致, 从父辈所在的一层取值: struct取同一层的key, slice取其中的下标, map取其余字段未使用的key.
+// map字段的key集合由输入决定, 如"i.m1=a&i.m2=b"解码为map[string]*string{"m1": "a", "m2": "b"}, 值可以是嵌套的map、struct或slice.
+// time.Time字段可通过选项"layouts"声明接受的多种格式, 以"|"分隔并依次尝试, 如`zwf:"t,layouts=RFC3339|2006-01-02"`
+func (p *FormParser) Decode(values url.Values, dst interface{}) (err error) {
+	defer p.recoverPanic(&err)
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("Param dst is invalid, non-nil *struct is needed")
+	}
+	if err := p.checkLimits(values); err != nil {
+		return err
+	}
+	cp := *p
+	cp.missing = new([]string)
+	if err := cp.decodeStruct(cp.buildTree(values), rv.Elem(), ""); err != nil {
+		return err
+	}
+	if len(*cp.missing) > 0 {
+		return &MissingError{Keys: *cp.missing}
+	}
+	return nil
+}
+
+// DecodeMap 将ToMap产生的map解码到dst中, 参见Decode
+func (p *FormParser) DecodeMap(m map[string]string, dst interface{}) error {
+	values := make(url.Values, len(m))
+	for k, v := range m {
+		values[k] = []string{v}
+	}
+	return p.Decode(values, dst)
+}
+
+// formNode 表单数据按key的路径拆分后组织成的树
+type formNode struct {
+	values   []string
+	children map[string]*formNode
+}
+
+func (n *formNode) child(seg string) *formNode {
+	if n.children == nil {
+		n.children = make(map[string]*formNode)
+	}
+	c, ok := n.children[seg]
+	if !ok {
+		c = &formNode{}
+		n.children[seg] = c
+	}
+	return c
+}
+
+// value 返回节点的第一个值, 与url.Values.Get一致
+func (n *formNode) value() string {
+	if len(n.values) == 0 {
+		return ""
+	}
+	return n.values[0]
+}
+
+// toInterface 将节点转换为interface{}: 叶子节点为string(重复出现时为[]interface{}), 其余为map[string]interface{}
+func (n *formNode) toInterface() interface{} {
+	if len(n.children) == 0 {
+		if len(n.values) == 1 {
+			return n.values[0]
+		}
+		l := make([]interface{}, len(n.values))
+		for i, v := range n.values {
+			l[i] = v
+		}
+		return l
+	}
+	m := make(map[string]interface{}, len(n.children))
+	for k, c := range n.children {
+		m[k] = c.toInterface()
+	}
+	return m
+}
+
+// ErrDecodeLimit 解码的输入超过了WithMaxKeys、WithMaxKeyDepth、WithMaxIndex设置的上限
+var ErrDecodeLimit = errors.New(pkgName + ": Decode limit exceeded")
+
+// checkLimits 在构建树之前检查参数的个数及key的层数. DecodeBody等读取时已按WithMaxKeys提前终止, 这里用于直接传入的values
+func (p *FormParser) checkLimits(values url.Values) error {
+	if p.maxKeys > 0 {
+		n := 0
+		for _, vs := range values {
+			n += len(vs)
+		}
+		if n > p.maxKeys {
+			return fmt.Errorf("%w: %d parameters, more than %d", ErrDecodeLimit, n, p.maxKeys)
+		}
+	}
+	if p.maxKeyDepth <= 0 {
+		return nil
+	}
+	for k := range values {
+		if n := len(p.splitKey(k)); n > p.maxKeyDepth {
+			return fmt.Errorf("%w: key %q has %d segments, more than %d", ErrDecodeLimit, k, n, p.maxKeyDepth)
+		}
+	}
+	return nil
+}
+
+// buildTree 按key的路径将表单数据组织成树
+func (p *FormParser) buildTree(values url.Values) *formNode {
+	root := &formNode{}
+	for k, vs := range values {
+		segs := p.splitKey(k)
+		// PHP风格的"tags[]=a&tags[]=b"等同于重复出现的"tags"
+		if p.decodeKeyStyle != KeyStyleDotted && len(segs) > 1 && strings.HasSuffix(k, "[]") {
+			segs = segs[:len(segs)-1]
+		}
+		n := root
+		for _, seg := range segs {
+			n = n.child(seg)
+		}
+		n.values = append(n.values, vs...)
+	}
+	return root
+}
+
+// splitKey 按WithDecodeKeyStyle设置的风格将key拆分为路径, 如"a.b[0].c"在自动识别时拆分为a、b、0、c
+func (p *FormParser) splitKey(key string) []string {
+	return splitKeyStyle(key, p.decodeKeyStyle)
+}
+
+// splitKeyStyle 按style将key拆分为路径, 参见splitKey
+func splitKeyStyle(key string, style KeyStyle) []string {
+	dots := style != KeyStyleBracket
+	brackets := style != KeyStyleDotted
+
+	var segs []string
+	start := 0
+	for i := 0; i < len(key); i++ {
+		switch {
+		case dots && key[i] == '.':
+			segs = append(segs, key[start:i])
+			start = i + 1
+		case brackets && key[i] == '[':
+			end := strings.IndexByte(key[i:], ']')
+			if end < 0 { // 未闭合的方括号按普通字符处理
+				i = len(key)
+				break
+			}
+			if i > start || i == 0 {
+				segs = append(segs, key[start:i])
+			}
+			segs = append(segs, key[i+1:i+end])
+			i += end
+			start = i + 1
+			// "a[b].c"中"]"之后的"."只作为分隔符
+			if dots && start < len(key) && key[start] == '.' {
+				i++
+				start++
+			}
+			if start == len(key) {
+				return segs
+			}
+		}
+	}
+	return append(segs, key[start:])
+}
+
+func (p *FormParser) decodeStruct(n *formNode, v reflect.Value, key string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !decodable(sf) {
+			continue
+		}
+		tagK, opts, drop := p.fieldTag(sf)
+		if drop {
+			continue
+		}
+		if p.isInline(tagK) {
+			if err := p.decodeInline(n, v, i, opts, key); err != nil {
+				return wrapFieldError(err, t, sf.Name, key)
+			}
+			continue
+		}
+		// 主名优先, 其次按顺序尝试别名
+		name := tagK
+		child, ok := p.lookupChild(n, tagK)
+		for _, alias := range opts.decodeAliases() {
+			if ok {
+				break
+			}
+			tagK = alias
+			child, ok = p.lookupChild(n, alias)
+		}
+		if !ok {
+			// 缺失的参数按default选项解码, 非指针的struct继续应用其字段的default选项
+			def, hasDef := opts.Get("default")
+			switch {
+			case opts.Contains("required") && !hasDef:
+				*p.missing = append(*p.missing, p.joinKey(key, name))
+				continue
+			case hasDef:
+				child = &formNode{values: []string{def}}
+			case v.Field(i).Kind() == reflect.Struct:
+				child = &formNode{}
+			default:
+				continue
+			}
+		}
+		fieldK := p.joinKey(key, tagK)
+		if err := p.decodeValue(child, v.Field(i), opts, fieldK); err != nil {
+			return wrapFieldError(err, t, sf.Name, fieldK)
+		}
+	}
+	return nil
+}
+
+// decodable 判断字段能否解码: 未导出的字段无法赋值, 但与编码一致, 嵌入的未导出struct仍展开其导出字段.
+// 嵌入的未导出struct指针为nil时无法分配, 因此不解码
+func decodable(sf reflect.StructField) bool {
+	return sf.PkgPath == "" || sf.Anonymous && sf.Type.Kind() == reflect.Struct
+}
+
+// decodeInline 解码标签为inline关键字的字段: 与编码一致, 其子节点与父辈的字段位于同一层.
+// struct使用同一层的全部子节点, slice只使用其中的下标, map使用同级字段未认领的其余子节点
+func (p *FormParser) decodeInline(n *formNode, v reflect.Value, field int, opts tagOptions, key string) error {
+	fv := v.Field(field)
+	child, childK := p.inlineNode(n, v.Type(), fv.Type(), opts), key
+	for _, alias := range opts.decodeAliases() {
+		if child != nil {
+			break
+		}
+		child, _ = p.lookupChild(n, alias)
+		childK = p.joinKey(key, alias)
+	}
+	if child == nil {
+		return nil
+	}
+	return p.decodeValue(child, fv, opts, childK)
+}
+
+// inlineNode 返回inline字段(类型为ft, 所属struct类型为st)在n中对应的节点, 没有可用的子节点时返回nil
+func (p *FormParser) inlineNode(n *formNode, st, ft reflect.Type, opts tagOptions) *formNode {
+	ptr := false
+	for ft.Kind() == reflect.Ptr {
+		ft, ptr = ft.Elem(), true
+	}
+	var keep func(seg string) bool
+	switch ft.Kind() {
+	case reflect.Struct:
+		// 非指针的struct总是解码, 以便应用其字段的default、required选项; 指针仅在有子节点属于它时才分配
+		if !ptr {
+			return n
+		}
+		keep = func(seg string) bool { return p.claims(ft, seg) }
+	case reflect.Slice, reflect.Array:
+		if _, ok := opts.Get("idxfmt"); ok {
+			return n
+		}
+		keep = isIndexSeg
+	case reflect.Map:
+		keep = func(seg string) bool { return !p.claims(st, seg) }
+	default:
+		return nil
+	}
+
+	rt := &formNode{children: make(map[string]*formNode)}
+	for seg, c := range n.children {
+		if keep(seg) {
+			rt.children[seg] = c
+		}
+	}
+	if len(rt.children) == 0 {
+		return nil
+	}
+	if ft.Kind() == reflect.Struct {
+		return n
+	}
+	return rt
+}
+
+// claims 判断struct类型t的字段(包括inline的struct、slice字段)是否使用名为seg的子节点, inline的map不认领任何子节点
+func (p *FormParser) claims(t reflect.Type, seg string) bool {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !decodable(sf) {
+			continue
+		}
+		tagK, opts, drop := p.fieldTag(sf)
+		if drop {
+			continue
+		}
+		if p.isInline(tagK) {
+			ft := sf.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			switch ft.Kind() {
+			case reflect.Struct:
+				if p.claims(ft, seg) {
+					return true
+				}
+			case reflect.Slice, reflect.Array:
+				if isIndexSeg(seg) {
+					return true
+				}
+			}
+		} else if p.sameKey(tagK, seg) {
+			return true
+		}
+		for _, alias := range opts.decodeAliases() {
+			if p.sameKey(alias, seg) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lookupChild 查找名为name的子节点. 设置WithCaseInsensitiveKeys后, 没有完全相同的子节点时忽略大小写查找,
+// 存在多个大小写不同的写法时取按字节序最小的一个, 如"NAME"优先于"Name"、"name"
+func (p *FormParser) lookupChild(n *formNode, name string) (*formNode, bool) {
+	if c, ok := n.children[name]; ok || !p.foldKeys {
+		return c, ok
+	}
+	best, found := "", false
+	for seg := range n.children {
+		if strings.EqualFold(seg, name) && (!found || seg < best) {
+			best, found = seg, true
+		}
+	}
+	if !found {
+		return nil, false
+	}
+	return n.children[best], true
+}
+
+// sameKey 按WithCaseInsensitiveKeys的设置比较字段的key与输入中的key
+func (p *FormParser) sameKey(tagK, seg string) bool {
+	if p.foldKeys {
+		return strings.EqualFold(tagK, seg)
+	}
+	return tagK == seg
+}
+
+// isIndexSeg 判断key的一段是否为slice下标
+func isIndexSeg(seg string) bool {
+	_, err := strconv.Atoi(seg)
+	return err == nil
+}
+
+func (p *FormParser) decodeValue(n *formNode, v reflect.Value, opts tagOptions, key string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return p.decodeValue(n, v.Elem(), opts, key)
+	}
+	if ok, err := p.decodeUnmarshaler(n, v, opts); ok {
+		if err != nil {
+			return decodeFailed(key, err)
+		}
+		return nil
+	}
+	if ok, err := p.decodeStyle(n, v, opts, key); ok {
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return p.decodeStruct(n, v, key)
+	case reflect.Slice, reflect.Array:
+		return p.decodeSlice(n, v, opts, key)
+	case reflect.Map:
+		return p.decodeMap(n, v, opts, key)
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			v.Set(reflect.ValueOf(n.toInterface()))
+		}
+		return nil
+	}
+	if len(n.values) == 0 {
+		return nil
+	}
+	if v.Kind() == reflect.Bool && p.boolLexicon != nil {
+		b, err := p.boolLexicon.parse(n.value())
+		if err != nil {
+			return decodeFailed(key, err)
+		}
+		v.SetBool(b)
+		return nil
+	}
+	if err := decodeScalar(n.value(), v); err != nil {
+		return decodeFailed(key, err)
+	}
+	return nil
+}
+
+// decodeUnmarshaler 与encodeMarshaler相对应, 对有特殊表示的类型进行解码, ok为false表示需按kind解码
+func (p *FormParser) decodeUnmarshaler(n *formNode, v reflect.Value, opts tagOptions) (ok bool, err error) {
+	if len(n.values) == 0 {
+		return false, nil
+	}
+	s := n.value()
+	if name, has := opts.Get("encoder"); has {
+		if dec, ok := namedDecoders[name]; ok {
+			return true, dec(s, v)
+		}
+	}
+	if f, ok, err := p.lookupFormat(v, opts); ok || err != nil {
+		if err != nil {
+			return true, err
+		}
+		if f.dec == nil {
+			return true, fmt.Errorf("Format %q of type %v does not support decoding", decodeFormatName(opts), v.Type())
+		}
+		return true, f.dec(s, v)
+	}
+	if dec, ok := p.typeDecoders[v.Type()]; ok {
+		return true, dec(s, v)
+	}
+	if layouts, ok := opts.Get("layouts"); ok && v.Type() == timeType {
+		t, err := p.parseTime(s, strings.Split(layouts, "|"), opts)
+		if err == nil {
+			v.Set(reflect.ValueOf(t))
+		}
+		return true, err
+	}
+	if v.Type() == timeType {
+		var t time.Time
+		var err error
+		if p.timeLayout != "" {
+			t, err = p.parseTime(s, []string{p.timeLayout}, opts)
+		} else {
+			t, err = time.Parse(time.RFC3339Nano, s)
+		}
+		if err == nil {
+			v.Set(reflect.ValueOf(t))
+		}
+		return true, err
+	}
+	if i, ok := implements(v, scannerType); ok {
+		return true, i.(sql.Scanner).Scan(s)
+	}
+	if i, ok := implements(v, binaryUnmarshalerType); ok {
+		b, err := p.decodeBytes(s, opts)
+		if err != nil {
+			return true, err
+		}
+		return true, i.(encoding.BinaryUnmarshaler).UnmarshalBinary(b)
+	}
+	return false, nil
+}
+
+// namedLayouts layouts选项中可直接使用的time包的layout常量名
+var namedLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RubyDate":    time.RubyDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"DateTime":    "2006-01-02 15:04:05",
+	"DateOnly":    "2006-01-02",
+	"TimeOnly":    "15:04:05",
+}
+
+// parseTime 依次按layouts选项中的layout解析s, 返回第一个成功的结果.
+// layout可以是time包的常量名(如RFC3339)或者layout本身; 不含时区的layout按tz选项或WithTimeLocation的时区解析, 均未设置时为UTC
+func (p *FormParser) parseTime(s string, layouts []string, opts tagOptions) (time.Time, error) {
+	loc, err := p.timeLocation(opts)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, layout := range layouts {
+		if named, ok := namedLayouts[layout]; ok {
+			layout = named
+		}
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Time %q does not match any of the layouts %q", s, layouts)
+}
+
+func decodeFormatName(opts tagOptions) string {
+	name, _ := opts.Get("format")
+	return name
+}
+
+// indexedNode slice中的一个元素及其下标
+type indexedNode struct {
+	idx  int
+	node *formNode
+}
+
+func (p *FormParser) decodeSlice(n *formNode, v reflect.Value, opts tagOptions, key string) error {
+	// []byte
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 && len(n.children) == 0 {
+		b, err := p.decodeBytes(n.value(), opts)
+		if err != nil {
+			return decodeFailed(key, err)
+		}
+		v.SetBytes(b)
+		return nil
+	}
+
+	// 带有"runes"选项的[]rune、[N]rune
+	if isRunes(v.Type()) && opts.Contains("runes") && len(n.children) == 0 {
+		rs := []rune(n.value())
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(v.Type(), len(rs), len(rs)))
+		} else if len(rs) > v.Len() {
+			return decodeFailed(key, fmt.Errorf("%d runes out of range [0, %d]", len(rs), v.Len()))
+		} else {
+			v.Set(reflect.Zero(v.Type()))
+		}
+		for i, r := range rs {
+			v.Index(i).SetInt(int64(r))
+		}
+		return nil
+	}
+
+	strategy, err := p.sliceStrategy(v.Type(), opts)
+	if err != nil {
+		return err
+	}
+	joined, isJoined := strategy.(sliceJoined)
+	var elems []indexedNode
+	switch {
+	case strategy == SliceRepeated: // 只接受重复出现的key
+		for i, s := range n.values {
+			elems = append(elems, indexedNode{i, &formNode{values: []string{s}}})
+		}
+	case isJoined && len(n.children) == 0 && len(n.values) == 1: // 以SliceJoined的分隔符拼接的值
+		for i, s := range strings.Split(n.values[0], joined.sep) {
+			elems = append(elems, indexedNode{i, &formNode{values: []string{s}}})
+		}
+	case len(n.children) > 0: // 带下标的key
+		var err error
+		if elems, err = p.indexedChildren(n, opts, key); err != nil {
+			return err
+		}
+	case opts.Contains("join") && len(n.values) == 1: // 以英文逗号join的值
+		for i, s := range strings.Split(n.values[0], ",") {
+			elems = append(elems, indexedNode{i, &formNode{values: []string{s}}})
+		}
+	default: // 重复出现的key
+		for i, s := range n.values {
+			elems = append(elems, indexedNode{i, &formNode{values: []string{s}}})
+		}
+	}
+
+	if v.Kind() == reflect.Array {
+		for _, e := range elems {
+			if e.idx >= v.Len() {
+				return decodeFailed(key, fmt.Errorf("index %d out of range [0, %d)", e.idx, v.Len()))
+			}
+			if err := p.decodeValue(e.node, v.Index(e.idx), opts, p.indexKey(key, e.idx, indexFormat{})); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	s := reflect.MakeSlice(v.Type(), len(elems), len(elems))
+	for i, e := range elems {
+		if err := p.decodeValue(e.node, s.Index(i), opts, p.indexKey(key, e.idx, indexFormat{})); err != nil {
+			return err
+		}
+	}
+	v.Set(s)
+	return nil
+}
+
+// decodeMap 与encodeMap相对应, 以子节点的名字作为map的key, key的集合完全由输入决定.
+// key按其类型解码(同字段的值), 已存在的元素在原值的基础上继续解码
+func (p *FormParser) decodeMap(n *formNode, v reflect.Value, opts tagOptions, key string) error {
+	if len(n.children) == 0 {
+		return nil
+	}
+	t := v.Type()
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(t, len(n.children)))
+	}
+	segs := make([]string, 0, len(n.children))
+	for seg := range n.children {
+		segs = append(segs, seg)
+	}
+	sort.Strings(segs)
+
+	for _, seg := range segs {
+		elemK := p.joinKey(key, seg)
+		mk := reflect.New(t.Key()).Elem()
+		if err := p.decodeValue(&formNode{values: []string{seg}}, mk, "", elemK); err != nil {
+			return err
+		}
+		elem := reflect.New(t.Elem()).Elem()
+		if old := v.MapIndex(mk); old.IsValid() {
+			elem.Set(old)
+		}
+		if err := p.decodeValue(n.children[seg], elem, opts, elemK); err != nil {
+			return err
+		}
+		v.SetMapIndex(mk, elem)
+	}
+	return nil
+}
+
+// indexedChildren 按下标从小到大返回slice的各个元素, 下标的写法与编码时的index_pad、idxfmt选项一致
+func (p *FormParser) indexedChildren(n *formNode, opts tagOptions, key string) ([]indexedNode, error) {
+	f, err := parseIndexFormat(opts)
+	if err != nil {
+		return nil, err
+	}
+	literals, prefix, suffix, err := p.indexPattern(f)
+	if err != nil {
+		return nil, err
+	}
+	for _, seg := range literals {
+		if n = n.children[seg]; n == nil {
+			return nil, nil
+		}
+	}
+
+	elems := make([]indexedNode, 0, len(n.children))
+	for seg, c := range n.children {
+		digits := strings.TrimSuffix(strings.TrimPrefix(seg, prefix), suffix)
+		idx, err := strconv.Atoi(digits)
+		if err != nil || len(digits) != len(seg)-len(prefix)-len(suffix) {
+			return nil, decodeFailed(key, fmt.Errorf("invalid index %q", seg))
+		}
+		if idx -= p.indexBase; idx < 0 {
+			return nil, decodeFailed(key, fmt.Errorf("index %q is less than the base %d", seg, p.indexBase))
+		}
+		if p.maxIndex > 0 && idx > p.maxIndex {
+			return nil, fmt.Errorf("%w: index %q of key %q is greater than %d", ErrDecodeLimit, seg, key, p.maxIndex)
+		}
+		elems = append(elems, indexedNode{idx, c})
+	}
+	sort.Slice(elems, func(i, j int) bool { return elems[i].idx < elems[j].idx })
+	return elems, nil
+}
+
+// indexPattern 根据idxfmt模板推导解码时下标所在的路径: 下标之前的固定路径, 以及下标所在段的前后缀.
+// 例如".member.%d"对应固定路径["member"], "[%d]"没有固定路径, ".item%d"对应前缀"item"
+func (p *FormParser) indexPattern(f indexFormat) (literals []string, prefix, suffix string, err error) {
+	if f.layout == "" {
+		return nil, "", "", nil
+	}
+	const probe = "1234567"
+	segs := p.splitKey("_" + fmt.Sprintf(f.layout, 1234567))
+	last := segs[len(segs)-1]
+	i := strings.Index(last, probe)
+	if i < 0 {
+		return nil, "", "", fmt.Errorf("%s: idxfmt %q can not be decoded", pkgName, f.layout)
+	}
+	return segs[1 : len(segs)-1], last[:i], last[i+len(probe):], nil
+}
+
+// decodeBytes 按WithBytesFormat设置的方式将字符串解码为[]byte, 带有"raw"选项时直接取字符串的字节
+func (p *FormParser) decodeBytes(s string, opts tagOptions) ([]byte, error) {
+	if opts.Contains("raw") {
+		return []byte(s), nil
+	}
+	switch p.bytesFormat {
+	case BytesBase64URL:
+		return base64.URLEncoding.DecodeString(s)
+	case BytesHex:
+		return hex.DecodeString(s)
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// decodeScalar 将字符串解码到基础类型的值v中
+func decodeScalar(s string, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Complex64, reflect.Complex128:
+		c, err := strconv.ParseComplex(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetComplex(c)
+	default:
+		return fmt.Errorf("Unsupported type %v", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,903 @@
+package formparser
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type decodeDemo struct {
+	A  int         `a:"a"`
+	B  string      `a:"b"`
+	C  *int64      `a:"c"`
+	D  float64     `a:"d"`
+	E  []int       `a:"e"`
+	F  Info        `a:"f"`
+	G  bool        `a:"g"`
+	H  []*Info     `a:"h"`
+	J  []byte      `a:"j"`
+	L  []string    `a:"l,join"`
+	T  time.Time   `a:"t"`
+	TD time.Time   `a:"td,format=date"`
+	X  [2]uint8    `a:"x"`
+	Y  interface{} `a:"y"`
+	Z  string      `a:"-"`
+}
+
+func newDecodeDemo() decodeDemo {
+	return decodeDemo{
+		A:  1,
+		B:  "BB",
+		C:  Int64Ptr(2),
+		D:  3.14,
+		E:  []int{2, 0, 32},
+		F:  Info{CPU: StringPtr("1核")},
+		G:  true,
+		H:  []*Info{{CPU: StringPtr("2核")}, {CPU: StringPtr("3核")}},
+		J:  []byte("Golang"),
+		L:  []string{"hello", "ladies", "gentlemen"},
+		T:  time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+		TD: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+		X:  [2]uint8{7, 8},
+	}
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	for _, style := range []KeyStyle{KeyStyleDotted, KeyStyleBracket} {
+		p := New("a", "-", WithKeyStyle(style))
+		src := newDecodeDemo()
+		m, err := p.ToMap(reflect.ValueOf(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dst decodeDemo
+		if err := p.DecodeMap(m, &dst); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(src, dst) {
+			t.Fatalf("Style %d: got %+v, want %+v", style, dst, src)
+		}
+	}
+}
+
+func TestDecodeKeyStyles(t *testing.T) {
+	type Demo struct {
+		E []int            `a:"e"`
+		H []*Info          `a:"h"`
+		Y interface{}      `a:"y"`
+		N struct{ M Info } `a:"n"`
+	}
+	want := Demo{E: []int{1, 2, 3}, H: []*Info{{CPU: StringPtr("1核")}, {CPU: StringPtr("2核")}}}
+	want.N.M.CPU = StringPtr("x")
+	want.Y = map[string]interface{}{"k": "v", "l": []interface{}{"1", "2"}}
+
+	cases := []url.Values{
+		// 点号风格
+		{"e.0": {"1"}, "e.1": {"2"}, "e.2": {"3"}, "h.0.cpu": {"1核"}, "h.1.cpu": {"2核"}, "y.k": {"v"}, "y.l": {"1", "2"}, "n.M.cpu": {"x"}},
+		// 方括号风格
+		{"e[0]": {"1"}, "e[1]": {"2"}, "e[2]": {"3"}, "h[0][cpu]": {"1核"}, "h[1][cpu]": {"2核"}, "y[k]": {"v"}, "y[l]": {"1", "2"}, "n[M][cpu]": {"x"}},
+		// 重复key, 混合风格, 非连续下标
+		{"e": {"1", "2", "3"}, "h[3].cpu": {"2核"}, "h.1[cpu]": {"1核"}, "y.k": {"v"}, "y[l]": {"1", "2"}, "n.M[cpu]": {"x"}},
+	}
+	p := New("a", "-")
+	for i, values := range cases {
+		var got Demo
+		if err := p.Decode(values, &got); err != nil {
+			t.Fatalf("Case %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Case %d: got %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestDecodeExplicitKeyStyle(t *testing.T) {
+	type Demo struct {
+		M interface{} `a:"m"`
+	}
+	values := url.Values{"m[a.b]": {"1"}, "m.c[d]": {"2"}}
+
+	var dotted, bracket Demo
+	if err := New("a", "-", WithDecodeKeyStyle(KeyStyleDotted)).Decode(values, &dotted); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"c[d]": "2"}; !reflect.DeepEqual(dotted.M, want) {
+		t.Fatalf("Got %v, want %v", dotted.M, want)
+	}
+	if err := New("a", "-", WithDecodeKeyStyle(KeyStyleBracket)).Decode(values, &bracket); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"a.b": "1"}; !reflect.DeepEqual(bracket.M, want) {
+		t.Fatalf("Got %v, want %v", bracket.M, want)
+	}
+}
+
+func TestDecodeIndexFormats(t *testing.T) {
+	type Demo struct {
+		A []int `a:"a,idxfmt=[%d]"`
+		B []int `a:"b,idxfmt=.member.%d"`
+		C []int `a:"c,index_pad=3"`
+		D []int `a:"d,idxfmt=.item%d"`
+	}
+	src := Demo{A: []int{1, 2}, B: []int{3}, C: []int{4, 5}, D: []int{6}}
+	p := New("a", "-", WithIndexBase(1))
+	m, err := p.ToMap(reflect.ValueOf(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dst Demo
+	if err := p.DecodeMap(m, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(src, dst) {
+		t.Fatalf("Got %+v, want %+v from %v", dst, src, m)
+	}
+
+	if err := p.DecodeMap(map[string]string{"c.000": "1"}, &dst); err == nil {
+		t.Fatal("Expect error for index less than base")
+	}
+}
+
+func TestDecodeAWSQuery(t *testing.T) {
+	type Filter struct {
+		Name   string   `a:"Name"`
+		Values []string `a:"Value"`
+	}
+	type Request struct {
+		Action  string   `a:"Action"`
+		Filters []Filter `a:"Filter"`
+		IDs     []string `a:"InstanceId"`
+		Tags    []Filter `a:"Tags,idxfmt=.member.%d"`
+	}
+	values := url.Values{
+		"Action":             {"DescribeInstances"},
+		"Filter.10.Name":     {"c"},
+		"Filter.2.Name":      {"b"},
+		"Filter.2.Value.1":   {"y"},
+		"Filter.1.Name":      {"a"},
+		"Filter.1.Value.2":   {"x2"},
+		"Filter.1.Value.1":   {"x1"},
+		"InstanceId.2":       {"i-2"},
+		"InstanceId.1":       {"i-1"},
+		"Tags.member.1.Name": {"k"},
+	}
+	var dst Request
+	if err := New("a", "-", WithIndexBase(1)).Decode(values, &dst); err != nil {
+		t.Fatal(err)
+	}
+	want := Request{
+		Action:  "DescribeInstances",
+		Filters: []Filter{{Name: "a", Values: []string{"x1", "x2"}}, {Name: "b", Values: []string{"y"}}, {Name: "c"}},
+		IDs:     []string{"i-1", "i-2"},
+		Tags:    []Filter{{Name: "k"}},
+	}
+	if !reflect.DeepEqual(dst, want) {
+		t.Fatalf("Got %+v, want %+v", dst, want)
+	}
+
+	values.Set("Filter.0.Name", "z")
+	if err := New("a", "-", WithIndexBase(1)).Decode(values, &dst); err == nil {
+		t.Fatal("Expect error for index less than base")
+	}
+}
+
+func TestDecodePHPBrackets(t *testing.T) {
+	type User struct {
+		Name  string   `a:"name"`
+		Roles []string `a:"roles"`
+	}
+	type Demo struct {
+		Tags  []string    `a:"tags"`
+		User  User        `a:"user"`
+		Users []User      `a:"users"`
+		Extra interface{} `a:"extra"`
+	}
+	values := url.Values{
+		"tags[]":            {"a", "b"},
+		"user[name]":        {"x"},
+		"user[roles][0]":    {"admin"},
+		"user[roles][1]":    {"dev"},
+		"users[0][name]":    {"y"},
+		"users[0][roles][]": {"ops", "qa"},
+		"extra[ids][]":      {"1", "2"},
+		"extra[k]":          {"v"},
+	}
+	var dst Demo
+	if err := New("a", "-").Decode(values, &dst); err != nil {
+		t.Fatal(err)
+	}
+	want := Demo{
+		Tags:  []string{"a", "b"},
+		User:  User{Name: "x", Roles: []string{"admin", "dev"}},
+		Users: []User{{Name: "y", Roles: []string{"ops", "qa"}}},
+		Extra: map[string]interface{}{"ids": []interface{}{"1", "2"}, "k": "v"},
+	}
+	if !reflect.DeepEqual(dst, want) {
+		t.Fatalf("Got %+v, want %+v", dst, want)
+	}
+
+	// 按点号拆分时"[]"只是key的一部分
+	var dotted Demo
+	if err := New("a", "-", WithDecodeKeyStyle(KeyStyleDotted)).Decode(url.Values{"tags[]": {"a"}}, &dotted); err != nil {
+		t.Fatal(err)
+	}
+	if dotted.Tags != nil {
+		t.Fatalf("Got %v, want nil", dotted.Tags)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	p := New("a", "-")
+	var v decodeDemo
+	for _, m := range []map[string]string{
+		{"a": "x"},
+		{"g": "maybe"},
+		{"e.x": "1"},
+		{"x.2": "1"},
+		{"j": "!!"},
+		{"t": "yesterday"},
+	} {
+		if err := p.DecodeMap(m, &v); err == nil {
+			t.Fatalf("Expect error for %v", m)
+		}
+	}
+	if err := p.DecodeMap(nil, v); err == nil {
+		t.Fatal("Expect error for non-pointer dst")
+	}
+}
+
+func TestSplitKey(t *testing.T) {
+	cases := []struct {
+		key   string
+		style KeyStyle
+		want  []string
+	}{
+		{"a", KeyStyleAuto, []string{"a"}},
+		{"a.b.0.c", KeyStyleAuto, []string{"a", "b", "0", "c"}},
+		{"a[b][0][c]", KeyStyleAuto, []string{"a", "b", "0", "c"}},
+		{"a.b[0].c", KeyStyleAuto, []string{"a", "b", "0", "c"}},
+		{"a[b.c]d", KeyStyleAuto, []string{"a", "b.c", "d"}},
+		{"a[b", KeyStyleAuto, []string{"a[b"}},
+		{"[a]", KeyStyleAuto, []string{"", "a"}},
+		{"a.b[0]", KeyStyleDotted, []string{"a", "b[0]"}},
+		{"a.b[0]", KeyStyleBracket, []string{"a.b", "0"}},
+	}
+	for _, c := range cases {
+		p := New("a", "-", WithDecodeKeyStyle(c.style))
+		if got := p.splitKey(c.key); !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("splitKey(%q, %d) = %q, want %q", c.key, c.style, got, c.want)
+		}
+	}
+}
+
+func TestDecodeAlias(t *testing.T) {
+	type Demo struct {
+		AK string `a:"access_key,alias=ak|accessKey"`
+	}
+	p := New("a", "-")
+	for _, m := range []map[string]string{
+		{"access_key": "x"},
+		{"accessKey": "x"},
+		{"access_key": "x", "ak": "y"},
+	} {
+		var got Demo
+		if err := p.DecodeMap(m, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.AK != "x" {
+			t.Fatalf("Got %q from %v", got.AK, m)
+		}
+	}
+}
+
+// roundTripEmbedded 未导出的嵌入struct, 其导出字段与外层的字段一起编解码
+type roundTripEmbedded struct {
+	K string `a:"k"`
+	M *int   `a:"m"`
+}
+
+// roundTripDemo 编码后能无损解码的struct, 用于校验decode(encode(v))与v相等
+type roundTripDemo struct {
+	roundTripEmbedded `a:"..."`
+	A                 int      `a:"a"`
+	B                 string   `a:"b"`
+	E                 []int    `a:"e"`
+	F                 Info     `a:"f"`
+	G                 bool     `a:"g"`
+	S                 []string `a:"s"`
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add("a=1&e.0=2&h[1][cpu]=x&y.k=v&x.1=3&j=R28=")
+	f.Add("e[]=1&h.9999.cpu=&t=2020-01-02T03:04:05Z")
+	f.Add("k=x&m=2&b=y&f.cpu=1&s.0=a,b&s.1=")
+	p := New("a", "-")
+	f.Fuzz(func(t *testing.T, query string) {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return
+		}
+		var v decodeDemo
+		_ = p.Decode(values, &v)
+
+		var src roundTripDemo
+		if err := p.Decode(values, &src); err != nil {
+			return
+		}
+		src.K = query // 嵌入struct的字段不依赖解码也有值
+		m, err := p.ToMap(reflect.ValueOf(src))
+		if err != nil {
+			t.Fatalf("Encode %+v failed, %v", src, err)
+		}
+		var dst roundTripDemo
+		if err := p.DecodeMap(m, &dst); err != nil {
+			t.Fatalf("Decode %v failed, %v", m, err)
+		}
+		if !reflect.DeepEqual(dst, src) {
+			t.Fatalf("Got %+v after round trip of %v, want %+v", dst, m, src)
+		}
+	})
+}
+
+func TestDecodeRunes(t *testing.T) {
+	type Demo struct {
+		R []rune  `a:"r,runes"`
+		A [4]rune `a:"a,runes"`
+		N []int32 `a:"n"`
+	}
+	p := New("a", "-")
+	var got Demo
+	if err := p.Decode(url.Values{"r": {"中文"}, "a": {"ab"}, "n": {"1", "2"}}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Demo{R: []rune("中文"), A: [4]rune{'a', 'b'}, N: []int32{1, 2}}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got %+v, want %+v", got, want)
+	}
+	if err := p.Decode(url.Values{"a": {"abcde"}}, &got); err == nil {
+		t.Fatal("Expect error for too many runes")
+	}
+}
+
+func TestNestedSlices(t *testing.T) {
+	type Demo struct {
+		S [][]string `a:"s"`
+		I [][]int    `a:"i,idxfmt=.n%d"`
+		A [2][2]int  `a:"a"`
+		P [][]*Info  `a:"p"`
+		B [][]byte   `a:"b"`
+		J [][]string `a:"j,join"`
+	}
+	src := Demo{
+		S: [][]string{{"a", "b"}, {"c"}},
+		I: [][]int{{1}, {2, 3}},
+		A: [2][2]int{{4, 5}, {6, 7}},
+		P: [][]*Info{{{CPU: StringPtr("x")}}},
+		B: [][]byte{[]byte("Go")},
+		J: [][]string{{"d", "e"}},
+	}
+	wantKeys := map[KeyStyle][]string{
+		KeyStyleDotted:  {"s.0.0", "s.0.1", "s.1.0", "i.n0.n0", "i.n1.n0", "i.n1.n1", "a.0.0", "a.0.1", "a.1.0", "a.1.1", "p.0.0.cpu", "b.0", "j.0"},
+		KeyStyleBracket: {"s[0][0]", "s[0][1]", "s[1][0]", "i.n0.n0", "i.n1.n0", "i.n1.n1", "a[0][0]", "a[0][1]", "a[1][0]", "a[1][1]", "p[0][0][cpu]", "b[0]", "j[0]"},
+	}
+	for style, keys := range wantKeys {
+		p := New("a", "-", WithKeyStyle(style))
+		kvs, err := p.Encode(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		m := make(map[string]string)
+		for _, kv := range kvs {
+			got = append(got, kv.K)
+			m[kv.K] = kv.V
+		}
+		if !reflect.DeepEqual(got, keys) {
+			t.Fatalf("Style %d: got %v, want %v", style, got, keys)
+		}
+		var dst Demo
+		if err := p.DecodeMap(m, &dst); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(dst, src) {
+			t.Fatalf("Style %d: got %+v, want %+v", style, dst, src)
+		}
+	}
+
+	// 内层以重复的key表示
+	var dst Demo
+	if err := New("a", "-").Decode(url.Values{"s.0": {"a", "b"}, "s.1": {"c"}}, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"a", "b"}, {"c"}}; !reflect.DeepEqual(dst.S, want) {
+		t.Fatalf("Got %v, want %v", dst.S, want)
+	}
+}
+
+func TestRawBytes(t *testing.T) {
+	type Demo struct {
+		T []byte   `a:"t,raw"`
+		L [][]byte `a:"l,raw"`
+		B []byte   `a:"b"`
+	}
+	src := Demo{T: []byte("{{.Name}} 你好"), L: [][]byte{[]byte("a=b")}, B: []byte("Go")}
+	p := New("a", "-")
+	kvs, err := p.Encode(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []KV{{"t", "{{.Name}} 你好"}, {"l.0", "a=b"}, {"b", "R28="}}; !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Got %v, want %v", kvs, want)
+	}
+	var dst Demo
+	if err := p.DecodeMap(map[string]string{"t": "{{.Name}} 你好", "l.0": "a=b", "b": "R28="}, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dst, src) {
+		t.Fatalf("Got %+v, want %+v", dst, src)
+	}
+}
+
+func TestRepeatedKeys(t *testing.T) {
+	type Demo struct {
+		E []int      `a:"e,repeat"`
+		S []*string  `a:"s"`
+		P []int      `a:"p,index_pad=2"`
+		I []Info     `a:"i"`
+		A [2]float64 `a:"a"`
+	}
+	v := Demo{E: []int{1, 2}, S: []*string{StringPtr("x"), StringPtr("y")}, P: []int{3}, I: []Info{{CPU: StringPtr("1核")}}, A: [2]float64{1.5, 2}}
+	cases := []struct {
+		opts []Option
+		want string
+	}{
+		{nil, "e=1&e=2&s.0=x&s.1=y&p.00=3&i.0.cpu=1%E6%A0%B8&a.0=1.5&a.1=2"},
+		{[]Option{WithRepeatedKeys(true)}, "e=1&e=2&s=x&s=y&p.00=3&i.0.cpu=1%E6%A0%B8&a=1.5&a=2"},
+	}
+	for i, c := range cases {
+		p := New("a", "-", c.opts...)
+		values := make(url.Values)
+		if err := p.EncodeTo(v, ValuesSink(values)); err != nil {
+			t.Fatal(err)
+		}
+		var sb strings.Builder
+		if err := p.EncodeTo(v, NewWriterSink(&sb)); err != nil {
+			t.Fatal(err)
+		}
+		if sb.String() != c.want {
+			t.Fatalf("Case %d: got %s, want %s", i, sb.String(), c.want)
+		}
+		if n, _, err := p.EstimateSize(v); err != nil || n != 8 {
+			t.Fatalf("Case %d: estimated %d KVs, %v", i, n, err)
+		}
+
+		var dst Demo
+		if err := p.Decode(values, &dst); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(dst, v) {
+			t.Fatalf("Case %d: got %+v, want %+v", i, dst, v)
+		}
+	}
+
+	// 只接受重复的key, 带下标的key被忽略
+	values, err := url.ParseQuery("e.0=1&e.1=2&s.0=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dst Demo
+	if err := New("a", "-", WithRepeatedKeys(true)).Decode(values, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if len(dst.E) != 0 || len(dst.S) != 0 {
+		t.Fatalf("Got %+v, indexed keys should be ignored", dst)
+	}
+}
+
+func TestDecodeDefault(t *testing.T) {
+	type Page struct {
+		Size int    `a:"size,default=20"`
+		Sort string `a:"sort,default=id"`
+	}
+	type Demo struct {
+		Page  Page     `a:"page"`
+		Num   *int     `a:"num,default=1"`
+		Tags  []string `a:"tags,default=x"`
+		Debug bool     `a:"debug,default=true"`
+		Name  string   `a:"name"`
+	}
+	p := New("a", "-")
+
+	var dst Demo
+	if err := p.Decode(url.Values{"page.sort": {"name"}, "debug": {"false"}}, &dst); err != nil {
+		t.Fatal(err)
+	}
+	want := Demo{Page: Page{Size: 20, Sort: "name"}, Num: IntPtr(1), Tags: []string{"x"}, Debug: false}
+	if !reflect.DeepEqual(dst, want) {
+		t.Fatalf("Got %+v, want %+v", dst, want)
+	}
+
+	type Bad struct {
+		N int `a:"n,default=x"`
+	}
+	if err := p.Decode(url.Values{}, &Bad{}); err == nil {
+		t.Fatal("Expect error for invalid default")
+	}
+}
+
+func TestDecodeRequired(t *testing.T) {
+	type Page struct {
+		Size int `a:"size,required"`
+		Num  int `a:"num,required,default=1"`
+	}
+	type Demo struct {
+		AK   string `a:"access_key,required,alias=ak"`
+		Name string `a:"name,required"`
+		Page Page   `a:"page"`
+		Opt  *Page  `a:"opt"`
+	}
+	p := New("a", "-")
+
+	var dst Demo
+	err := p.Decode(url.Values{"ak": {"x"}}, &dst)
+	var me *MissingError
+	if !errors.As(err, &me) {
+		t.Fatalf("Got %v, want *MissingError", err)
+	}
+	if want := []string{"name", "page.size"}; !reflect.DeepEqual(me.Keys, want) {
+		t.Fatalf("Got %v, want %v", me.Keys, want)
+	}
+	if dst.AK != "x" || dst.Page.Num != 1 {
+		t.Fatalf("Present fields should still be decoded, got %+v", dst)
+	}
+	if want := `formparser: Missing required parameters: name, page.size`; err.Error() != want {
+		t.Fatalf("Got %s, want %s", err, want)
+	}
+
+	err = p.Decode(url.Values{"access_key": {"x"}, "name": {"n"}, "page.size": {"10"}}, &dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Decode(url.Values{"opt.num": {"2"}}, &dst); !errors.As(err, &me) || len(me.Keys) != 4 {
+		t.Fatalf("Got %v", err)
+	}
+}
+
+func TestDecodeTimeLayouts(t *testing.T) {
+	type Demo struct {
+		T  time.Time   `a:"t,layouts=RFC3339|2006-01-02"`
+		P  *time.Time  `a:"p,layouts=DateTime,tz=Asia/Shanghai"`
+		TS []time.Time `a:"ts,layouts=DateOnly|Kitchen"`
+	}
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip(err)
+	}
+	p := New("a", "-")
+
+	cases := []struct {
+		values url.Values
+		want   Demo
+	}{
+		{
+			url.Values{"t": {"2024-05-06T07:08:09+08:00"}, "p": {"2024-05-06 07:08:09"}, "ts": {"2024-01-02", "3:04PM"}},
+			Demo{
+				T:  time.Date(2024, 5, 6, 7, 8, 9, 0, time.FixedZone("", 8*3600)),
+				P:  func() *time.Time { t := time.Date(2024, 5, 6, 7, 8, 9, 0, shanghai); return &t }(),
+				TS: []time.Time{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(0, 1, 1, 15, 4, 0, 0, time.UTC)},
+			},
+		},
+		{
+			url.Values{"t": {"2024-05-06"}},
+			Demo{T: time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+	for i, c := range cases {
+		var dst Demo
+		if err := p.Decode(c.values, &dst); err != nil {
+			t.Fatal(err)
+		}
+		if !dst.T.Equal(c.want.T) || (dst.P == nil) != (c.want.P == nil) || dst.P != nil && !dst.P.Equal(*c.want.P) || len(dst.TS) != len(c.want.TS) {
+			t.Fatalf("Case %d: got %+v, want %+v", i, dst, c.want)
+		}
+		for j := range dst.TS {
+			if !dst.TS[j].Equal(c.want.TS[j]) {
+				t.Fatalf("Case %d: got %v, want %v", i, dst.TS[j], c.want.TS[j])
+			}
+		}
+	}
+
+	if err := p.Decode(url.Values{"t": {"06/05/2024"}}, &Demo{}); err == nil {
+		t.Fatal("Expect error for unmatched layouts")
+	}
+}
+
+func TestDecodeBytesSymmetric(t *testing.T) {
+	type Blob []byte
+	type Point struct{ X, Y int }
+	type Demo struct {
+		B   []byte `a:"b"`
+		R   []byte `a:"r,raw"`
+		N   Blob   `a:"n"`
+		H   []byte `a:"h,encoder=hex"`
+		S   string `a:"s,encoder=base64"`
+		G   Point  `a:"g,encoder=gob+base64"`
+		Nil []byte `a:"nil"`
+	}
+	v := Demo{B: []byte{0, 1, 0xff}, R: []byte("text"), N: Blob("blob"), H: []byte{0xab}, S: "str", G: Point{1, 2}, Nil: []byte{}}
+	for _, opt := range []Option{WithBytesFormat(BytesBase64), WithBytesFormat(BytesBase64URL), WithBytesFormat(BytesHex)} {
+		p := New("a", "-", opt)
+		m, err := p.ToMap(reflect.ValueOf(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m["h"] != "ab" || m["r"] != "text" {
+			t.Fatalf("Got %v", m)
+		}
+		var dst Demo
+		if err := p.DecodeMap(m, &dst); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(dst, v) {
+			t.Fatalf("Got %+v, want %+v from %v", dst, v, m)
+		}
+	}
+
+	type Bad struct {
+		I int `a:"i,encoder=hex"`
+	}
+	if err := New("a", "-").Decode(url.Values{"i": {"ab"}}, &Bad{}); err == nil {
+		t.Fatal("Expect error for decoding bytes into int")
+	}
+}
+
+func TestDecodeMaps(t *testing.T) {
+	type Demo struct {
+		I map[string]*string        `a:"i"`
+		N map[int]Info              `a:"n"`
+		M map[string]map[string]int `a:"m"`
+		S map[string][]int          `a:"s"`
+		T map[time.Time]bool        `a:"t"`
+	}
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	src := Demo{
+		I: map[string]*string{"m1": StringPtr("a"), "m2": StringPtr("b")},
+		N: map[int]Info{1: {CPU: StringPtr("1核")}, 10: {CPU: StringPtr("2核")}},
+		M: map[string]map[string]int{"x": {"y": 1}},
+		S: map[string][]int{"k": {1, 2}},
+		T: map[time.Time]bool{ts: true},
+	}
+	p := New("a", "-")
+	for _, style := range []KeyStyle{KeyStyleDotted, KeyStyleBracket} {
+		m, err := p.ToMap(reflect.ValueOf(src), WithKeyStyle(style))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dst Demo
+		if err := p.DecodeMap(m, &dst); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(dst, src) {
+			t.Fatalf("Style %d: got %+v, want %+v from %v", style, dst, src, m)
+		}
+	}
+
+	// 已存在的元素在原值的基础上继续解码
+	dst := Demo{M: map[string]map[string]int{"x": {"z": 2}}}
+	if err := p.Decode(url.Values{"m.x.y": {"1"}}, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]map[string]int{"x": {"y": 1, "z": 2}}; !reflect.DeepEqual(dst.M, want) {
+		t.Fatalf("Got %v, want %v", dst.M, want)
+	}
+
+	if err := p.Decode(url.Values{"n.x.cpu": {"1"}}, &Demo{}); err == nil || !strings.Contains(err.Error(), `"n.x"`) {
+		t.Fatalf("Got %v, want error of key n.x", err)
+	}
+}
+
+func TestDecodeInline(t *testing.T) {
+	type Inner struct {
+		S Info           `a:"..."`
+		L []Info         `a:"..."`
+		M map[string]int `a:"...,alias=m"`
+	}
+	type Outer struct {
+		P Inner   `a:"p"`
+		Q []Inner `a:"q"`
+		R *Info   `a:",inline"`
+		T *Inner  `a:"t"`
+	}
+	v := Outer{
+		P: Inner{S: Info{CPU: StringPtr("1核")}, L: []Info{{CPU: StringPtr("2核")}}, M: map[string]int{"x": 1}},
+		Q: []Inner{{M: map[string]int{"y": 2}}},
+		R: &Info{CPU: StringPtr("3核")},
+	}
+	p := New("a", "-")
+	for _, style := range []KeyStyle{KeyStyleDotted, KeyStyleBracket} {
+		m, err := p.ToMap(reflect.ValueOf(v), WithKeyStyle(style))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dst Outer
+		if err := p.DecodeMap(m, &dst); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(dst, v) {
+			t.Fatalf("Style %d: got %+v, want %+v from %v", style, dst, v, m)
+		}
+	}
+
+	// 主名没有可用的子节点时使用别名, 没有属于指针字段的子节点时不分配
+	var dst Outer
+	if err := p.Decode(url.Values{"p.m.z": {"3"}}, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"z": 3}; !reflect.DeepEqual(dst.P.M, want) || dst.R != nil || dst.T != nil {
+		t.Fatalf("Got %+v", dst)
+	}
+}
+
+func TestDecodeCaseInsensitive(t *testing.T) {
+	type Inner struct {
+		M map[string]int `a:"..."`
+	}
+	type Demo struct {
+		Name string   `a:"name"`
+		AK   string   `a:"access_key,alias=ak"`
+		Info Info     `a:"info"`
+		E    []int    `a:"e"`
+		I    Inner    `a:"i"`
+		L    []string `a:"l"`
+	}
+	values := url.Values{
+		"Name": {"b"}, "NAME": {"a"}, "AK": {"k"}, "INFO.CPU": {"8核"}, "E.0": {"1"},
+		"i.Foo": {"1"}, "l": {"exact"}, "L": {"upper"},
+	}
+	var dst Demo
+	if err := New("a", "-", WithCaseInsensitiveKeys(true)).Decode(values, &dst); err != nil {
+		t.Fatal(err)
+	}
+	want := Demo{Name: "a", AK: "k", Info: Info{CPU: StringPtr("8核")}, E: []int{1}, I: Inner{M: map[string]int{"Foo": 1}}, L: []string{"exact"}}
+	if !reflect.DeepEqual(dst, want) {
+		t.Fatalf("Got %+v, want %+v", dst, want)
+	}
+
+	dst = Demo{}
+	if err := New("a", "-").Decode(values, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Name != "" || dst.AK != "" || dst.L[0] != "exact" {
+		t.Fatalf("Got %+v, keys should be case sensitive by default", dst)
+	}
+}
+
+func TestDecodeAccept(t *testing.T) {
+	type Demo struct {
+		PageSize int    `a:"page_size,accept=pageSize|size"`
+		AK       string `a:"access_key,alias=ak,accept=accessKey"`
+	}
+	p := New("a", "-")
+	m, err := p.ToMap(reflect.ValueOf(Demo{PageSize: 10, AK: "x"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"page_size": "10", "access_key": "x", "ak": "x"}; !reflect.DeepEqual(m, want) {
+		t.Fatalf("Got %v, want %v, accept should not be encoded", m, want)
+	}
+
+	cases := []struct {
+		values url.Values
+		want   Demo
+	}{
+		{url.Values{"pageSize": {"20"}, "accessKey": {"y"}}, Demo{20, "y"}},
+		{url.Values{"size": {"30"}, "pageSize": {"20"}, "ak": {"z"}, "accessKey": {"y"}}, Demo{20, "z"}},
+		{url.Values{"page_size": {"40"}, "pageSize": {"20"}}, Demo{PageSize: 40}},
+	}
+	for i, c := range cases {
+		var dst Demo
+		if err := p.Decode(c.values, &dst); err != nil {
+			t.Fatal(err)
+		}
+		if dst != c.want {
+			t.Fatalf("Case %d: got %+v, want %+v", i, dst, c.want)
+		}
+	}
+}
+
+func TestBoolLexicon(t *testing.T) {
+	type Demo struct {
+		A bool  `a:"a"`
+		B *bool `a:"b"`
+	}
+	p := New("a", "-", WithBoolLexicon(BoolLexicon{True: "on", False: "off", TrueValues: []string{"yes", "1"}, FalseValues: []string{"no", "0"}}))
+	m, err := p.ToMap(reflect.ValueOf(Demo{A: true, B: BoolPtr(false)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"a": "on", "b": "off"}; !reflect.DeepEqual(m, want) {
+		t.Fatalf("Got %v, want %v", m, want)
+	}
+
+	for _, c := range []struct {
+		a, b  string
+		wantA bool
+		wantB bool
+	}{
+		{"on", "off", true, false},
+		{"YES", "No", true, false},
+		{"1", "0", true, false},
+	} {
+		var dst Demo
+		if err := p.Decode(url.Values{"a": {c.a}, "b": {c.b}}, &dst); err != nil {
+			t.Fatal(err)
+		}
+		if dst.A != c.wantA || *dst.B != c.wantB {
+			t.Fatalf("Got %+v for %s, %s", dst, c.a, c.b)
+		}
+	}
+	if err := p.Decode(url.Values{"a": {"true"}}, &Demo{}); err == nil {
+		t.Fatal("Expect error for string out of the lexicon")
+	}
+
+	cfg := p.Options()
+	cfg.BoolLexicon.TrueValues[0] = "x"
+	if p.Options().BoolLexicon.TrueValues[0] != "yes" {
+		t.Fatal("Options should return a copy of the lexicon")
+	}
+
+	for _, l := range []BoolLexicon{{True: "on"}, {True: "1", False: "0", TrueValues: []string{"0"}}, {True: "y", False: "Y"}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("Expect panic for %+v", l)
+				}
+			}()
+			WithBoolLexicon(l)
+		}()
+	}
+}
+
+func TestDecodeLimits(t *testing.T) {
+	type Demo struct {
+		E []int   `a:"e"`
+		H []*Info `a:"h"`
+	}
+	p := New("a", "-", WithMaxKeyDepth(3), WithMaxIndex(10))
+	var dst Demo
+	if err := p.Decode(url.Values{"e.10": {"1"}, "h.0.cpu": {"x"}}, &dst); err != nil {
+		t.Fatal(err)
+	}
+	for _, values := range []url.Values{
+		{"e.999999999": {"1"}},
+		{"h.0.cpu.x": {"x"}},
+		{"h[0][cpu][x]": {"x"}},
+	} {
+		if err := p.Decode(values, &Demo{}); !errors.Is(err, ErrDecodeLimit) {
+			t.Fatalf("Got %v for %v, want ErrDecodeLimit", err, values)
+		}
+	}
+	if err := New("a", "-", WithIndexBase(1), WithMaxIndex(10)).Decode(url.Values{"e.11": {"1"}}, &dst); err != nil {
+		t.Fatalf("Index should be compared after the base, %v", err)
+	}
+}
+
+func TestDecodeMaxKeys(t *testing.T) {
+	type Demo struct {
+		A string `a:"a"`
+		E []int  `a:"e"`
+	}
+	p := New("a", "-", WithMaxKeys(3))
+	if err := p.Decode(url.Values{"a": {"x"}, "e": {"1", "2"}}, &Demo{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Decode(url.Values{"a": {"x"}, "e": {"1", "2", "3"}}, &Demo{}); !errors.Is(err, ErrDecodeLimit) {
+		t.Fatalf("Got %v, want ErrDecodeLimit", err)
+	}
+	if err := p.DecodeQuery(strings.Repeat("x=1&", 4), &Demo{}); !errors.Is(err, ErrDecodeLimit) {
+		t.Fatalf("Got %v, want ErrDecodeLimit", err)
+	}
+}
@@ -0,0 +1,90 @@
+package formparser
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ChangeKind 两份表单数据之间某个key的变化类型
+type ChangeKind int
+
+const (
+	// ChangeAdded 仅存在于新数据中
+	ChangeAdded ChangeKind = iota
+	// ChangeRemoved 仅存在于旧数据中
+	ChangeRemoved
+	// ChangeModified 两者都存在但值不同
+	ChangeModified
+)
+
+// Change 某个key的变化, Added时Old为空, Removed时New为空
+type Change struct {
+	Kind ChangeKind
+	Key  string
+	Old  string
+	New  string
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s=%s", c.Key, c.New)
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s=%s", c.Key, c.Old)
+	}
+	return fmt.Sprintf("~ %s=%s -> %s", c.Key, c.Old, c.New)
+}
+
+// Diff 比较a(旧)与b(新), 按key排序返回所有新增、删除、修改的key, 两者相同时返回nil
+func Diff(a, b map[string]string) []Change {
+	var changes []Change
+	for k, old := range a {
+		if v, ok := b[k]; !ok {
+			changes = append(changes, Change{Kind: ChangeRemoved, Key: k, Old: old})
+		} else if v != old {
+			changes = append(changes, Change{Kind: ChangeModified, Key: k, Old: old, New: v})
+		}
+	}
+	for k, v := range b {
+		if _, ok := a[k]; !ok {
+			changes = append(changes, Change{Kind: ChangeAdded, Key: k, New: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// DiffValues 分别以ToMap编码a、b后比较, 参见Diff
+func (p *FormParser) DiffValues(a, b interface{}, opts ...Option) ([]Change, error) {
+	ma, err := p.ToMap(valueOf(a), opts...)
+	if err != nil {
+		return nil, err
+	}
+	mb, err := p.ToMap(valueOf(b), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(ma, mb), nil
+}
+
+// Equal 比较a、b编码后的KV列表是否相同, 与字段、map的遍历顺序无关, 便于测试中断言两个结构不同的模型在传输层等价.
+// 同名key的多个值按顺序比较
+func (p *FormParser) Equal(a, b interface{}, opts ...Option) (bool, error) {
+	ka, err := p.canonicalKVs(a, opts)
+	if err != nil {
+		return false, err
+	}
+	kb, err := p.canonicalKVs(b, opts)
+	if err != nil {
+		return false, err
+	}
+	if len(ka) != len(kb) {
+		return false, nil
+	}
+	for i := range ka {
+		if ka[i] != kb[i] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,82 @@
+package formparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := map[string]string{"a": "1", "b": "2", "c": "3"}
+	b := map[string]string{"a": "1", "b": "20", "d": "4"}
+	want := []Change{
+		{Kind: ChangeModified, Key: "b", Old: "2", New: "20"},
+		{Kind: ChangeRemoved, Key: "c", Old: "3"},
+		{Kind: ChangeAdded, Key: "d", New: "4"},
+	}
+	got := Diff(a, b)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+	var strs []string
+	for _, c := range got {
+		strs = append(strs, c.String())
+	}
+	if want := []string{"~ b=2 -> 20", "- c=3", "+ d=4"}; !reflect.DeepEqual(strs, want) {
+		t.Fatalf("Got %q, want %q", strs, want)
+	}
+	if got := Diff(a, a); got != nil {
+		t.Fatalf("Got %v, want nil", got)
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	type Demo struct {
+		A string `a:"a"`
+		B []int  `a:"b"`
+	}
+	p := New("a", "-")
+	got, err := p.DiffValues(Demo{A: "x", B: []int{1, 2}}, &Demo{A: "x", B: []int{1}}, WithRootKey("r"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Change{{Kind: ChangeRemoved, Key: "r.b.1", Old: "2"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+	if _, err := p.DiffValues(Demo{}, 1); err == nil {
+		t.Fatal("Expect error for invalid param")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	type A struct {
+		X string `a:"x"`
+		Y []int  `a:"y"`
+	}
+	type B struct {
+		Y []string       `a:"y"`
+		X *string        `a:"x"`
+		Z map[string]int `a:"z"`
+	}
+	p := New("a", "-")
+	cases := []struct {
+		a, b interface{}
+		want bool
+	}{
+		{A{X: "1", Y: []int{1, 2}}, &B{X: StringPtr("1"), Y: []string{"1", "2"}}, true},
+		{A{X: "1", Y: []int{1, 2}}, map[string]string{"y.1": "2", "x": "1", "y.0": "1"}, true},
+		{A{X: "1", Y: []int{1, 2}}, B{X: StringPtr("1"), Y: []string{"2", "1"}}, false},
+		{A{X: "1"}, B{X: StringPtr("1"), Z: map[string]int{"k": 1}}, false},
+	}
+	for i, c := range cases {
+		got, err := p.Equal(c.a, c.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Fatalf("Case %d: got %v, want %v", i, got, c.want)
+		}
+	}
+	if _, err := p.Equal(A{}, 1); err == nil {
+		t.Fatal("Expect error for invalid param")
+	}
+}
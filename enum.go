@@ -0,0 +1,51 @@
+package formparser
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// RegisterEnum 为整数枚举类型T注册各个值的名字, T的值(及指向它的指针)编码为values中对应的名字,
+// 解码时既接受名字也接受数值; 编码或解码values之外的值时返回错误. 例如:
+//
+//	type Level int
+//	formparser.RegisterEnum(p, map[Level]string{0: "low", 1: "high"})
+//
+// 由于方法不能带有类型参数, 以函数的形式提供. 需在开始编码前完成注册, 注册过程非并发安全
+func RegisterEnum[T ~int](p *FormParser, values map[T]string) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if len(values) == 0 {
+		panic(fmt.Sprintf("%s: Missing values of enum %v", pkgName, t))
+	}
+	byValue := make(map[int64]string, len(values))
+	byName := make(map[string]int64, len(values))
+	for v, name := range values {
+		if name == "" {
+			panic(fmt.Sprintf("%s: Missing name of value %d of enum %v", pkgName, v, t))
+		}
+		if _, dup := byName[name]; dup {
+			panic(fmt.Sprintf("%s: Duplicate name %q of enum %v", pkgName, name, t))
+		}
+		byValue[int64(v)], byName[name] = name, int64(v)
+	}
+
+	p.typeEncoders[t] = func(v reflect.Value) (string, error) {
+		if name, ok := byValue[v.Int()]; ok {
+			return name, nil
+		}
+		return "", fmt.Errorf("%s: Unknown value %d of enum %v", pkgName, v.Int(), t)
+	}
+	p.typeDecoders[t] = func(s string, v reflect.Value) error {
+		n, ok := byName[s]
+		if !ok {
+			i, err := strconv.ParseInt(s, 10, 64)
+			if _, known := byValue[i]; err != nil || !known {
+				return fmt.Errorf("%s: Unknown value %q of enum %v", pkgName, s, t)
+			}
+			n = i
+		}
+		v.SetInt(n)
+		return nil
+	}
+}
@@ -0,0 +1,65 @@
+package formparser
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+type level int
+
+func TestRegisterEnum(t *testing.T) {
+	type Demo struct {
+		L  level   `a:"l"`
+		P  *level  `a:"p"`
+		LS []level `a:"ls"`
+	}
+	p := New("a", "-")
+	RegisterEnum(p, map[level]string{0: "low", 1: "mid", 2: "high"})
+
+	high := level(2)
+	v := Demo{L: 1, P: &high, LS: []level{0, 2}}
+	m, err := p.ToMap(reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"l": "mid", "p": "high", "ls.0": "low", "ls.1": "high"}; !reflect.DeepEqual(m, want) {
+		t.Fatalf("Got %v, want %v", m, want)
+	}
+	var dst Demo
+	if err := p.DecodeMap(m, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dst, v) {
+		t.Fatalf("Got %+v, want %+v", dst, v)
+	}
+
+	// 解码时也接受数值
+	dst = Demo{}
+	if err := p.Decode(url.Values{"l": {"2"}, "ls": {"mid", "0"}}, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Demo{L: 2, LS: []level{1, 0}}); !reflect.DeepEqual(dst, want) {
+		t.Fatalf("Got %+v, want %+v", dst, want)
+	}
+
+	for _, s := range []string{"3", "unknown", ""} {
+		if err := p.Decode(url.Values{"l": {s}}, &Demo{}); err == nil {
+			t.Fatalf("Expect error for %q", s)
+		}
+	}
+	if _, err := p.Encode(Demo{L: 5}); err == nil {
+		t.Fatal("Expect error for unknown value")
+	}
+
+	for _, values := range []map[level]string{nil, {0: ""}, {0: "x", 1: "x"}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("Expect panic for %v", values)
+				}
+			}()
+			RegisterEnum(New("a", "-"), values)
+		}()
+	}
+}
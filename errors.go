@@ -0,0 +1,95 @@
+package formparser
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"strings"
+)
+
+// FieldError 编码或解码某个字段时发生的错误, 嵌套的struct中出错时指向最内层的字段
+type FieldError struct {
+	Type  reflect.Type // 字段所属的struct类型
+	Field string       // Go字段名
+	Key   string       // 字段完整的key, 如"h.0.cpu"
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%v (field %v.%s, key %q)", e.Err, e.Type, e.Field, e.Key)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// wrapFieldError 将字段的错误包装为FieldError, 已经是FieldError时保持原样.
+// 解码slice、map的元素失败时, FieldError的Key为该元素的key
+func wrapFieldError(err error, t reflect.Type, field, key string) error {
+	var fe *FieldError
+	if err == nil || errors.As(err, &fe) {
+		return err
+	}
+	var de *decodeError
+	if errors.As(err, &de) {
+		key = de.key
+	}
+	return &FieldError{Type: t, Field: field, Key: key, Err: err}
+}
+
+// decodeError 解码某个key的值失败, 总是由wrapFieldError包装为FieldError, 因此消息中不重复key
+type decodeError struct {
+	key string
+	err error
+}
+
+func decodeFailed(key string, err error) error {
+	return &decodeError{key: key, err: err}
+}
+
+func (e *decodeError) Error() string {
+	return fmt.Sprintf("%s: Decode failed, %v", pkgName, e.err)
+}
+
+func (e *decodeError) Unwrap() error {
+	return e.err
+}
+
+// MissingError Decode时缺失了带有"required"选项的参数, 一次性列出所有缺失的参数
+type MissingError struct {
+	Keys []string // 缺失参数完整的key, 按字段的遍历顺序排列, 如"page.size"
+}
+
+func (e *MissingError) Error() string {
+	return fmt.Sprintf("%s: Missing required parameters: %s", pkgName, strings.Join(e.Keys, ", "))
+}
+
+// MergeError MergeToMap、EncodeInto在ConflictError策略下, 不同来源的相同key编码出不同的值, 一次性列出所有冲突的key
+type MergeError struct {
+	Keys []string // 冲突的key, 按字典序排列
+}
+
+func (e *MergeError) Error() string {
+	return fmt.Sprintf("%s: Conflicting values of keys: %s", pkgName, strings.Join(e.Keys, ", "))
+}
+
+// PanicError 开启WithRecover时, 由编码、解码过程中的panic转换而来的错误
+type PanicError struct {
+	Value interface{} // recover()的结果
+	Stack []byte      // 发生panic时的调用栈
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s: Recovered from panic, %v", pkgName, e.Value)
+}
+
+// recoverPanic 开启WithRecover时将panic转换为PanicError写入err, 需直接以defer调用
+func (p *FormParser) recoverPanic(err *error) {
+	if !p.recover {
+		return
+	}
+	if r := recover(); r != nil {
+		*err = &PanicError{Value: r, Stack: debug.Stack()}
+	}
+}
@@ -0,0 +1,104 @@
+package formparser
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFieldError(t *testing.T) {
+	type Leaf struct {
+		T time.Time `a:"t,tz=Nowhere/City"`
+	}
+	type Demo struct {
+		H []Leaf          `a:"h"`
+		M map[string]Leaf `a:"m"`
+		I Leaf            `a:"..."`
+	}
+	leaf := Leaf{T: time.Now()}
+	cases := []struct {
+		v     interface{}
+		opts  []Option
+		key   string
+		field string
+	}{
+		{Demo{H: []Leaf{leaf}}, nil, "h.0.t", "T"},
+		{Demo{M: map[string]Leaf{"k": leaf}}, []Option{WithKeyStyle(KeyStyleBracket)}, "m[k][t]", "T"},
+		{Demo{I: leaf}, []Option{WithRootKey("r")}, "r.t", "T"},
+	}
+	p := New("a", "-")
+	for _, c := range cases {
+		_, err := p.Encode(c.v, c.opts...)
+		var fe *FieldError
+		if !errors.As(err, &fe) {
+			t.Fatalf("Expect FieldError, got %v", err)
+		}
+		if fe.Key != c.key || fe.Field != c.field || fe.Type != reflect.TypeOf(Leaf{}) {
+			t.Fatalf("Got %+v, want key %q field %q", fe, c.key, c.field)
+		}
+		if _, _, err := p.EstimateSize(c.v, c.opts...); !errors.As(err, &fe) || fe.Key != c.key {
+			t.Fatalf("EstimateSize got %v, want key %q", err, c.key)
+		}
+	}
+
+	var v decodeDemo
+	err := p.DecodeMap(map[string]string{"h.1.cpu": "x", "f.cpu": "y", "x.5": "1"}, &v)
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Key != "x" || fe.Field != "X" || fe.Type != reflect.TypeOf(v) {
+		t.Fatalf("Got %v", err)
+	}
+	if want := `formparser: Decode failed, index 5 out of range [0, 2) (field formparser.decodeDemo.X, key "x")`; err.Error() != want {
+		t.Fatalf("Got %s, want %s", err, want)
+	}
+
+	// slice元素解码失败时Key为元素的key
+	err = p.DecodeMap(map[string]string{"e.1": "z"}, &v)
+	if !errors.As(err, &fe) || fe.Key != "e.1" || fe.Field != "E" || strings.Count(err.Error(), "e.1") != 1 {
+		t.Fatalf("Got %v", err)
+	}
+
+	if _, err := New("a", "-", WithMaxKVs(1)).Encode(struct {
+		H []Info `a:"h"`
+	}{[]Info{{CPU: StringPtr("1")}, {CPU: StringPtr("2")}}}); !errors.Is(err, ErrTooManyKVs) {
+		t.Fatalf("Expect ErrTooManyKVs, got %v", err)
+	}
+}
+
+type panicker struct{}
+
+func (panicker) MarshalBinary() ([]byte, error) {
+	panic("boom")
+}
+
+func (*panicker) UnmarshalBinary([]byte) error {
+	panic("boom")
+}
+
+func TestRecover(t *testing.T) {
+	type Demo struct {
+		P panicker `a:"p"`
+	}
+	p := New("a", "-", WithRecover(true))
+	checks := []func() error{
+		func() error { _, err := p.Encode(Demo{}); return err },
+		func() error { _, err := p.EncodeValue("p", panicker{}); return err },
+		func() error { _, _, err := p.EstimateSize(Demo{}); return err },
+		func() error { _, err := p.ToHeader(Demo{}); return err },
+		func() error { return p.DecodeMap(map[string]string{"p": "eA=="}, &Demo{}) },
+	}
+	for i, check := range checks {
+		var pe *PanicError
+		if err := check(); !errors.As(err, &pe) || pe.Value != "boom" || len(pe.Stack) == 0 {
+			t.Fatalf("Case %d: expect PanicError, got %v", i, err)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("Expect panic without WithRecover")
+		}
+	}()
+	New("a", "-").Encode(Demo{})
+}
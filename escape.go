@@ -0,0 +1,78 @@
+package formparser
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// QueryEscape 按WithSpaceAsPercent20、WithEscapeChars、WithKeepChars设置的规则对s做query转义,
+// 均未设置时与url.QueryEscape一致. 便于自定义的Signer使用与输出相同的规则计算签名
+func (p *FormParser) QueryEscape(s string, opts ...Option) string {
+	return p.with(opts).queryEscape(s)
+}
+
+func (p *FormParser) queryEscape(s string) string {
+	if !p.spacePercent20 && p.escapeChars == "" && p.keepChars == "" {
+		return url.QueryEscape(s)
+	}
+
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' && !p.spacePercent20 && strings.IndexByte(p.escapeChars, ' ') < 0:
+			b.WriteByte('+')
+		case unreserved(c) && strings.IndexByte(p.escapeChars, c) < 0,
+			strings.IndexByte(p.keepChars, c) >= 0:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+		}
+	}
+	return b.String()
+}
+
+// unreserved url.QueryEscape不转义的字符
+func unreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '_' || c == '.' || c == '~'
+}
+
+// encodeQuery 与url.Values.Encode一致按key排序拼接, 但使用queryEscape转义
+func (p *FormParser) encodeQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		key := p.queryEscape(k)
+		for _, v := range q[k] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(key)
+			b.WriteByte('=')
+			b.WriteString(p.queryEscape(v))
+		}
+	}
+	return b.String()
+}
+
+// checkEscapeChars 校验WithEscapeChars、WithKeepChars的参数, 只允许可打印的ASCII字符;
+// WithKeepChars还不允许保留会改变query结构或含义的字符
+func checkEscapeChars(name, chars string) {
+	for i := 0; i < len(chars); i++ {
+		if c := chars[i]; c < ' ' || c > '~' || (name == "WithKeepChars" && strings.IndexByte("% &=+#", c) >= 0) {
+			panic(fmt.Sprintf("%s: Invalid char %q for %s", pkgName, c, name))
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package formparser
+
+import (
+	"io"
+	"testing"
+)
+
+func TestQueryEscape(t *testing.T) {
+	const s = "a b~c,d:e/f"
+	cases := []struct {
+		opts []Option
+		want string
+	}{
+		{nil, "a+b~c%2Cd%3Ae%2Ff"},
+		{[]Option{WithSpaceAsPercent20(true)}, "a%20b~c%2Cd%3Ae%2Ff"},
+		{[]Option{WithEscapeChars("~")}, "a+b%7Ec%2Cd%3Ae%2Ff"},
+		{[]Option{WithKeepChars(",:/")}, "a+b~c,d:e/f"},
+		{[]Option{WithEscapeChars("~ "), WithKeepChars("~")}, "a%20b~c%2Cd%3Ae%2Ff"},
+	}
+	p := New("a", "-")
+	for i, c := range cases {
+		if got := p.QueryEscape(s, c.opts...); got != c.want {
+			t.Fatalf("Case %d: got %s, want %s", i, got, c.want)
+		}
+	}
+
+	for _, f := range []func(){
+		func() { WithKeepChars("%") },
+		func() { WithKeepChars(" ") },
+		func() { WithKeepChars("&") },
+		func() { WithKeepChars("=") },
+		func() { WithKeepChars("+") },
+		func() { WithKeepChars("#") },
+		func() { WithEscapeChars("\n") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("Expect panic")
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+func TestEscapePolicy(t *testing.T) {
+	type Demo struct {
+		B string `a:"b"`
+		A string `a:"a"`
+	}
+	v := Demo{B: "x y", A: "1,2~"}
+	p := New("a", "-", WithSpaceAsPercent20(true), WithEscapeChars("~"))
+
+	m, err := p.ToMap(valueOf(v), WithEscapeValues(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["b"] != "x%20y" || m["a"] != "1%2C2%7E" {
+		t.Fatalf("Got %v", m)
+	}
+
+	u, err := p.BuildURL("http://x/?c=3", v, WithKeepChars(","))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "http://x/?a=1,2%7E&b=x%20y&c=3"; u != want {
+		t.Fatalf("Got %s, want %s", u, want)
+	}
+
+	body, _, err := p.EncodeBody(v, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(body); string(b) != "b=x%20y&a=1%2C2%7E" {
+		t.Fatalf("Got %s", b)
+	}
+}
@@ -0,0 +1,161 @@
+package formparser
+
+import (
+	"reflect"
+	"strings"
+)
+
+// EstimateSize 计算v编码后的KV个数及字节数(所有key与value的长度之和), 但不生成编码结果,
+// 便于调用方在编码前拒绝过大的数据或改为分批提交.
+// 结果已计入WithTruncateValues的截断、WithOnlyPaths、WithExcludePaths的过滤及WithInterceptor的改写, 但不受WithMaxKVs、WithMaxValueLen的限制
+func (p *FormParser) EstimateSize(v interface{}, opts ...Option) (kvs int, bytes int, err error) {
+	p = p.with(opts)
+	defer p.recoverPanic(&err)
+	rv, tagK, err := p.rootValue(valueOf(v))
+	if err != nil {
+		return 0, 0, err
+	}
+	return p.estimate(rv, tagK, "")
+}
+
+// estimate 与encode的遍历方式一致, 只累计KV个数及字节数
+func (p *FormParser) estimate(v reflect.Value, tagK string, opts tagOptions) (n int, size int, err error) {
+	for {
+		if rt, ok, err := p.encodeMarshaler(v, tagK, opts); ok {
+			if err != nil {
+				return 0, 0, err
+			}
+			return p.estimateKVs(rt)
+		}
+		if v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface {
+			break
+		}
+		v = v.Elem()
+	}
+	if rt, ok, err := p.encodeStyle(v, tagK, opts); ok {
+		if err != nil {
+			return 0, 0, err
+		}
+		return p.estimateKVs(rt)
+	}
+	if rt, ok, err := p.encodeTagOption(v, tagK, opts); ok {
+		if err != nil {
+			return 0, 0, err
+		}
+		return p.estimateKVs(rt)
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return p.estimateStruct(v, tagK)
+	case reflect.Slice, reflect.Array:
+		return p.estimateSlice(v, tagK, opts)
+	case reflect.Map:
+		return p.estimateMap(v, tagK, opts)
+	}
+	rt, err := p.encode(v, tagK, opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	return p.estimateKVs(rt)
+}
+
+// estimateKVs 统计叶子节点编码得到的KV
+func (p *FormParser) estimateKVs(kvs []KV) (n int, size int, err error) {
+	keep := p.pathFilter()
+	for _, kv := range kvs {
+		if p.lowercaseKeys {
+			kv.K = strings.ToLower(kv.K)
+		}
+		if keep != nil && !keep(kv.K) {
+			continue
+		}
+		var ok bool
+		if kv, ok = p.intercept(kv); !ok {
+			continue
+		}
+		n++
+		if p.truncateValue {
+			if kv.V, err = p.limitValue(kv); err != nil {
+				return 0, 0, err
+			}
+		}
+		size += len(kv.K) + len(kv.V)
+	}
+	return n, size, nil
+}
+
+func (p *FormParser) estimateStruct(v reflect.Value, tagK string) (n int, size int, err error) {
+	if hasHooks(v) {
+		// AfterEncode可能任意修改结果, 只能实际编码
+		kvs, err := p.encodeFields(v, p.prefixOf(tagK))
+		if err != nil {
+			return 0, 0, err
+		}
+		return p.estimateKVs(kvs)
+	}
+	return p.estimateFields(v, p.prefixOf(tagK))
+}
+
+func (p *FormParser) estimateFields(v reflect.Value, prefix string) (n int, size int, err error) {
+	err = p.eachField(v, prefix, func(field reflect.Value, fieldK string, opts tagOptions) error {
+		fn, fsize, err := p.estimate(field, fieldK, opts)
+		n, size = n+fn, size+fsize
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return n, size, nil
+}
+
+func (p *FormParser) estimateSlice(v reflect.Value, tagK string, opts tagOptions) (n int, size int, err error) {
+	if kv, ok := p.encodeSliceValue(v, tagK, opts); ok {
+		return p.estimateKVs([]KV{kv})
+	}
+	idxFmt, err := parseIndexFormat(opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	s, err := p.sliceStrategy(v.Type(), opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	if s != SliceIndexed && s != SliceRepeated {
+		// 其余方式可能任意组合元素的结果, 只能实际编码
+		kvs, err := s.EncodeSlice(&SliceEncoder{p: p, opts: opts, idxFmt: idxFmt}, v, tagK)
+		if err != nil {
+			return 0, 0, err
+		}
+		return p.estimateKVs(kvs)
+	}
+	repeat := s == SliceRepeated
+	for i := 0; i < v.Len(); i++ {
+		elemK := tagK
+		if !repeat {
+			elemK = p.indexKey(tagK, i, idxFmt)
+		}
+		en, esize, err := p.estimate(v.Index(i), elemK, opts)
+		if err != nil {
+			return 0, 0, err
+		}
+		n, size = n+en, size+esize
+	}
+	return n, size, nil
+}
+
+func (p *FormParser) estimateMap(v reflect.Value, tagK string, opts tagOptions) (n int, size int, err error) {
+	keys, err := p.mapKeys(v)
+	if err != nil {
+		return 0, 0, err
+	}
+	prefix := p.prefixOf(tagK)
+	for _, key := range keys {
+		vn, vsize, err := p.estimate(v.MapIndex(key.v), p.joinKey(prefix, key.s), opts)
+		if err != nil {
+			return 0, 0, err
+		}
+		n, size = n+vn, size+vsize
+	}
+	return n, size, nil
+}
@@ -0,0 +1,67 @@
+package formparser
+
+import (
+	"testing"
+)
+
+func TestEstimateSize(t *testing.T) {
+	type Nested struct {
+		M map[string]interface{} `a:"m"`
+		S []Info                 `a:"s,idxfmt=.member.%d"`
+		P []int                  `a:"p,index_pad=2"`
+		N []map[string]int       `a:"..."`
+		T string                 `a:"t"`
+	}
+	n := Nested{
+		M: map[string]interface{}{"x": []int{1, 2}, "y": Info{CPU: StringPtr("8核")}, "z": map[string]string{"k": "v"}},
+		S: []Info{{CPU: StringPtr("1核")}, {}},
+		P: []int{1, 2, 3},
+		N: []map[string]int{{"a": 1}, {"b": 2}},
+		T: "0123456789",
+	}
+	cases := []struct {
+		v    interface{}
+		opts []Option
+	}{
+		{h, nil},
+		{&h, []Option{WithKeyStyle(KeyStyleBracket)}},
+		{n, nil},
+		{n, []Option{WithKeyStyle(KeyStyleBracket), WithIndexBase(1)}},
+		{n, []Option{WithTruncateValues(4, "..")}},
+		{n, []Option{WithRootKey("root")}},
+		{[]Info{{CPU: StringPtr("1核")}}, nil},
+		{map[string][]int{"a": {1, 2}}, []Option{WithRootKey("r"), WithKeyStyle(KeyStyleBracket)}},
+	}
+	p := New("a", "-")
+	for i, c := range cases {
+		kvs, err := p.Encode(c.v, c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantBytes := 0
+		for _, kv := range kvs {
+			wantBytes += len(kv.K) + len(kv.V)
+		}
+		gotKVs, gotBytes, err := p.EstimateSize(c.v, c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotKVs != len(kvs) || gotBytes != wantBytes {
+			t.Fatalf("Case %d: got (%d, %d), want (%d, %d) from %v", i, gotKVs, gotBytes, len(kvs), wantBytes, kvs)
+		}
+	}
+}
+
+func TestEstimateSizeIgnoresLimits(t *testing.T) {
+	p := New("a", "-", WithMaxKVs(1), WithMaxValueLen(1))
+	kvs, _, err := p.EstimateSize(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kvs <= 1 {
+		t.Fatalf("Got %d KVs, expect more than the limit", kvs)
+	}
+	if _, _, err := p.EstimateSize(1); err == nil {
+		t.Fatal("Expect error for invalid param")
+	}
+}
@@ -0,0 +1,205 @@
+package formparser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ToFlatJSON 将编码结果输出为JSON对象, 如{"h.0.cpu":"1核"}, key按字典序排列, 不转义HTML字符.
+// 编码结果中重复的key(如repeat选项、style=form)输出为字符串数组, 如{"id":["3","4"]}
+func (p *FormParser) ToFlatJSON(v interface{}, opts ...Option) ([]byte, error) {
+	p = p.with(opts)
+	kvs, err := p.marshal(valueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		if p.escapeValues {
+			kv.V = p.queryEscape(kv.V)
+		}
+		switch old := m[kv.K].(type) {
+		case nil:
+			m[kv.K] = kv.V
+		case string:
+			m[kv.K] = []string{old, kv.V}
+		case []string:
+			m[kv.K] = append(old, kv.V)
+		}
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// ToProperties 将ToMap的结果按key的字典序逐行写入w, 格式为Java的.properties("key=value"),
+// 也可以作为容器的env文件使用. key中的空格、":"、"="、"#"、"!"以及换行等控制字符按.properties的规则以"\\"转义,
+// 非ASCII字符按UTF-8原样输出
+func (p *FormParser) ToProperties(w io.Writer, v interface{}, opts ...Option) error {
+	m, err := p.ToMap(valueOf(v), opts...)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(escapeProperty(k, true))
+		b.WriteByte('=')
+		b.WriteString(escapeProperty(m[k], false))
+		b.WriteByte('\n')
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// escapeProperty 按.properties的规则转义key或value. value中只有开头的空格需要转义
+func escapeProperty(s string, key bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\f':
+			b.WriteString(`\f`)
+		case ' ':
+			if key || i == 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		case ':', '=', '#', '!':
+			if key {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// FromProperties 读取.properties(或简单的env文件)格式的数据并解码到dst中, 是ToProperties的逆过程, 参见Decode.
+// 支持"#"、"!"开头的注释, 以"="、":"或空白分隔key与value, 行末的"\\"续行以及"\\uXXXX"等转义; 重复的key以最后一个为准
+func (p *FormParser) FromProperties(r io.Reader, dst interface{}) error {
+	values := make(url.Values)
+	sc := bufio.NewScanner(r)
+	var logical string
+	for line := 1; sc.Scan(); line++ {
+		s := strings.TrimLeft(sc.Text(), " \t\f")
+		if logical == "" && (s == "" || s[0] == '#' || s[0] == '!') {
+			continue
+		}
+		// 行末奇数个"\\"表示续行
+		if n := len(s) - len(strings.TrimRight(s, `\`)); n%2 == 1 {
+			logical += s[:len(s)-1]
+			continue
+		}
+		logical += s
+		k, v := splitProperty(logical)
+		logical = ""
+		key, err := unescapeProperty(k)
+		if err != nil {
+			return fmt.Errorf("%s: Invalid key at line %d, %v", pkgName, line, err)
+		}
+		value, err := unescapeProperty(v)
+		if err != nil {
+			return fmt.Errorf("%s: Invalid value of key %q at line %d, %v", pkgName, key, line, err)
+		}
+		values.Set(key, value)
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return p.Decode(values, dst)
+}
+
+// splitProperty 以第一个未转义的"="、":"或空白拆分key与value, 分隔符两侧的空白被忽略
+func splitProperty(s string) (key, value string) {
+	i := 0
+	for i < len(s) && strings.IndexByte("=: \t\f", s[i]) < 0 {
+		if s[i] == '\\' {
+			i++
+		}
+		i++
+	}
+	if i >= len(s) {
+		return s, ""
+	}
+	rest := strings.TrimLeft(s[i:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = rest[1:]
+	}
+	return s[:i], strings.TrimLeft(rest, " \t\f")
+}
+
+// unescapeProperty 是escapeProperty的逆过程, 另外支持"\\uXXXX"
+func unescapeProperty(s string) (string, error) {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", errors.New("Malformed \\u escape")
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("Malformed \\u escape %q", s[i-1:i+5])
+			}
+			i += 4
+			// UTF-16代理对, 如"\uD83D\uDE00"
+			if utf16.IsSurrogate(rune(r)) && i+6 < len(s) && s[i+1] == '\\' && s[i+2] == 'u' {
+				if r2, err := strconv.ParseUint(s[i+3:i+7], 16, 16); err == nil {
+					if c := utf16.DecodeRune(rune(r), rune(r2)); c != utf8.RuneError {
+						b.WriteRune(c)
+						i += 6
+						continue
+					}
+				}
+			}
+			b.WriteRune(rune(r))
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
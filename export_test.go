@@ -0,0 +1,116 @@
+package formparser
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToFlatJSON(t *testing.T) {
+	p := New("a", "-")
+	b, err := p.ToFlatJSON(&h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	want, err := p.ToMap(reflect.ValueOf(h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+
+	type Demo struct {
+		B string `a:"b"`
+		A []int  `a:"a"`
+	}
+	if b, err = p.ToFlatJSON(Demo{B: "<\"x\">", A: []int{1}}, WithRootKey("r")); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"r.a.0":"1","r.b":"<\"x\">"}`; string(b) != want {
+		t.Fatalf("Got %s, want %s", b, want)
+	}
+	type Repeated struct {
+		Tags []string `a:"tags,repeat"`
+	}
+	if b, err = p.ToFlatJSON(Repeated{Tags: []string{"x", "y"}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"tags":["x","y"]}`; string(b) != want {
+		t.Fatalf("Got %s, want %s", b, want)
+	}
+	if _, err := p.ToFlatJSON(1); err == nil {
+		t.Fatal("Expect error for invalid param")
+	}
+}
+
+func TestToProperties(t *testing.T) {
+	type Demo struct {
+		B string            `a:"b"`
+		A []string          `a:"a"`
+		M map[string]string `a:"m"`
+	}
+	v := Demo{B: " lead\ttab\\", A: []string{"x=y", "中文"}, M: map[string]string{"k 1:#!": "line1\nline2"}}
+	var buf strings.Builder
+	if err := New("a", "-").ToProperties(&buf, v); err != nil {
+		t.Fatal(err)
+	}
+	want := "a.0=x=y\n" +
+		"a.1=中文\n" +
+		"b=\\ lead\\ttab\\\\\n" +
+		"m.k\\ 1\\:\\#\\!=line1\\nline2\n"
+	if buf.String() != want {
+		t.Fatalf("Got %q, want %q", buf.String(), want)
+	}
+	if err := New("a", "-").ToProperties(&buf, 1); err == nil {
+		t.Fatal("Expect error for invalid param")
+	}
+}
+
+func TestFromProperties(t *testing.T) {
+	type Demo struct {
+		B string            `a:"b"`
+		A []string          `a:"a"`
+		M map[string]string `a:"m"`
+		I interface{}       `a:"i"`
+	}
+	p := New("a", "-")
+	src := Demo{B: " lead\ttab\\", A: []string{"x=y", "中文"}}
+	var buf strings.Builder
+	if err := p.ToProperties(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+	var dst Demo
+	if err := p.FromProperties(strings.NewReader(buf.String()), &dst); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dst, src) {
+		t.Fatalf("Got %+v, want %+v", dst, src)
+	}
+
+	input := "# comment\n" +
+		"  ! another\n" +
+		"\n" +
+		"b = first\n" +
+		"b:second \\\n" +
+		"    line\n" +
+		"a.0 \\u4e2d\\uD83D\\uDE00\n" +
+		"i.k\\ 1=v\n" +
+		"i.empty\n"
+	dst = Demo{}
+	if err := p.FromProperties(strings.NewReader(input), &dst); err != nil {
+		t.Fatal(err)
+	}
+	want := Demo{B: "second line", A: []string{"中😀"}, I: map[string]interface{}{"k 1": "v", "empty": ""}}
+	if !reflect.DeepEqual(dst, want) {
+		t.Fatalf("Got %+v, want %+v", dst, want)
+	}
+	if err := p.FromProperties(strings.NewReader("b=\\u12"), &dst); err == nil {
+		t.Fatal("Expect error for malformed escape")
+	}
+}
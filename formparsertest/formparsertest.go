@@ -0,0 +1,147 @@
+// Package formparsertest 提供锁定formparser编码结果的测试辅助函数
+package formparsertest
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	formparser "github.com/Hurricanezwf/form-parser"
+)
+
+var update = flag.Bool("update-golden", false, "rewrite the golden files of formparsertest.AssertEncoding")
+
+// AssertEncoding 以p.Encode编码v并按key稳定排序, 与goldenFile中的内容逐行比较, 不一致时报告新增、删除、修改的KV.
+// 同名key的多个值(如repeat选项、style=form)全部保留并按原有顺序比较.
+// 以-update-golden运行测试时改为将编码结果写入goldenFile
+func AssertEncoding(t testing.TB, p *formparser.FormParser, v interface{}, goldenFile string, opts ...formparser.Option) {
+	t.Helper()
+	kvs, err := p.Encode(v, opts...)
+	if err != nil {
+		t.Fatalf("Encode failed, %v", err)
+		return
+	}
+	sort.SliceStable(kvs, func(i, j int) bool { return kvs[i].K < kvs[j].K })
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(goldenFile), 0755); err != nil {
+			t.Fatalf("Create dir of golden file failed, %v", err)
+			return
+		}
+		if err := os.WriteFile(goldenFile, Dump(kvs), 0644); err != nil {
+			t.Fatalf("Write golden file failed, %v", err)
+		}
+		return
+	}
+
+	b, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatalf("Read golden file failed, %v (run with -update-golden to create it)", err)
+		return
+	}
+	want, err := Parse(b)
+	if err != nil {
+		t.Fatalf("Parse golden file %s failed, %v", goldenFile, err)
+		return
+	}
+	if reflect.DeepEqual(want, kvs) || len(want) == 0 && len(kvs) == 0 {
+		return
+	}
+	changes := diff(want, kvs)
+	if len(changes) == 0 { // KV相同, 仅同名key的值顺序不同
+		changes = []string{"order of repeated values changed"}
+	}
+	t.Errorf("Encoding differs from %s:\n%s", goldenFile, strings.Join(changes, "\n"))
+}
+
+// diff 逐个比较want与got中的KV, 返回按key排序的变化: 同一个key上被替换的值为"~", 其余为"+"或"-"
+func diff(want, got []formparser.KV) []string {
+	var changes []formparser.Change
+	pending := make(map[string][]int) // 各key尚未与新增配对的删除在changes中的位置
+	for _, kv := range subtract(want, got) {
+		pending[kv.K] = append(pending[kv.K], len(changes))
+		changes = append(changes, formparser.Change{Kind: formparser.ChangeRemoved, Key: kv.K, Old: kv.V})
+	}
+	for _, kv := range subtract(got, want) {
+		if idx := pending[kv.K]; len(idx) > 0 {
+			changes[idx[0]].Kind, changes[idx[0]].New = formparser.ChangeModified, kv.V
+			pending[kv.K] = idx[1:]
+			continue
+		}
+		changes = append(changes, formparser.Change{Kind: formparser.ChangeAdded, Key: kv.K, New: kv.V})
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	lines := make([]string, len(changes))
+	for i, c := range changes {
+		lines[i] = c.String()
+	}
+	return lines
+}
+
+// subtract 返回a中去掉b之后剩余的KV, 相同的KV按出现次数抵消
+func subtract(a, b []formparser.KV) []formparser.KV {
+	n := make(map[formparser.KV]int, len(b))
+	for _, kv := range b {
+		n[kv]++
+	}
+	var rt []formparser.KV
+	for _, kv := range a {
+		if n[kv] > 0 {
+			n[kv]--
+			continue
+		}
+		rt = append(rt, kv)
+	}
+	return rt
+}
+
+// Dump 每行输出一个`key="value"`, value以strconv.Quote转义. kvs应已按key稳定排序, 同名key的多个值各占一行
+func Dump(kvs []formparser.KV) []byte {
+	var buf bytes.Buffer
+	for _, kv := range kvs {
+		buf.WriteString(kv.K + "=" + strconv.Quote(kv.V) + "\n")
+	}
+	return buf.Bytes()
+}
+
+// Parse 解析Dump的输出, 忽略空行
+func Parse(b []byte) ([]formparser.KV, error) {
+	var kvs []formparser.KV
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for line := 1; sc.Scan(); line++ {
+		s := sc.Text()
+		if s == "" {
+			continue
+		}
+		k, v, err := parseLine(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		kvs = append(kvs, formparser.KV{K: k, V: v})
+	}
+	return kvs, sc.Err()
+}
+
+// parseLine 解析一行`key="value"`. 带引号的value中的`"`都已转义, 因此从左往右第一个使其余部分成为完整带引号字符串的`="`
+// 即为key与value的分界, value以"="结尾(如base64的填充)或key中含有`="`时都能正确拆分
+func parseLine(s string) (key, value string, err error) {
+	err = fmt.Errorf("missing quoted value")
+	for i := 0; i < len(s); i++ {
+		j := strings.Index(s[i:], "=\"")
+		if j < 0 {
+			break
+		}
+		i += j
+		if value, err = strconv.Unquote(s[i+1:]); err == nil {
+			return s[:i], value, nil
+		}
+	}
+	return "", "", err
+}
@@ -0,0 +1,107 @@
+package formparsertest
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	formparser "github.com/Hurricanezwf/form-parser"
+)
+
+type demo struct {
+	A string `a:"a"`
+	B []int  `a:"b"`
+	C string `a:"c"`
+}
+
+// recorder 记录AssertEncoding报告的失败
+type recorder struct {
+	testing.TB
+	errs []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+}
+
+func TestAssertEncoding(t *testing.T) {
+	p := formparser.New("a", "-")
+	v := demo{A: "x y", B: []int{1, 2}, C: "\"q\"\n"}
+	AssertEncoding(t, p, v, "testdata/demo.golden")
+
+	r := &recorder{TB: t}
+	v.B = v.B[:1]
+	AssertEncoding(r, p, v, "testdata/demo.golden")
+	if want := []string{"Encoding differs from testdata/demo.golden:\n- b.1=2"}; !reflect.DeepEqual(r.errs, want) {
+		t.Fatalf("Got %q, want %q", r.errs, want)
+	}
+
+	// 同名key的每个值都参与比较
+	type repeated struct {
+		ID []int `a:"id,repeat"`
+	}
+	AssertEncoding(t, p, repeated{[]int{3, 4}}, "testdata/repeated.golden")
+	for _, c := range []struct {
+		ids  []int
+		want string
+	}{
+		{[]int{9, 4}, "~ id=3 -> 9"},
+		{[]int{3, 4, 5}, "+ id=5"},
+		{[]int{4, 3}, "order of repeated values changed"},
+	} {
+		r = &recorder{TB: t}
+		AssertEncoding(r, p, repeated{c.ids}, "testdata/repeated.golden")
+		if want := []string{"Encoding differs from testdata/repeated.golden:\n" + c.want}; !reflect.DeepEqual(r.errs, want) {
+			t.Fatalf("Got %q, want %q", r.errs, want)
+		}
+	}
+
+	r = &recorder{TB: t}
+	AssertEncoding(r, p, v, "testdata/missing.golden")
+	if len(r.errs) != 1 {
+		t.Fatalf("Expect error for missing golden file, got %q", r.errs)
+	}
+}
+
+func TestDumpParse(t *testing.T) {
+	kvs := []formparser.KV{{K: "a", V: "中文"}, {K: "b", V: "a=\"b"}, {K: "b", V: "x"}, {K: "c[0]", V: ""}, {K: "d=\"", V: "x"}}
+	want := "a=\"中文\"\nb=\"a=\\\"b\"\nb=\"x\"\nc[0]=\"\"\nd=\"=\"x\"\n"
+	if got := string(Dump(kvs)); got != want {
+		t.Fatalf("Got %s, want %s", got, want)
+	}
+	got, err := Parse(Dump(kvs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, kvs) {
+		t.Fatalf("Got %v, want %v", got, kvs)
+	}
+
+	// []byte默认按base64编码, 填充的"="位于value末尾
+	type padded struct {
+		J []byte `a:"j"`
+		K string `a:"k="`
+	}
+	enc, err := formparser.New("a", "-").Encode(padded{J: []byte("Go"), K: "=\"="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enc[0].V != "R28=" {
+		t.Fatalf("Got %q, expect padded base64", enc[0].V)
+	}
+	if got, err = Parse(Dump(enc)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, enc) {
+		t.Fatalf("Got %v, want %v", got, enc)
+	}
+	if _, err := Parse([]byte("a=1\n")); err == nil {
+		t.Fatal("Expect error for unquoted value")
+	}
+}
@@ -0,0 +1,14 @@
+// formtag 检查formparser的struct标签, 可单独运行或作为go vet的工具:
+//
+//	go install github.com/Hurricanezwf/form-parser/formtag/cmd/formtag
+//	go vet -vettool=$(which formtag) ./...
+package main
+
+import (
+	"github.com/Hurricanezwf/form-parser/formtag"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(formtag.Analyzer)
+}
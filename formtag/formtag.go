@@ -0,0 +1,296 @@
+// Package formtag 提供检查formparser标签的analysis.Analyzer, 可通过go vet -vettool使用,
+// 在编译期发现无效的选项、struct内重复的key、不支持的字段类型以及"..."的误用
+package formtag
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "formtag",
+	Doc:      "check formparser struct tags for invalid options, duplicate keys, unsupported kinds and misuse of inline keywords",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+var (
+	tagName    string // 标签名, 同formparser.New的tag
+	ignoreFlag string // 忽略字段的标志, 同formparser.New的ignoreFlag
+	inlineKeys string // 以逗号分隔的内联关键字, 同WithInlineKeywords
+	customOpts string // 以逗号分隔的自定义选项, 同RegisterTagOption, 带不带值均可
+)
+
+func init() {
+	Analyzer.Flags.StringVar(&tagName, "tag", "zwf", "struct tag name")
+	Analyzer.Flags.StringVar(&ignoreFlag, "ignore", "-", "tag value that skips a field")
+	Analyzer.Flags.StringVar(&inlineKeys, "inline", "...", "comma separated inline keywords")
+	Analyzer.Flags.StringVar(&customOpts, "options", "", "comma separated custom options registered with RegisterTagOption")
+}
+
+// flagOptions 不带值的选项, valueOptions 形如k=v的选项
+var (
+	flagOptions  = map[string]bool{"omitempty": true, "omitzero": true, "join": true, "runes": true, "raw": true, "repeat": true, "required": true, "sensitive": true, "inline": true, "flatten": true}
+	valueOptions = map[string]bool{"alias": true, "accept": true, "omitunless": true, "format": true, "encoder": true, "idxfmt": true, "index_pad": true, "in": true, "tz": true, "default": true, "layouts": true, "sensitive": true, "slice": true, "style": true, "explode": true}
+)
+
+// field 带有标签的字段
+type field struct {
+	node   *ast.Field
+	name   string // Go字段名
+	key    string // 标签中的key
+	opts   []string
+	typ    types.Type
+	inline bool
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inline := make(map[string]bool)
+	for _, k := range strings.Split(inlineKeys, ",") {
+		inline[k] = true
+	}
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	insp.Preorder([]ast.Node{(*ast.StructType)(nil)}, func(n ast.Node) {
+		st := n.(*ast.StructType)
+		s, ok := pass.TypesInfo.TypeOf(st).(*types.Struct)
+		if !ok {
+			return
+		}
+		checkStruct(pass, st, s, inline)
+	})
+	return nil, nil
+}
+
+func checkStruct(pass *analysis.Pass, st *ast.StructType, s *types.Struct, inline map[string]bool) {
+	// types.Struct的字段与ast中的字段名一一对应
+	var nodes []*ast.Field
+	for _, f := range st.Fields.List {
+		nodes = append(nodes, f)
+		for i := 1; i < len(f.Names); i++ {
+			nodes = append(nodes, f)
+		}
+	}
+	// 只检查带有标签的struct; 其中未设置标签的导出字段同样会以字段名编码, 一并检查
+	tagged := false
+	for i := 0; i < s.NumFields() && !tagged; i++ {
+		_, tagged = reflect.StructTag(s.Tag(i)).Lookup(tagName)
+	}
+	if !tagged {
+		return
+	}
+	var fields []field
+	for i := 0; i < s.NumFields(); i++ {
+		v := s.Field(i)
+		tag := reflect.StructTag(s.Tag(i)).Get(tagName)
+		if !v.Exported() || tag == ignoreFlag {
+			continue
+		}
+		f := field{node: nodes[i], name: v.Name(), key: tag, typ: v.Type()}
+		if j := strings.Index(tag, ","); j >= 0 {
+			f.key, f.opts = tag[:j], strings.Split(tag[j+1:], ",")
+		}
+		if f.key == "" {
+			f.key = v.Name()
+		}
+		f.inline = inline[f.key]
+		for _, opt := range f.opts {
+			if opt == "inline" || opt == "flatten" {
+				f.key, f.inline = opt, true
+			}
+		}
+		fields = append(fields, f)
+	}
+
+	seen := make(map[string]string)
+	for _, f := range fields {
+		checkOptions(pass, f, fields)
+		checkKind(pass, f)
+		if f.inline {
+			if !canInline(f.typ) {
+				pass.Reportf(f.node.Pos(), "inline keyword %q has no effect on field %s of type %s", f.key, f.name, f.typ)
+			}
+			continue
+		}
+		for _, key := range append([]string{f.key}, aliases(f)...) {
+			if other, dup := seen[key]; dup {
+				pass.Reportf(f.node.Pos(), "duplicate key %q in field %s, already used by field %s", key, f.name, other)
+				continue
+			}
+			seen[key] = f.name
+		}
+	}
+}
+
+// isCustomOption 判断name是否为通过-options指定的自定义选项
+func isCustomOption(name string) bool {
+	for _, opt := range strings.Split(customOpts, ",") {
+		if opt != "" && opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+func checkOptions(pass *analysis.Pass, f field, siblings []field) {
+	for _, opt := range f.opts {
+		name, value, hasValue := strings.Cut(opt, "=")
+		switch {
+		case flagOptions[name] && !hasValue, valueOptions[name] && hasValue:
+		case flagOptions[name]:
+			pass.Reportf(f.node.Pos(), "option %q of field %s does not take a value", name, f.name)
+			continue
+		case valueOptions[name]:
+			pass.Reportf(f.node.Pos(), "option %q of field %s needs a value", name, f.name)
+			continue
+		case isCustomOption(name):
+			continue
+		default:
+			pass.Reportf(f.node.Pos(), "unknown option %q in field %s", opt, f.name)
+			continue
+		}
+		if msg := checkOption(f, name, value, siblings); msg != "" {
+			pass.Reportf(f.node.Pos(), "invalid option %q in field %s: %s", opt, f.name, msg)
+		}
+	}
+}
+
+// checkOption 校验单个选项, 返回空串表示有效
+func checkOption(f field, name, value string, siblings []field) string {
+	switch name {
+	case "join":
+		if sl, ok := deref(f.typ).Underlying().(*types.Slice); !ok || !isString(sl.Elem()) {
+			return "join only applies to []string"
+		}
+	case "idxfmt":
+		if !validIndexFormat(value) {
+			return "exactly one %d or zero padded %0Nd is needed"
+		}
+	case "index_pad":
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return "a non-negative integer is needed"
+		}
+	case "in":
+		if value != "path" && value != "header" {
+			return "path or header is needed"
+		}
+	case "style":
+		if value != "form" && value != "deepObject" {
+			return "form or deepObject is needed"
+		}
+	case "explode":
+		if value != "true" && value != "false" {
+			return "true or false is needed"
+		}
+	case "slice":
+		if value == "" {
+			return "strategy name is needed"
+		}
+	case "sensitive":
+		if value != "" && value != "mask" && value != "drop" {
+			return "mask or drop is needed"
+		}
+	case "omitunless":
+		ref, _, ok := strings.Cut(value, "=")
+		if !ok || ref == "" {
+			return "Field=value is needed"
+		}
+		for _, s := range siblings {
+			if s.key == ref || s.name == ref {
+				return ""
+			}
+		}
+		return fmt.Sprintf("field %q is not found", ref)
+	}
+	return ""
+}
+
+// checkKind 报告formparser无法编码的字段类型
+func checkKind(pass *analysis.Pass, f field) {
+	if t := unsupported(f.typ, 0); t != nil {
+		pass.Reportf(f.node.Pos(), "field %s has unsupported type %s", f.name, t)
+	}
+}
+
+// unsupported 返回t中无法编码的类型, 实现了方法的命名类型可能通过RegisterType等方式编码, 不做报告
+func unsupported(t types.Type, depth int) types.Type {
+	if depth > 8 {
+		return nil
+	}
+	if _, named := t.(*types.Named); named && types.NewMethodSet(types.NewPointer(t)).Len() > 0 {
+		return nil
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Chan, *types.Signature:
+		return t
+	case *types.Pointer:
+		return unsupported(u.Elem(), depth+1)
+	case *types.Slice:
+		return unsupported(u.Elem(), depth+1)
+	case *types.Array:
+		return unsupported(u.Elem(), depth+1)
+	case *types.Map:
+		return unsupported(u.Elem(), depth+1)
+	}
+	return nil
+}
+
+// canInline 判断类型是否能内联: struct、map、slice、array及interface(动态类型)
+func canInline(t types.Type) bool {
+	switch deref(t).Underlying().(type) {
+	case *types.Struct, *types.Map, *types.Slice, *types.Array, *types.Interface:
+		return true
+	}
+	return false
+}
+
+// aliases 返回alias及accept选项声明的别名
+func aliases(f field) []string {
+	var rt []string
+	for _, opt := range f.opts {
+		for _, prefix := range []string{"alias=", "accept="} {
+			if v, ok := strings.CutPrefix(opt, prefix); ok && v != "" {
+				rt = append(rt, strings.Split(v, "|")...)
+			}
+		}
+	}
+	return rt
+}
+
+func deref(t types.Type) types.Type {
+	for {
+		p, ok := t.Underlying().(*types.Pointer)
+		if !ok {
+			return t
+		}
+		t = p.Elem()
+	}
+}
+
+func isString(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsString != 0
+}
+
+// validIndexFormat 与formparser的idxfmt校验一致, 只接受含有唯一的"%d"或补零的"%0Nd"的格式
+func validIndexFormat(s string) bool {
+	if strings.Count(s, "%") != 1 {
+		return false
+	}
+	rest := s[strings.IndexByte(s, '%')+1:]
+	if strings.HasPrefix(rest, "0") {
+		width := strings.TrimLeft(rest[1:], "0123456789")
+		if len(width) == len(rest)-1 {
+			return false
+		}
+		rest = width
+	}
+	return strings.HasPrefix(rest, "d")
+}
@@ -0,0 +1,19 @@
+package formtag
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "a")
+}
+
+func TestAnalyzerCustomOptions(t *testing.T) {
+	if err := Analyzer.Flags.Set("options", "trim,mask"); err != nil {
+		t.Fatal(err)
+	}
+	defer Analyzer.Flags.Set("options", "")
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "b")
+}
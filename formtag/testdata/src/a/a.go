@@ -0,0 +1,62 @@
+package a
+
+import "unsafe"
+
+type Info struct {
+	CPU string `zwf:"cpu"`
+}
+
+type Good struct {
+	A  string   `zwf:"a,omitempty"`
+	B  []string `zwf:"b,join"`
+	C  []int    `zwf:"c,idxfmt=[%d],index_pad=2"`
+	D  Info     `zwf:"..."`
+	E  string   `zwf:"e,alias=ee|eee,accept=eeee"`
+	F  string   `zwf:"f,omitunless=a=x"`
+	G  string   `zwf:"g,in=header,sensitive"`
+	H  chan int `zwf:"-"`
+	I  int
+	_  int            `zwf:"i"`
+	j  int            `zwf:"a"`
+	Ka int            `zwf:",omitzero"`
+	L  Info           `zwf:",inline"`
+	M  map[string]int `zwf:"m,flatten"`
+}
+
+type Bad struct {
+	A string         `zwf:"a,omitemtpy"`   // want `unknown option "omitemtpy" in field A`
+	B []int          `zwf:"b,join"`        // want `invalid option "join" in field B: join only applies to \[\]string`
+	C []int          `zwf:"c,idxfmt=%d%d"` // want `invalid option "idxfmt=%d%d" in field C`
+	D int            `zwf:"..."`           // want `inline keyword "..." has no effect on field D of type int`
+	E string         `zwf:"a"`             // want `duplicate key "a" in field E, already used by field A`
+	F string         `zwf:"f,alias=b"`     // want `duplicate key "b" in field F, already used by field B`
+	G chan int       `zwf:"g"`             // want `field G has unsupported type chan int`
+	H func()         `zwf:"h"`             // want `field H has unsupported type func\(\)`
+	I unsafe.Pointer `zwf:"i"`
+	J []chan int     `zwf:"j"`                // want `field J has unsupported type chan int`
+	K string         `zwf:"k,in=body"`        // want `invalid option "in=body" in field K: path or header is needed`
+	L string         `zwf:"l,omitunless=x"`   // want `invalid option "omitunless=x" in field L: Field=value is needed`
+	M string         `zwf:"m,omitempty=1"`    // want `option "omitempty" of field M does not take a value`
+	N string         `zwf:"n,format"`         // want `option "format" of field N needs a value`
+	O string         `zwf:"o,omitunless=z=1"` // want `invalid option "omitunless=z=1" in field O: field "z" is not found`
+	P int            `zwf:",inline"`          // want `inline keyword "inline" has no effect on field P of type int`
+	Q string         `zwf:"q,accept=c"`       // want `duplicate key "c" in field Q, already used by field C`
+	R string         `zwf:"r,sensitive=x"`    // want `invalid option "sensitive=x" in field R: mask or drop is needed`
+	S []string       `zwf:"s,slice="`         // want `invalid option "slice=" in field S: strategy name is needed`
+	T []string       `zwf:"t,style=label"`    // want `invalid option "style=label" in field T: form or deepObject is needed`
+	U []string       `zwf:"u,explode=no"`     // want `invalid option "explode=no" in field U: true or false is needed`
+	V []int          `zwf:"v,idxfmt=.%c"`     // want `invalid option "idxfmt=.%c" in field V: exactly one %d or zero padded %0Nd is needed`
+	W []int          `zwf:"w,idxfmt=.%x"`     // want `invalid option "idxfmt=.%x" in field W`
+}
+
+type Untagged struct {
+	A string   `zwf:"B"`
+	B string   // want `duplicate key "B" in field B, already used by field A`
+	C chan int // want `field C has unsupported type chan int`
+	d chan int
+}
+
+// 没有任何标签的struct不是formparser的参数, 不做检查
+type Plain struct {
+	C chan int
+}
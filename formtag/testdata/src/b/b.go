@@ -0,0 +1,7 @@
+package b
+
+type Custom struct {
+	A string `zwf:"a,trim"`
+	B string `zwf:"b,mask=4"`
+	C string `zwf:"c,upper"` // want `unknown option "upper" in field C`
+}
@@ -0,0 +1,36 @@
+package formparser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+)
+
+// Hash 计算v编码结果的摘要(sha256的hex串), 可用作幂等key、请求去重或缓存key.
+// 摘要基于按key排序的KV列表, 与字段、map的遍历顺序无关; 同名key的多个值保持原有顺序
+func (p *FormParser) Hash(v interface{}, opts ...Option) (string, error) {
+	kvs, err := p.canonicalKVs(v, opts)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, kv := range kvs {
+		// 转义后"="、"&"不会出现在key与value中, 保证不同的KV列表不会拼出同样的内容
+		h.Write([]byte(url.QueryEscape(kv.K)))
+		h.Write([]byte{'='})
+		h.Write([]byte(url.QueryEscape(kv.V)))
+		h.Write([]byte{'&'})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// canonicalKVs 编码v并按key稳定排序
+func (p *FormParser) canonicalKVs(v interface{}, opts []Option) ([]KV, error) {
+	kvs, err := p.Encode(v, opts...)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(kvs, func(i, j int) bool { return kvs[i].K < kvs[j].K })
+	return kvs, nil
+}
@@ -0,0 +1,43 @@
+package formparser
+
+import (
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	type A struct {
+		X string `a:"x"`
+		Y []int  `a:"y"`
+	}
+	type B struct {
+		Y []int  `a:"y"`
+		X string `a:"x"`
+	}
+	p := New("a", "-")
+	ha, err := p.Hash(A{X: "1", Y: []int{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ha) != 64 {
+		t.Fatalf("Got %q, expect a sha256 hex digest", ha)
+	}
+	// 字段顺序不影响结果
+	if hb, _ := p.Hash(&B{X: "1", Y: []int{1, 2}}); hb != ha {
+		t.Fatalf("Got %q, want %q", hb, ha)
+	}
+	if hm, _ := p.Hash(map[string]string{"y.1": "2", "x": "1", "y.0": "1"}); hm != ha {
+		t.Fatalf("Got %q, want %q", hm, ha)
+	}
+	for _, v := range []interface{}{
+		A{X: "1", Y: []int{2, 1}},
+		A{X: "1=", Y: []int{1, 2}},
+		map[string]string{"x": "1&y.0=1", "y.1": "2"},
+	} {
+		if h, _ := p.Hash(v); h == ha {
+			t.Fatalf("Got same hash for %v", v)
+		}
+	}
+	if _, err := p.Hash(1); err == nil {
+		t.Fatal("Expect error for invalid param")
+	}
+}
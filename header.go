@@ -0,0 +1,87 @@
+package formparser
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ToHeader 将v中带有in=header选项的顶层字段编码为HTTP header, 其余字段被忽略.
+// 名字不符合RFC 7230的token语法(如map的key含有CR、LF或空格)、value中含有CR、LF或NUL时返回错误以防止header注入, 非ASCII字符按WithHeaderEncoding设置的方式处理
+func (p *FormParser) ToHeader(v interface{}, opts ...Option) (http.Header, error) {
+	p = p.with(opts)
+	kvs, _, err := p.partition(valueOf(v), "header")
+	if err != nil {
+		return nil, err
+	}
+	h := make(http.Header, len(kvs))
+	for _, kv := range kvs {
+		if !isToken(kv.K) {
+			return nil, fmt.Errorf("%s: Invalid header name %q", pkgName, kv.K)
+		}
+		val, err := p.headerValue(kv)
+		if err != nil {
+			return nil, err
+		}
+		h.Add(kv.K, val)
+	}
+	return h, nil
+}
+
+// headerValue 校验并编码header的value
+func (p *FormParser) headerValue(kv KV) (string, error) {
+	if i := strings.IndexAny(kv.V, "\r\n\x00"); i >= 0 {
+		return "", fmt.Errorf("%s: Header %q contains invalid character %q", pkgName, kv.K, kv.V[i])
+	}
+	if isASCII(kv.V) {
+		return kv.V, nil
+	}
+	switch p.headerEncoding {
+	case HeaderRFC2047:
+		return mime.QEncoding.Encode("utf-8", kv.V), nil
+	case HeaderRFC8187:
+		return "UTF-8''" + extValue(kv.V), nil
+	}
+	return kv.V, nil
+}
+
+// isToken s是否符合RFC 7230的token语法, 即由数字、字母及"!#$%&'*+-.^_`|~"组成的非空字符串
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// extValue 按RFC 8187对attr-char以外的字节做百分号编码
+func extValue(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0xf])
+	}
+	return b.String()
+}
@@ -0,0 +1,55 @@
+package formparser
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestToHeader(t *testing.T) {
+	type Req struct {
+		Token  string   `a:"X-Token,in=header"`
+		Name   string   `a:"X-Name,in=header"`
+		Accept []string `a:"Accept,in=header,join"`
+		Limit  int      `a:"limit"`
+	}
+	req := Req{Token: "abc", Name: "张三 a", Accept: []string{"a", "b"}, Limit: 1}
+	cases := []struct {
+		enc  HeaderEncoding
+		name string
+	}{
+		{HeaderRaw, "张三 a"},
+		{HeaderRFC2047, "=?utf-8?q?=E5=BC=A0=E4=B8=89_a?="},
+		{HeaderRFC8187, "UTF-8''%E5%BC%A0%E4%B8%89%20a"},
+	}
+	p := New("a", "-")
+	for _, c := range cases {
+		got, err := p.ToHeader(req, WithHeaderEncoding(c.enc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := http.Header{"X-Token": {"abc"}, "X-Name": {c.name}, "Accept": {"a,b"}}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Got %v, want %v", got, want)
+		}
+	}
+
+	for _, v := range []string{"a\r\nSet-Cookie: x", "a\nb", "a\x00"} {
+		if _, err := p.ToHeader(Req{Token: v}); err == nil {
+			t.Fatalf("Expect error for %q", v)
+		}
+	}
+
+	// in=header的map字段以map的key作为header的名字, 同样需要校验
+	type Meta struct {
+		Extra map[string]string `a:"...,in=header"`
+	}
+	if h, err := p.ToHeader(Meta{map[string]string{"X-A": "1"}}); err != nil || h.Get("X-A") != "1" {
+		t.Fatalf("Got %v, %v", h, err)
+	}
+	for _, k := range []string{"X-A\r\nSet-Cookie: x", "X A", "X:A", ""} {
+		if h, err := p.ToHeader(Meta{map[string]string{k: "1"}}); err == nil {
+			t.Fatalf("Expect error for name %q, got %v", k, h)
+		}
+	}
+}
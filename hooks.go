@@ -0,0 +1,44 @@
+package formparser
+
+import (
+	"reflect"
+)
+
+// beforeEncoder 在struct的字段编码之前调用, 可用于规范化自身的数据
+type beforeEncoder interface {
+	BeforeEncode() error
+}
+
+// afterEncoder 在struct的字段编码之后调用, 可修改结果或追加计算得到的参数(如校验和).
+// kvs中的key相对于该struct, 不含父辈前缀; 绝对key(标签以"/"开头)保持原样
+type afterEncoder interface {
+	AfterEncode(kvs []KV) ([]KV, error)
+}
+
+var (
+	beforeEncoderType = reflect.TypeOf((*beforeEncoder)(nil)).Elem()
+	afterEncoderType  = reflect.TypeOf((*afterEncoder)(nil)).Elem()
+)
+
+// hasHooks 判断struct是否实现了BeforeEncode或AfterEncode
+func hasHooks(v reflect.Value) bool {
+	_, before := implements(v, beforeEncoderType)
+	_, after := implements(v, afterEncoderType)
+	return before || after
+}
+
+// beforeEncode 若v实现了BeforeEncode则调用之. 指针接收者的方法仅在v可寻址时调用, 如传入*struct
+func beforeEncode(v reflect.Value) error {
+	if i, ok := implements(v, beforeEncoderType); ok {
+		return i.(beforeEncoder).BeforeEncode()
+	}
+	return nil
+}
+
+// afterEncode 若v实现了AfterEncode则以v的编码结果调用之
+func afterEncode(v reflect.Value, kvs []KV) ([]KV, error) {
+	if i, ok := implements(v, afterEncoderType); ok {
+		return i.(afterEncoder).AfterEncode(kvs)
+	}
+	return kvs, nil
+}
@@ -0,0 +1,57 @@
+package formparser
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type hooked struct {
+	Name string `a:"name"`
+	Sum  string `a:"-"`
+}
+
+func (h *hooked) BeforeEncode() error {
+	if h.Name == "" {
+		return errors.New("name is required")
+	}
+	h.Name = strings.ToLower(h.Name)
+	return nil
+}
+
+func (h hooked) AfterEncode(kvs []KV) ([]KV, error) {
+	return append(kvs, KV{"sum", strings.Repeat("x", len(h.Name))}), nil
+}
+
+func TestEncodeHooks(t *testing.T) {
+	type Demo struct {
+		H hooked  `a:"h"`
+		P *hooked `a:"p"`
+	}
+	p := New("a", "-")
+	v := &Demo{H: hooked{Name: "AB"}, P: &hooked{Name: "CDE"}}
+	got, err := p.Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KV{{"h.name", "ab"}, {"h.sum", "xx"}, {"p.name", "cde"}, {"p.sum", "xxx"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+	if n, _, err := p.EstimateSize(v); err != nil || n != len(want) {
+		t.Fatalf("EstimateSize got %d, %v", n, err)
+	}
+
+	if _, err := p.Encode(&hooked{}); err == nil {
+		t.Fatal("Expect error from BeforeEncode")
+	}
+	// 不可寻址时不调用指针接收者的BeforeEncode
+	got, err = p.Encode(hooked{Name: "AB"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []KV{{"name", "AB"}, {"sum", "xx"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+}
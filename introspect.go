@@ -0,0 +1,180 @@
+package formparser
+
+import (
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Config FormParser当前配置的快照, 由Options返回, 修改它不会影响FormParser
+type Config struct {
+	Tag              string
+	IgnoreFlag       string
+	InlineKeywords   []string
+	OnConflict       ConflictPolicy
+	MaxKVs           int
+	MaxValueLen      int
+	TruncateValues   bool
+	TruncateMarker   string
+	EscapeValues     bool
+	SpacePercent20   bool
+	EscapeChars      string
+	KeepChars        string
+	LowercaseKeys    bool
+	BytesFormat      BytesFormat
+	IndexBase        int
+	RootKey          string
+	Version          string
+	Style            string
+	KeyStyle         KeyStyle
+	DecodeKeyStyle   KeyStyle
+	RepeatedKeys     bool
+	SemicolonSep     bool
+	MaxBodyBytes     int64
+	MaxKeys          int
+	MaxKeyDepth      int
+	MaxIndex         int
+	CaseInsensitive  bool
+	FieldNaming      FieldNaming
+	RequireTags      bool
+	ExcludePaths     []string
+	OnlyPaths        []string
+	Interceptors     []string // WithInterceptor设置的路径
+	HeaderEncoding   HeaderEncoding
+	ComplexFormat    ComplexFormat
+	BoolLexicon      *BoolLexicon  // 未设置WithBoolLexicon时为nil
+	SliceStrategy    SliceStrategy // 未设置WithSliceStrategy时为nil
+	RejectUintptr    bool
+	TimeLocation     *time.Location
+	TimeLayout       string
+	Recover          bool
+	OmitEmptyStructs bool
+	JSONMarshaler    bool
+	EncodeCache      bool
+
+	// 是否设置了WithFieldPredicate、WithMapKeyLess/WithNumericMapKeys
+	FieldPredicate bool
+	CustomMapOrder bool
+
+	// 可通过encoder选项选用的编码器名字, 各类型可通过format选项选用的表示方式, 通过RegisterTagOption注册的选项,
+	// 以及可通过slice选项选用的展开方式
+	Encoders        []string
+	Formats         map[reflect.Type][]string
+	TagOptions      []string
+	SliceStrategies []string
+}
+
+// Options 返回当前配置的快照, 便于嵌入FormParser的框架输出或校验配置
+func (p *FormParser) Options() Config {
+	c := Config{
+		Tag:              p.tag,
+		IgnoreFlag:       p.ignoreFlag,
+		OnConflict:       p.onConflict,
+		MaxKVs:           p.maxKVs,
+		MaxValueLen:      p.maxValueLen,
+		TruncateValues:   p.truncateValue,
+		TruncateMarker:   p.truncateMarker,
+		EscapeValues:     p.escapeValues,
+		SpacePercent20:   p.spacePercent20,
+		EscapeChars:      p.escapeChars,
+		KeepChars:        p.keepChars,
+		LowercaseKeys:    p.lowercaseKeys,
+		BytesFormat:      p.bytesFormat,
+		IndexBase:        p.indexBase,
+		RootKey:          p.rootKey,
+		Version:          p.version,
+		Style:            p.style,
+		KeyStyle:         p.keyStyle,
+		DecodeKeyStyle:   p.decodeKeyStyle,
+		RepeatedKeys:     p.repeatedKeys,
+		SemicolonSep:     p.semicolonSep,
+		MaxBodyBytes:     p.maxBodyBytes,
+		MaxKeys:          p.maxKeys,
+		MaxKeyDepth:      p.maxKeyDepth,
+		MaxIndex:         p.maxIndex,
+		CaseInsensitive:  p.foldKeys,
+		FieldNaming:      p.fieldNaming,
+		RequireTags:      p.requireTags,
+		ExcludePaths:     append([]string(nil), p.excludePaths...),
+		OnlyPaths:        append([]string(nil), p.onlyPaths...),
+		Interceptors:     p.interceptorPaths(),
+		HeaderEncoding:   p.headerEncoding,
+		ComplexFormat:    p.complexFormat,
+		RejectUintptr:    p.rejectUintptr,
+		TimeLocation:     p.timeLoc,
+		TimeLayout:       p.timeLayout,
+		SliceStrategy:    p.slicePreset,
+		Recover:          p.recover,
+		OmitEmptyStructs: p.omitEmptyStructs,
+		JSONMarshaler:    p.jsonMarshaler,
+		EncodeCache:      p.cache != nil,
+		FieldPredicate:   p.fieldPredicate != nil,
+		CustomMapOrder:   p.mapKeyLess != nil,
+		Formats:          make(map[reflect.Type][]string, len(p.formats)),
+	}
+	if l := p.boolLexicon; l != nil {
+		c.BoolLexicon = &BoolLexicon{l.True, l.False, append([]string(nil), l.TrueValues...), append([]string(nil), l.FalseValues...)}
+	}
+	for k := range p.inlineKeywords {
+		c.InlineKeywords = append(c.InlineKeywords, k)
+	}
+	sort.Strings(c.InlineKeywords)
+	for name := range p.namedEncoders {
+		c.Encoders = append(c.Encoders, name)
+	}
+	sort.Strings(c.Encoders)
+	for name := range p.tagHandlers {
+		c.TagOptions = append(c.TagOptions, name)
+	}
+	sort.Strings(c.TagOptions)
+	c.SliceStrategies = p.sliceStrategyNames()
+	for t, formats := range p.formats {
+		for name := range formats {
+			c.Formats[t] = append(c.Formats[t], name)
+		}
+		sort.Strings(c.Formats[t])
+	}
+	return c
+}
+
+// SupportedKinds 返回按当前配置可以编码的reflect.Kind, 按Kind的值升序.
+// 指针及接口按其指向的值编码; uintptr、unsafe.Pointer默认被忽略, 开启WithRejectUintptr时不在此列
+func (p *FormParser) SupportedKinds() []reflect.Kind {
+	kinds := []reflect.Kind{reflect.Ptr, reflect.Interface}
+	for k := range p.encoders {
+		switch {
+		case k == reflect.Invalid:
+			continue
+		case (k == reflect.Uintptr || k == reflect.UnsafePointer) && p.rejectUintptr:
+			continue
+		case (k == reflect.Complex64 || k == reflect.Complex128) && p.complexFormat == ComplexError:
+			continue
+		}
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	return kinds
+}
+
+// RegisteredTypes 返回通过RegisterType、RegisterLocaleType或RegisterFormat注册过的类型(含内置的time.Time), 按类型名升序
+func (p *FormParser) RegisteredTypes() []reflect.Type {
+	var types []reflect.Type
+	seen := make(map[reflect.Type]bool)
+	add := func(t reflect.Type) {
+		if !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	for t := range p.typeEncoders {
+		add(t)
+	}
+	for t := range p.localeEncoders {
+		add(t)
+	}
+	for t := range p.formats {
+		add(t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].String() < types[j].String() })
+	return types
+}
@@ -0,0 +1,52 @@
+package formparser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIntrospect(t *testing.T) {
+	p := New("a", "-", WithInlineKeywords("inline"), WithMaxKVs(10), WithKeyStyle(KeyStyleBracket), WithRejectUintptr(true))
+	p.RegisterType(reflect.TypeOf(decimalStub{}), DecimalEncoder)
+	p.RegisterFormat(reflect.TypeOf(cents(0)), "yuan", func(v reflect.Value) (string, error) { return "", nil }, nil)
+
+	c := p.Options()
+	if c.Tag != "a" || c.IgnoreFlag != "-" || c.MaxKVs != 10 || c.KeyStyle != KeyStyleBracket || !c.RejectUintptr {
+		t.Fatalf("Unexpected config %+v", c)
+	}
+	if want := []string{"...", "inline"}; !reflect.DeepEqual(c.InlineKeywords, want) {
+		t.Fatalf("Got %v, want %v", c.InlineKeywords, want)
+	}
+	if want := []string{"base64", "gob+base64", "hex"}; !reflect.DeepEqual(c.Encoders, want) {
+		t.Fatalf("Got %v, want %v", c.Encoders, want)
+	}
+	if want := []string{"yuan"}; !reflect.DeepEqual(c.Formats[reflect.TypeOf(cents(0))], want) {
+		t.Fatalf("Got %v, want %v", c.Formats, want)
+	}
+	c.InlineKeywords[0] = "changed"
+	if _, ok := p.inlineKeywords["..."]; !ok {
+		t.Fatal("Options should return a copy")
+	}
+
+	want := []reflect.Type{reflect.TypeOf(cents(0)), reflect.TypeOf(decimalStub{}), reflect.TypeOf(time.Time{})}
+	if got := p.RegisteredTypes(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+
+	kinds := p.SupportedKinds()
+	has := make(map[reflect.Kind]bool)
+	for _, k := range kinds {
+		has[k] = true
+	}
+	for _, k := range []reflect.Kind{reflect.String, reflect.Ptr, reflect.Interface, reflect.Struct, reflect.Map, reflect.Slice, reflect.Complex128} {
+		if !has[k] {
+			t.Fatalf("Expect kind %v in %v", k, kinds)
+		}
+	}
+	for _, k := range []reflect.Kind{reflect.Invalid, reflect.Chan, reflect.Func, reflect.Uintptr, reflect.UnsafePointer} {
+		if has[k] {
+			t.Fatalf("Unexpected kind %v in %v", k, kinds)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package formparser
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Locale 编码时的地区信息, 由WithLocale设置, 传给RegisterLocaleType注册的编码器,
+// 使得金额、日期一类的类型可以按目标API的约定格式化, 而无需为每个API创建单独的FormParser
+type Locale struct {
+	Language string            // 语言标签, 如"zh-CN"
+	Currency string            // ISO 4217货币代码, 如"CNY"
+	Location *time.Location    // 时区, 未设置tz选项及WithTimeLocation时time.Time也转换到该时区
+	Extra    map[string]string // 其它约定, 由编码器自行解释
+}
+
+// LocaleEncoder 按Locale将指定类型的值编码为单个字符串, 用于RegisterLocaleType
+type LocaleEncoder func(l Locale, v reflect.Value) (string, error)
+
+// RegisterLocaleType 为类型t注册依赖Locale的编码器, 与RegisterType相同, 但编码时传入WithLocale设置的Locale, 例如:
+//
+//	p.RegisterLocaleType(reflect.TypeOf(Money{}), func(l formparser.Locale, v reflect.Value) (string, error) {
+//		return v.Interface().(Money).Format(l.Currency), nil
+//	})
+//	p.ToMap(reflect.ValueOf(order), formparser.WithLocale(formparser.Locale{Currency: "USD"}))
+//
+// 同一类型同时通过RegisterType注册时以RegisterType为准. 需在开始编码前完成注册, 注册过程非并发安全
+func (p *FormParser) RegisterLocaleType(t reflect.Type, enc LocaleEncoder) {
+	if t == nil || enc == nil {
+		panic(fmt.Sprintf("%s: Missing type or encoder", pkgName))
+	}
+	p.localeEncoders[t] = enc
+}
@@ -0,0 +1,59 @@
+package formparser
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type money struct {
+	Cents int64
+}
+
+func TestRegisterLocaleType(t *testing.T) {
+	type Order struct {
+		Price money     `a:"price"`
+		At    time.Time `a:"at"`
+	}
+	p := New("a", "-")
+	p.RegisterLocaleType(reflect.TypeOf(money{}), func(l Locale, v reflect.Value) (string, error) {
+		m := v.Interface().(money)
+		if l.Currency == "" {
+			return "", fmt.Errorf("Missing currency")
+		}
+		return fmt.Sprintf("%d.%02d %s", m.Cents/100, m.Cents%100, l.Currency), nil
+	})
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip(err)
+	}
+	v := Order{Price: money{1234}, At: time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)}
+
+	m, err := p.ToMap(reflect.ValueOf(v), WithLocale(Locale{Currency: "USD", Location: shanghai}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"price": "12.34 USD", "at": "2024-05-06T08:00:00+08:00"}; !reflect.DeepEqual(m, want) {
+		t.Fatalf("Got %v, want %v", m, want)
+	}
+	// WithTimeLocation优先于Locale的时区
+	m, err = p.ToMap(reflect.ValueOf(v), WithLocale(Locale{Currency: "CNY", Location: shanghai}), WithTimeLocation(time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"price": "12.34 CNY", "at": "2024-05-06T00:00:00Z"}; !reflect.DeepEqual(m, want) {
+		t.Fatalf("Got %v, want %v", m, want)
+	}
+	if _, err := p.ToMap(reflect.ValueOf(v)); err == nil {
+		t.Fatal("Expect error from the encoder without locale")
+	}
+
+	found := false
+	for _, typ := range p.RegisteredTypes() {
+		found = found || typ == reflect.TypeOf(money{})
+	}
+	if !found {
+		t.Fatalf("Got %v", p.RegisteredTypes())
+	}
+}
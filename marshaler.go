@@ -0,0 +1,229 @@
+package formparser
+
+import (
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var (
+	valuerType          = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	binaryMarshalerType = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	stringerType        = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+	stringsType         = reflect.TypeOf([]string(nil))
+	runeType            = reflect.TypeOf(rune(0))
+)
+
+// isRunes 判断t是否为[]rune或[N]rune. rune与int32是同一类型, 因此仅在带有"runes"选项时才按字符串处理
+func isRunes(t reflect.Type) bool {
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem() == runeType
+}
+
+// locations LoadLocation的结果缓存, 避免每次编码都读取时区数据
+var locations sync.Map
+
+// timeLocation 返回time.Time编码前应转换到的时区, 依次为字段的tz选项、WithTimeLocation、WithLocale的时区, 均未设置时为nil
+func (p *FormParser) timeLocation(opts tagOptions) (*time.Location, error) {
+	name, ok := opts.Get("tz")
+	if !ok {
+		if p.timeLoc == nil {
+			return p.locale.Location, nil
+		}
+		return p.timeLoc, nil
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: Invalid tz %q, %v", pkgName, name, err)
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// protobuf well-known类型所在的包. 通过包路径识别这些类型, 从而无需依赖protobuf
+const (
+	protoWrappersPkg  = "google.golang.org/protobuf/types/known/wrapperspb"
+	protoTimestampPkg = "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// protoWellKnown 按"包路径.类型名"登记的protobuf well-known类型, 值为取出被包装值的方法名.
+// 这些类型按方法的结果编码, Timestamp通过AsTime转为time.Time后按RFC3339编码
+var protoWellKnown = map[string]string{
+	protoWrappersPkg + ".DoubleValue": "GetValue",
+	protoWrappersPkg + ".FloatValue":  "GetValue",
+	protoWrappersPkg + ".Int64Value":  "GetValue",
+	protoWrappersPkg + ".UInt64Value": "GetValue",
+	protoWrappersPkg + ".Int32Value":  "GetValue",
+	protoWrappersPkg + ".UInt32Value": "GetValue",
+	protoWrappersPkg + ".BoolValue":   "GetValue",
+	protoWrappersPkg + ".StringValue": "GetValue",
+	protoWrappersPkg + ".BytesValue":  "GetValue",
+	protoTimestampPkg + ".Timestamp":  "AsTime",
+}
+
+// unwrapProto 若v为(指向)protobuf well-known类型, 则返回其被包装的值
+func unwrapProto(v reflect.Value) (reflect.Value, bool) {
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t.PkgPath() == "" {
+		return reflect.Value{}, false
+	}
+	method, ok := protoWellKnown[t.PkgPath()+"."+t.Name()]
+	if !ok {
+		return reflect.Value{}, false
+	}
+	// 方法均定义在指针上, 不可寻址的值先复制一份
+	if v.Kind() != reflect.Ptr {
+		if v.CanAddr() {
+			v = v.Addr()
+		} else {
+			ptr := reflect.New(t)
+			ptr.Elem().Set(v)
+			v = ptr
+		}
+	}
+	m := v.MethodByName(method)
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return reflect.Value{}, false
+	}
+	return m.Call(nil)[0], true
+}
+
+// implements 判断v(或其指针)是否实现了接口t, 返回可直接断言为t的值
+func implements(v reflect.Value, t reflect.Type) (interface{}, bool) {
+	if !v.IsValid() {
+		return nil, false
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil, false
+	}
+	if !v.CanInterface() {
+		return nil, false
+	}
+	if v.Type().Implements(t) {
+		return v.Interface(), true
+	}
+	if v.CanAddr() && reflect.PtrTo(v.Type()).Implements(t) {
+		return v.Addr().Interface(), true
+	}
+	return nil, false
+}
+
+// encodeMarshaler 对实现了特定接口或有特殊表示的类型进行编码, ok为false表示v不属于这类类型, 需按kind编码
+func (p *FormParser) encodeMarshaler(v reflect.Value, tagK string, opts tagOptions) (rt []KV, ok bool, err error) {
+	if !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		return nil, false, nil
+	}
+	// time.Time在格式化之前转换时区
+	if v.Type() == timeType && v.CanInterface() {
+		loc, err := p.timeLocation(opts)
+		if err != nil {
+			return nil, true, err
+		}
+		if loc != nil {
+			v = reflect.ValueOf(v.Interface().(time.Time).In(loc))
+		}
+	}
+	// 字段通过encoder选项选择的编码器
+	if enc, ok, err := p.lookupEncoder(opts); ok || err != nil {
+		if err != nil {
+			return nil, true, err
+		}
+		s, err := enc(v)
+		if err != nil {
+			return nil, true, err
+		}
+		return append(rt, KV{tagK, s}), true, nil
+	}
+	// 字段通过format选项选择的表示方式
+	if f, ok, err := p.lookupFormat(v, opts); ok || err != nil {
+		if err != nil {
+			return nil, true, err
+		}
+		s, err := f.enc(v)
+		if err != nil {
+			return nil, true, err
+		}
+		return append(rt, KV{tagK, s}), true, nil
+	}
+	// 通过RegisterType注册的类型
+	if enc, ok := p.typeEncoders[v.Type()]; ok {
+		s, err := enc(v)
+		if err != nil {
+			return nil, true, err
+		}
+		return append(rt, KV{tagK, s}), true, nil
+	}
+	if enc, ok := p.localeEncoders[v.Type()]; ok {
+		s, err := enc(p.locale, v)
+		if err != nil {
+			return nil, true, err
+		}
+		return append(rt, KV{tagK, s}), true, nil
+	}
+	if v.Type() == timeType && v.CanInterface() {
+		layout := p.timeLayout
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		return append(rt, KV{tagK, v.Interface().(time.Time).Format(layout)}), true, nil
+	}
+	// protobuf well-known类型按被包装的值编码
+	if val, ok := unwrapProto(v); ok {
+		rt, err = p.encode(val, tagK, opts)
+		return rt, true, err
+	}
+	// driver.Valuer按Value()的结果编码, 结果为nil时忽略该字段.
+	// 结果本身仍是driver.Valuer时(如Value()直接返回接收者)按kind编码, 以免无限递归
+	if i, ok := implements(v, valuerType); ok {
+		val, err := i.(driver.Valuer).Value()
+		if err != nil {
+			return nil, true, err
+		}
+		rv := reflect.ValueOf(val)
+		if _, again := implements(rv, valuerType); again {
+			rt, err = p.encodeKind(rv, tagK, opts)
+		} else {
+			rt, err = p.encode(rv, tagK, opts)
+		}
+		return rt, true, err
+	}
+	// encoding.BinaryMarshaler按序列化结果编码, 与[]byte的编码方式一致
+	if i, ok := implements(v, binaryMarshalerType); ok {
+		b, err := i.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return nil, true, err
+		}
+		return append(rt, KV{tagK, p.encodeBytes(b, opts)}), true, nil
+	}
+	// 开启WithJSONMarshaler时json.Marshaler按序列化结果编码, JSON字符串去掉引号
+	if p.jsonMarshaler {
+		if i, ok := implements(v, jsonMarshalerType); ok {
+			b, err := i.(json.Marshaler).MarshalJSON()
+			if err != nil {
+				return nil, true, err
+			}
+			return append(rt, KV{tagK, jsonValue(b)}), true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// jsonValue 将JSON字符串还原为其内容, 其余JSON值(数字、对象等)保持原样
+func jsonValue(b []byte) string {
+	var s string
+	if len(b) > 0 && b[0] == '"' && json.Unmarshal(b, &s) == nil {
+		return s
+	}
+	return string(b)
+}
@@ -0,0 +1,252 @@
+package formparser
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type valuerStub struct {
+	id string
+}
+
+func (v valuerStub) Value() (driver.Value, error) {
+	if v.id == "" {
+		return nil, nil
+	}
+	return "id-" + v.id, nil
+}
+
+type ptrValuerStub struct {
+	n int64
+}
+
+func (v *ptrValuerStub) Value() (driver.Value, error) {
+	if v.n < 0 {
+		return nil, errors.New("negative")
+	}
+	return v.n, nil
+}
+
+func TestEncodeValuer(t *testing.T) {
+	type Demo struct {
+		A valuerStub     `a:"a"`
+		B *valuerStub    `a:"b"`
+		C valuerStub     `a:"c"`
+		D *ptrValuerStub `a:"d"`
+		E sql.NullString `a:"e"`
+		F sql.NullInt64  `a:"f"`
+		G sql.NullTime   `a:"g"`
+	}
+	v := Demo{
+		A: valuerStub{"1"},
+		B: &valuerStub{"2"},
+		D: &ptrValuerStub{3},
+		E: sql.NullString{String: "s", Valid: true},
+		G: sql.NullTime{Time: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Valid: true},
+	}
+	kvs, err := New("a", "-").parse(reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KV{{"a", "id-1"}, {"b", "id-2"}, {"d", "3"}, {"e", "s"}, {"g", "2020-01-02T03:04:05Z"}}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Unexpected result %v, want %v", kvs, want)
+	}
+
+	v.D.n = -1
+	if _, err := New("a", "-").parse(reflect.ValueOf(v)); err == nil {
+		t.Fatal("Expect error from Value()")
+	}
+}
+
+// selfValuer Value()直接返回接收者本身
+type selfValuer string
+
+func (s selfValuer) Value() (driver.Value, error) {
+	return s, nil
+}
+
+// selfPtrValuer Value()返回指向自身的指针
+type selfPtrValuer int
+
+func (s *selfPtrValuer) Value() (driver.Value, error) {
+	return s, nil
+}
+
+func TestEncodeSelfValuer(t *testing.T) {
+	type Demo struct {
+		S selfValuer     `a:"s"`
+		P *selfPtrValuer `a:"p"`
+	}
+	n := selfPtrValuer(2)
+	kvs, err := New("a", "-").Encode(Demo{S: "active", P: &n})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []KV{{"s", "active"}, {"p", "2"}}; !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Got %v, want %v", kvs, want)
+	}
+}
+
+type binaryStub [2]byte
+
+func (b binaryStub) MarshalBinary() ([]byte, error) {
+	return []byte{b[0], b[1], 0xff}, nil
+}
+
+func TestEncodeBinaryMarshaler(t *testing.T) {
+	type Demo struct {
+		A binaryStub  `a:"a"`
+		B *binaryStub `a:"b"`
+		C []byte      `a:"c"`
+	}
+	v := Demo{A: binaryStub{1, 2}, C: []byte{0xfb, 0xff}}
+
+	cases := []struct {
+		format BytesFormat
+		want   []KV
+	}{
+		{BytesBase64, []KV{{"a", "AQL/"}, {"c", "+/8="}}},
+		{BytesBase64URL, []KV{{"a", "AQL_"}, {"c", "-_8="}}},
+		{BytesHex, []KV{{"a", "0102ff"}, {"c", "fbff"}}},
+	}
+	for _, c := range cases {
+		kvs, err := New("a", "-", WithBytesFormat(c.format)).parse(reflect.ValueOf(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(kvs, c.want) {
+			t.Fatalf("Format %d: unexpected result %v, want %v", c.format, kvs, c.want)
+		}
+	}
+}
+
+// 模拟wrapperspb.StringValue、timestamppb.Timestamp的结构及方法
+type stringValueStub struct {
+	state int
+	Value string
+}
+
+func (x *stringValueStub) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+type timestampStub struct {
+	sizeCache int
+	Seconds   int64
+	Nanos     int32
+}
+
+func (x *timestampStub) AsTime() time.Time {
+	return time.Unix(x.Seconds, int64(x.Nanos)).UTC()
+}
+
+func TestEncodeProtoWellKnown(t *testing.T) {
+	pkg := reflect.TypeOf(stringValueStub{}).PkgPath()
+	protoWellKnown[pkg+".stringValueStub"] = "GetValue"
+	protoWellKnown[pkg+".timestampStub"] = "AsTime"
+	defer delete(protoWellKnown, pkg+".stringValueStub")
+	defer delete(protoWellKnown, pkg+".timestampStub")
+
+	type Demo struct {
+		A *stringValueStub `a:"a"`
+		B *stringValueStub `a:"b"`
+		C *timestampStub   `a:"c"`
+		D stringValueStub  `a:"d"`
+	}
+	v := Demo{
+		A: &stringValueStub{Value: "x"},
+		C: &timestampStub{Seconds: 1577934245, Nanos: 500000000},
+		D: stringValueStub{Value: "y"},
+	}
+	kvs, err := New("a", "-").parse(reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KV{{"a", "x"}, {"c", "2020-01-02T03:04:05.5Z"}, {"d", "y"}}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Unexpected result %v, want %v", kvs, want)
+	}
+}
+
+func TestTimeLocation(t *testing.T) {
+	type Demo struct {
+		T  time.Time   `a:"t"`
+		U  time.Time   `a:"u,tz=UTC"`
+		D  *time.Time  `a:"d,format=datetime"`
+		TS []time.Time `a:"ts,format=date"`
+	}
+	tm := time.Date(2020, 1, 2, 23, 4, 5, 0, time.UTC)
+	v := Demo{T: tm, U: tm, D: &tm, TS: []time.Time{tm}}
+	p := New("a", "-", WithTimeLocation(time.FixedZone("CST", 8*3600)))
+	kvs, err := p.Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KV{
+		{"t", "2020-01-03T07:04:05+08:00"},
+		{"u", "2020-01-02T23:04:05Z"},
+		{"d", "2020-01-03 07:04:05"},
+		{"ts.0", "2020-01-03"},
+	}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Got %v, want %v", kvs, want)
+	}
+
+	type Bad struct {
+		T time.Time `a:"t,tz=Nowhere/City"`
+	}
+	if _, err := p.Encode(Bad{}); err == nil {
+		t.Fatal("Expect error for invalid tz")
+	}
+}
+
+type jsonStatus int
+
+func (s jsonStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]string{"off", "on"}[s])
+}
+
+type jsonPoint struct {
+	X, Y int
+}
+
+func (p *jsonPoint) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`{"x":%d,"y":%d}`, p.X, p.Y)), nil
+}
+
+func TestJSONMarshaler(t *testing.T) {
+	type Demo struct {
+		S jsonStatus   `a:"s"`
+		P jsonPoint    `a:"p"`
+		L []jsonStatus `a:"l"`
+		T time.Time    `a:"t"`
+	}
+	v := &Demo{S: 1, P: jsonPoint{1, 2}, L: []jsonStatus{0}, T: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)}
+	p := New("a", "-")
+	cases := []struct {
+		opts []Option
+		want []KV
+	}{
+		{nil, []KV{{"s", "1"}, {"p.X", "1"}, {"p.Y", "2"}, {"l.0", "0"}, {"t", "2020-01-02T00:00:00Z"}}},
+		{[]Option{WithJSONMarshaler(true)}, []KV{{"s", "on"}, {"p", `{"x":1,"y":2}`}, {"l.0", "off"}, {"t", "2020-01-02T00:00:00Z"}}},
+	}
+	for _, c := range cases {
+		got, err := p.Encode(v, c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("Got %v, want %v", got, c.want)
+		}
+	}
+}
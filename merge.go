@@ -0,0 +1,93 @@
+package formparser
+
+import (
+	"net/url"
+	"sort"
+)
+
+// MergeToMap 依次编码vs并合并为一份表单数据, 不同来源编码出相同key时按WithOnConflict设置的策略处理:
+// ConflictKeepLast(默认)保留后者, ConflictKeepFirst保留前者, ConflictConcat合并为多个值,
+// ConflictError在值不同时返回MergeError, 其中列出所有冲突的key. 同一来源中重复的key(如repeat选项)保持为多个值
+func (p *FormParser) MergeToMap(vs []interface{}, opts ...Option) (url.Values, error) {
+	p = p.with(opts)
+	values := make(url.Values)
+	var conflicts []string
+	for _, v := range vs {
+		kvs, err := p.Encode(v)
+		if err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, p.mergeInto(values, kvs)...)
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, &MergeError{Keys: conflicts}
+	}
+	return values, nil
+}
+
+// EncodeInto 编码v并合并到dst中, dst中已有的key视为先出现的来源, 冲突的处理参见MergeToMap.
+// 返回MergeError时dst不会被修改
+func (p *FormParser) EncodeInto(dst url.Values, v interface{}, opts ...Option) error {
+	p = p.with(opts)
+	kvs, err := p.Encode(v)
+	if err != nil {
+		return err
+	}
+	if conflicts := p.mergeInto(dst, kvs); len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return &MergeError{Keys: conflicts}
+	}
+	return nil
+}
+
+// mergeInto 将同一来源的kvs按策略合并到dst中, 返回与dst中的值冲突的key, 此时dst不会被修改
+func (p *FormParser) mergeInto(dst url.Values, kvs []KV) []string {
+	src := make(url.Values)
+	var keys []string
+	for _, kv := range kvs {
+		if _, ok := src[kv.K]; !ok {
+			keys = append(keys, kv.K)
+		}
+		src[kv.K] = append(src[kv.K], kv.V)
+	}
+
+	if p.onConflict == ConflictError {
+		var conflicts []string
+		for _, k := range keys {
+			if old, ok := dst[k]; ok && !equalValues(old, src[k]) {
+				conflicts = append(conflicts, k)
+			}
+		}
+		if len(conflicts) > 0 {
+			return conflicts
+		}
+	}
+	for _, k := range keys {
+		old, ok := dst[k]
+		if !ok {
+			dst[k] = src[k]
+			continue
+		}
+		switch p.onConflict {
+		case ConflictKeepFirst, ConflictError:
+		case ConflictConcat:
+			dst[k] = append(old, src[k]...)
+		default:
+			dst[k] = src[k]
+		}
+	}
+	return nil
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package formparser
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestMergeToMap(t *testing.T) {
+	type Page struct {
+		Page int `a:"page"`
+		Size int `a:"size"`
+	}
+	type Filter struct {
+		Page int      `a:"page"`
+		Tags []string `a:"tag,repeat"`
+	}
+	vs := []interface{}{Page{Page: 1, Size: 10}, &Filter{Page: 2, Tags: []string{"a", "b"}}}
+	cases := []struct {
+		policy ConflictPolicy
+		want   url.Values
+	}{
+		{ConflictKeepLast, url.Values{"page": {"2"}, "size": {"10"}, "tag": {"a", "b"}}},
+		{ConflictKeepFirst, url.Values{"page": {"1"}, "size": {"10"}, "tag": {"a", "b"}}},
+		{ConflictConcat, url.Values{"page": {"1", "2"}, "size": {"10"}, "tag": {"a", "b"}}},
+	}
+	p := New("a", "-")
+	for _, c := range cases {
+		got, err := p.MergeToMap(vs, WithOnConflict(c.policy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("Policy %d: got %v, want %v", c.policy, got, c.want)
+		}
+	}
+
+	_, err := p.MergeToMap(append(vs, map[string]int{"size": 20}), WithOnConflict(ConflictError))
+	var me *MergeError
+	if !errors.As(err, &me) || !reflect.DeepEqual(me.Keys, []string{"page", "size"}) {
+		t.Fatalf("Got %v, expect MergeError of page and size", err)
+	}
+	// 值相同不算冲突
+	if _, err := p.MergeToMap([]interface{}{Page{Page: 1}, Filter{Page: 1}}, WithOnConflict(ConflictError)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.MergeToMap([]interface{}{Page{}, 1}); err == nil {
+		t.Fatal("Expect error for invalid param")
+	}
+}
+
+func TestEncodeInto(t *testing.T) {
+	type Page struct {
+		Page int `a:"page"`
+		Size int `a:"size"`
+	}
+	p := New("a", "-")
+	dst := url.Values{"page": {"1"}, "q": {"x"}}
+	if err := p.EncodeInto(dst, Page{Page: 2, Size: 10}, WithOnConflict(ConflictError)); err == nil {
+		t.Fatal("Expect MergeError")
+	}
+	if want := (url.Values{"page": {"1"}, "q": {"x"}}); !reflect.DeepEqual(dst, want) {
+		t.Fatalf("Got %v, expect dst unchanged", dst)
+	}
+	if err := p.EncodeInto(dst, Page{Page: 2, Size: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if want := (url.Values{"page": {"2"}, "q": {"x"}, "size": {"10"}}); !reflect.DeepEqual(dst, want) {
+		t.Fatalf("Got %v, want %v", dst, want)
+	}
+}
@@ -0,0 +1,104 @@
+package formparser
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// styleOf 解析OpenAPI 3的style、explode选项, 未设置style时返回空串; explode默认为true, 与OpenAPI中form的默认值一致,
+// deepObject忽略explode选项
+func styleOf(opts tagOptions) (style string, explode bool, err error) {
+	style, ok := opts.Get("style")
+	if !ok {
+		return "", false, nil
+	}
+	if style == "deepObject" {
+		return style, true, nil
+	}
+	if style != "form" {
+		return "", false, fmt.Errorf("%s: Unsupported style %q", pkgName, style)
+	}
+	explode = true
+	if s, ok := opts.Get("explode"); ok {
+		if explode, err = strconv.ParseBool(s); err != nil {
+			return "", false, fmt.Errorf("%s: Invalid explode %q", pkgName, s)
+		}
+	}
+	return style, explode, nil
+}
+
+// isObject 判断t(解引用后)是否为OpenAPI意义上的object, 即struct或map
+func isObject(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct || t.Kind() == reflect.Map
+}
+
+// explodedObject 字段是否为style=form、explode=true的object, 此时其各个字段不带该字段的key, 与inline选项相同
+func explodedObject(t reflect.Type, opts tagOptions) bool {
+	style, explode, err := styleOf(opts)
+	return err == nil && style == "form" && explode && isObject(t)
+}
+
+// encodeStyle 按style、explode选项编码object, 如`zwf:"color,style=form,explode=false"`输出"color=R,100,G,200",
+// `zwf:"filter,style=deepObject"`不论WithKeyStyle如何都输出"filter[color]=red&filter[size]=L".
+// array、slice由sliceStrategy处理, 基础类型不受影响
+func (p *FormParser) encodeStyle(v reflect.Value, tagK string, opts tagOptions) (rt []KV, ok bool, err error) {
+	style, explode, err := styleOf(opts)
+	if err != nil {
+		return nil, true, err
+	}
+	if style == "" || !v.IsValid() || !isObject(v.Type()) {
+		return nil, false, nil
+	}
+	if style == "deepObject" {
+		if p.keyStyle == KeyStyleBracket {
+			return nil, false, nil
+		}
+		bp := p.with([]Option{WithKeyStyle(KeyStyleBracket)})
+		rt, err = bp.encoders[v.Kind()](v, tagK, opts)
+		return rt, true, err
+	}
+	if explode {
+		return nil, false, nil
+	}
+	kvs, err := p.encoders[v.Kind()](v, tagK, opts)
+	if err != nil {
+		return nil, true, err
+	}
+	parts := make([]string, 0, 2*len(kvs))
+	for _, kv := range kvs {
+		rel, ok := p.relativeKey(tagK, kv.K)
+		if !ok {
+			rel = kv.K
+		}
+		parts = append(parts, rel, kv.V)
+	}
+	return append(rt, KV{tagK, strings.Join(parts, ",")}), true, nil
+}
+
+// decodeStyle 与encodeStyle相对应, 将"R,100,G,200"还原为object, ok为false表示需按kind解码
+func (p *FormParser) decodeStyle(n *formNode, v reflect.Value, opts tagOptions, key string) (ok bool, err error) {
+	style, explode, err := styleOf(opts)
+	if err != nil {
+		return true, err
+	}
+	if style != "form" || explode || !isObject(v.Type()) || len(n.children) > 0 || len(n.values) == 0 {
+		return false, nil
+	}
+	values := make(url.Values)
+	if s := n.value(); s != "" {
+		parts := strings.Split(s, ",")
+		if len(parts)%2 != 0 {
+			return true, decodeFailed(key, fmt.Errorf("odd number of parts in %q", s))
+		}
+		for i := 0; i < len(parts); i += 2 {
+			values.Add(parts[i], parts[i+1])
+		}
+	}
+	return true, p.decodeValue(p.buildTree(values), v, "", key)
+}
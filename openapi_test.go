@@ -0,0 +1,134 @@
+package formparser
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestFormStyle(t *testing.T) {
+	type Color struct {
+		R int `a:"R"`
+		G int `a:"G"`
+	}
+	type Query struct {
+		ID     []int          `a:"id,style=form"`
+		IDs    []int          `a:"ids,style=form,explode=false"`
+		Color  Color          `a:"color,style=form"`
+		Packed *Color         `a:"packed,style=form,explode=false"`
+		Extra  map[string]int `a:"extra,style=form,explode=false"`
+		Name   string         `a:"name,style=form"`
+	}
+	v := Query{
+		ID:     []int{3, 4},
+		IDs:    []int{5, 6},
+		Color:  Color{100, 200},
+		Packed: &Color{1, 2},
+		Extra:  map[string]int{"b": 2, "a": 1},
+		Name:   "x",
+	}
+	p := New("a", "-")
+	got, err := p.Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KV{
+		{"id", "3"}, {"id", "4"}, {"ids", "5,6"}, {"R", "100"}, {"G", "200"},
+		{"packed", "R,1,G,2"}, {"extra", "a,1,b,2"}, {"name", "x"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+	if n, _, _ := p.EstimateSize(v); n != len(want) {
+		t.Fatalf("EstimateSize got %d, want %d", n, len(want))
+	}
+
+	values := make(url.Values)
+	for _, kv := range got {
+		values.Add(kv.K, kv.V)
+	}
+	var d Query
+	if err := p.Decode(values, &d); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d, v) {
+		t.Fatalf("Got %+v, want %+v", d, v)
+	}
+
+	// style=form是query参数的序列化方式, BuildURL需保留展开后的每个值
+	type Q struct {
+		IDs []int `a:"id,style=form"`
+	}
+	u, err := p.BuildURL("https://x/y?keep=1&id=1", Q{IDs: []int{3, 4, 5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://x/y?id=3&id=4&id=5&keep=1"; u != want {
+		t.Fatalf("Got %s, want %s", u, want)
+	}
+
+	type Bad struct {
+		A []int `a:"a,style=label"`
+		B Color `a:"b,style=form,explode=no"`
+	}
+	if _, err := p.Encode(Bad{A: []int{1}}); err == nil {
+		t.Fatal("Expect error for unsupported style")
+	}
+	if err := p.Decode(url.Values{"packed": {"R,1,G"}}, &d); err == nil {
+		t.Fatal("Expect error for odd number of parts")
+	}
+}
+
+func TestDeepObjectStyle(t *testing.T) {
+	type Filter struct {
+		Color string `a:"color"`
+		Size  string `a:"size"`
+	}
+	type Query struct {
+		Filter Filter            `a:"filter,style=deepObject"`
+		Sort   map[string]string `a:"sort,style=deepObject"`
+		Page   Filter            `a:"page"`
+	}
+	v := Query{Filter: Filter{"red", "L"}, Sort: map[string]string{"name": "asc"}, Page: Filter{"x", "y"}}
+	p := New("a", "-")
+	for _, opts := range [][]Option{nil, {WithKeyStyle(KeyStyleBracket)}} {
+		got, err := p.Encode(v, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pageColor, pageSize := "page.color", "page.size"
+		if opts != nil {
+			pageColor, pageSize = "page[color]", "page[size]"
+		}
+		want := []KV{{"filter[color]", "red"}, {"filter[size]", "L"}, {"sort[name]", "asc"}, {pageColor, "x"}, {pageSize, "y"}}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Got %v, want %v", got, want)
+		}
+
+		values := make(url.Values)
+		for _, kv := range got {
+			values.Add(kv.K, kv.V)
+		}
+		var d Query
+		if err := p.Decode(values, &d); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(d, v) {
+			t.Fatalf("Got %+v, want %+v", d, v)
+		}
+	}
+
+	got, err := p.Encode(v, WithRootKey("q"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].K != "q.filter[color]" {
+		t.Fatalf("Got %v", got)
+	}
+	type Bad struct {
+		A []int `a:"a,style=deepObject"`
+	}
+	if _, err := p.Encode(Bad{A: []int{1}}); err == nil {
+		t.Fatal("Expect error for deepObject slice")
+	}
+}
@@ -0,0 +1,499 @@
+package formparser
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Option 用于定制FormParser的行为, 在New或Default时传入
+type Option func(p *FormParser)
+
+// WithInlineKeywords 追加表示"子字段不继承父辈标签"的关键字, 例如"inline"、"squash".
+// 默认关键字"..."始终有效, 以保持兼容
+func WithInlineKeywords(keywords ...string) Option {
+	return func(p *FormParser) {
+		for _, k := range keywords {
+			if len(k) <= 0 {
+				panic(fmt.Sprintf("%s: Empty inline keyword", pkgName))
+			}
+			if k == p.ignoreFlag {
+				panic(fmt.Sprintf("%s: Inline keyword %q conflicts with `ignoreFlag`", pkgName, k))
+			}
+			p.inlineKeywords[k] = struct{}{}
+		}
+	}
+}
+
+// ConflictPolicy 多个字段(或map的key与字段)编码出相同key时的处理策略
+type ConflictPolicy int
+
+const (
+	// ConflictKeepLast 保留最后出现的值, 默认策略
+	ConflictKeepLast ConflictPolicy = iota
+	// ConflictKeepFirst 保留最先出现的值
+	ConflictKeepFirst
+	// ConflictError 返回错误
+	ConflictError
+	// ConflictConcat 将所有值合并为同名key的多个值, 仅用于MergeToMap、EncodeInto, 其余接口按ConflictKeepLast处理
+	ConflictConcat
+)
+
+// WithOnConflict 设置ToMap遇到重复key时的处理策略, 以及MergeToMap、EncodeInto中多个来源编码出相同key时的处理策略
+func WithOnConflict(policy ConflictPolicy) Option {
+	return func(p *FormParser) {
+		p.onConflict = policy
+	}
+}
+
+// WithMaxKVs 设置单次编码允许产生的KV数量上限, 超过时立即返回ErrTooManyKVs, n<=0表示不限制
+func WithMaxKVs(n int) Option {
+	return func(p *FormParser) {
+		p.maxKVs = n
+	}
+}
+
+// WithMaxValueLen 设置单个value允许的最大字节数, 超过时返回ErrValueTooLong, n<=0表示不限制
+func WithMaxValueLen(n int) Option {
+	return func(p *FormParser) {
+		p.maxValueLen = n
+		p.truncateValue = false
+	}
+}
+
+// WithTruncateValues 设置单个value允许的最大字节数, 超过时截断并追加marker, 截断结果(含marker)不超过n字节
+func WithTruncateValues(n int, marker string) Option {
+	return func(p *FormParser) {
+		if n > 0 && len(marker) >= n {
+			panic(fmt.Sprintf("%s: Truncate marker %q is longer than the limit %d", pkgName, marker, n))
+		}
+		p.maxValueLen = n
+		p.truncateValue = true
+		p.truncateMarker = marker
+	}
+}
+
+// WithEscapeValues 设置ToMap返回的value是否已按query参数规则做URL转义, 便于调用方直接拼接query串
+func WithEscapeValues(escape bool) Option {
+	return func(p *FormParser) {
+		p.escapeValues = escape
+	}
+}
+
+// WithLowercaseKeys 设置是否将最终产生的key(标签解析之后)统一转为小写
+func WithLowercaseKeys(lower bool) Option {
+	return func(p *FormParser) {
+		p.lowercaseKeys = lower
+	}
+}
+
+// BytesFormat []byte(以及encoding.BinaryMarshaler的结果)编码成字符串的方式
+type BytesFormat int
+
+const (
+	// BytesBase64 标准base64编码, 默认方式
+	BytesBase64 BytesFormat = iota
+	// BytesBase64URL URL安全的base64编码
+	BytesBase64URL
+	// BytesHex 小写十六进制编码
+	BytesHex
+)
+
+// WithBytesFormat 设置[]byte编码成字符串的方式
+func WithBytesFormat(f BytesFormat) Option {
+	return func(p *FormParser) {
+		p.bytesFormat = f
+	}
+}
+
+// WithIndexBase 设置slice下标的起始值, 默认为0. 部分云厂商的query风格API要求从1开始, 如"h.1.cpu"
+func WithIndexBase(base int) Option {
+	return func(p *FormParser) {
+		if base < 0 {
+			panic(fmt.Sprintf("%s: Negative index base %d", pkgName, base))
+		}
+		p.indexBase = base
+	}
+}
+
+// KeyStyle 父子字段key的拼接风格
+type KeyStyle int
+
+const (
+	// KeyStyleDotted 以"."拼接, 如"a.b.0.c", 默认风格
+	KeyStyleDotted KeyStyle = iota
+	// KeyStyleBracket Rack/Rails风格, 如"a[b][0][c]"
+	KeyStyleBracket
+	// KeyStyleAuto 自动识别, 同时接受以上两种风格, 仅用于解码
+	KeyStyleAuto
+)
+
+// WithKeyStyle 设置编码时父子字段key的拼接风格, 作用于struct、map以及slice下标(idxfmt选项优先)
+func WithKeyStyle(s KeyStyle) Option {
+	return func(p *FormParser) {
+		if s == KeyStyleAuto {
+			panic(fmt.Sprintf("%s: KeyStyleAuto is only for decoding", pkgName))
+		}
+		p.keyStyle = s
+	}
+}
+
+// WithDecodeKeyStyle 设置解码时key的拆分风格, 默认为KeyStyleAuto.
+// 明确指定风格后另一种风格的分隔符按普通字符处理, 如KeyStyleBracket下"a.b[c]"拆分为"a.b"、"c"
+func WithDecodeKeyStyle(s KeyStyle) Option {
+	return func(p *FormParser) {
+		p.decodeKeyStyle = s
+	}
+}
+
+// WithRepeatedKeys 设置元素为基础类型(含其指针)的slice默认以重复的key编解码, 如"e=1&e=2", 相当于加上"repeat"选项.
+// 设置了idxfmt或index_pad的字段仍按下标编解码
+func WithRepeatedKeys(enabled bool) Option {
+	return func(p *FormParser) {
+		p.repeatedKeys = enabled
+	}
+}
+
+// WithMaxBodyBytes 设置DecodeBody、DecodeRequest读取body的大小上限, 超过时返回ErrBodyTooLarge, n<=0时为默认的10MB
+func WithMaxBodyBytes(n int64) Option {
+	return func(p *FormParser) {
+		p.maxBodyBytes = n
+	}
+}
+
+// WithMaxKeys 设置解码时允许的参数个数上限(重复的key每出现一次计一个), 超过时返回ErrDecodeLimit, n<=0表示不限制.
+// 作用于Decode及以其为基础的DecodeBody、DecodeRequest等, 与WithMaxKVs限制编码结果的数量相对应
+func WithMaxKeys(n int) Option {
+	return func(p *FormParser) {
+		p.maxKeys = n
+	}
+}
+
+// WithMaxKeyDepth 设置解码时单个key允许的最大层数, 如"a.b.0.c"为4层, 超过时返回ErrDecodeLimit, n<=0表示不限制
+func WithMaxKeyDepth(n int) Option {
+	return func(p *FormParser) {
+		p.maxKeyDepth = n
+	}
+}
+
+// WithMaxIndex 设置解码时slice下标(减去WithIndexBase之后)允许的最大值, 超过时返回ErrDecodeLimit, n<=0表示不限制
+func WithMaxIndex(n int) Option {
+	return func(p *FormParser) {
+		p.maxIndex = n
+	}
+}
+
+// WithCaseInsensitiveKeys 设置解码时是否忽略key的大小写匹配字段: 完全相同的key优先,
+// 否则在多个大小写不同的写法中取按字节序最小的一个. map的key保持输入中的原样
+func WithCaseInsensitiveKeys(enabled bool) Option {
+	return func(p *FormParser) {
+		p.foldKeys = enabled
+	}
+}
+
+// WithSemicolonSeparator 设置DecodeBody、DecodeQuery是否同时接受";"作为KV的分隔符(HTML4的旧写法), 如"a=1;b=2"
+func WithSemicolonSeparator(enabled bool) Option {
+	return func(p *FormParser) {
+		p.semicolonSep = enabled
+	}
+}
+
+// WithRootKey 设置顶层对象的key, 如顶层为[]Item时产生"items.0.cpu"而不是"0.cpu", 通常作为ToMap的单次调用选项
+func WithRootKey(name string) Option {
+	return func(p *FormParser) {
+		p.rootKey = name
+	}
+}
+
+// FieldNaming 未设置标签名的字段默认key的生成方式
+type FieldNaming int
+
+const (
+	// NamingAsIs 直接使用Go字段名, 如"CPUCount", 默认方式
+	NamingAsIs FieldNaming = iota
+	// NamingLower 字段名转为小写, 如"cpucount"
+	NamingLower
+	// NamingSnake 字段名转为snake_case, 如"cpu_count"
+	NamingSnake
+)
+
+// WithFieldNaming 设置未设置标签名的字段(如无标签或`zwf:",omitempty"`)默认key的生成方式, 编码、解码一致
+func WithFieldNaming(n FieldNaming) Option {
+	return func(p *FormParser) {
+		p.fieldNaming = n
+	}
+}
+
+// WithRequireTags 设置是否要求所有导出的字段都明确设置标签名, 否则编码时返回ErrMissingTag, 便于统一约束SDK模型的参数名
+func WithRequireTags(require bool) Option {
+	return func(p *FormParser) {
+		p.requireTags = require
+	}
+}
+
+// WithExcludePaths 从最终结果中去掉位于这些路径之下的key, 如"h.*.cpu"去掉"h.0.cpu"、"h.1.cpu"以及其下的所有key.
+// 路径以"."或方括号分隔, "*"匹配任意一段, 与WithKeyStyle无关; 可多次使用, 通常作为单次调用选项
+func WithExcludePaths(paths ...string) Option {
+	return func(p *FormParser) {
+		p.excludePaths = append(p.excludePaths[:len(p.excludePaths):len(p.excludePaths)], paths...)
+		p.exclude = append(p.exclude[:len(p.exclude):len(p.exclude)], compilePaths(paths)...)
+	}
+}
+
+// WithOnlyPaths 最终结果仅保留位于这些路径之下的key, 路径的写法同WithExcludePaths, 两者同时设置时先保留再去掉.
+// 可多次使用, 例如以完整的请求struct只提交需要更新的部分
+func WithOnlyPaths(paths ...string) Option {
+	return func(p *FormParser) {
+		p.onlyPaths = append(p.onlyPaths[:len(p.onlyPaths):len(p.onlyPaths)], paths...)
+		p.only = append(p.only[:len(p.only):len(p.only)], compilePaths(paths)...)
+	}
+}
+
+// WithInterceptor 编码时以fn改写位于path之下的KV, fn返回false时去掉该KV, 如为"auth.*"加上租户前缀或去掉内部字段.
+// path的写法同WithExcludePaths, 在其过滤之后、WithMaxValueLen的检查之前执行; 可多次使用, 按设置的顺序执行,
+// 后面的拦截器以改写后的key匹配
+func WithInterceptor(path string, fn func(kv KV) (KV, bool)) Option {
+	return func(p *FormParser) {
+		if fn == nil {
+			panic(fmt.Sprintf("%s: Nil interceptor for path %q", pkgName, path))
+		}
+		it := interceptor{path: path, pattern: splitKeyStyle(path, KeyStyleAuto), fn: fn}
+		p.interceptors = append(p.interceptors[:len(p.interceptors):len(p.interceptors)], it)
+	}
+}
+
+// HeaderEncoding ToHeader对非ASCII字符的value的处理方式
+type HeaderEncoding int
+
+const (
+	// HeaderRaw 原样输出, 默认方式
+	HeaderRaw HeaderEncoding = iota
+	// HeaderRFC2047 按RFC 2047编码为"=?utf-8?q?...?="
+	HeaderRFC2047
+	// HeaderRFC8187 按RFC 8187编码为"UTF-8''..."
+	HeaderRFC8187
+)
+
+// WithHeaderEncoding 设置ToHeader对非ASCII字符的value的处理方式, 纯ASCII的value总是原样输出
+func WithHeaderEncoding(e HeaderEncoding) Option {
+	return func(p *FormParser) {
+		p.headerEncoding = e
+	}
+}
+
+// WithFieldPredicate 设置判断字段是否参与编码的函数, 与omitempty、omitunless等选项同时生效
+func WithFieldPredicate(fn FieldPredicate) Option {
+	return func(p *FormParser) {
+		p.fieldPredicate = fn
+	}
+}
+
+// WithNumericMapKeys 设置map的key中的数字是否按数值排序, 如"2"排在"10"之前, 默认按字符串排序
+func WithNumericMapKeys(numeric bool) Option {
+	return func(p *FormParser) {
+		if numeric {
+			p.mapKeyLess = numericLess
+		} else {
+			p.mapKeyLess = nil
+		}
+	}
+}
+
+// WithMapKeyLess 设置map的key的排序方式, less的参数为key编码后的字符串, 可用于将签名字段排在最后等特定顺序.
+// 与WithNumericMapKeys互相覆盖, 以后设置的为准, less为nil时恢复默认的字符串升序
+func WithMapKeyLess(less func(a, b string) bool) Option {
+	return func(p *FormParser) {
+		p.mapKeyLess = less
+	}
+}
+
+// ComplexFormat complex64、complex128的编码方式
+type ComplexFormat int
+
+const (
+	// ComplexDefault 按fmt的%v编码, 如"(1+2i)", 默认方式
+	ComplexDefault ComplexFormat = iota
+	// ComplexPair 以逗号分隔实部与虚部, 如"1,2"
+	ComplexPair
+	// ComplexSplit 实部与虚部分别作为子key输出, 如"k.re"="1"、"k.im"="2"
+	ComplexSplit
+	// ComplexError 遇到复数时返回错误
+	ComplexError
+)
+
+// WithComplexFormat 设置复数的编码方式
+func WithComplexFormat(f ComplexFormat) Option {
+	return func(p *FormParser) {
+		p.complexFormat = f
+	}
+}
+
+// WithLocale 设置编码时的Locale, 传给RegisterLocaleType注册的编码器, 通常作为单次调用的选项按目标API传入
+func WithLocale(l Locale) Option {
+	return func(p *FormParser) {
+		p.locale = l
+	}
+}
+
+// WithVersion 设置标签的版本, 如WithVersion("v2")使字段的`zwf_v2:"..."`标签优先于`zwf:"..."`, 为空时只使用后者.
+// 编码与解码均按此选用标签, 通常作为单次调用的选项按目标API的版本传入
+func WithVersion(version string) Option {
+	return func(p *FormParser) {
+		p.version = version
+	}
+}
+
+// WithTimeLayout 设置time.Time默认的编解码layout, 可以是time包的常量名(如"RFC1123")或者layout本身, 默认为RFC3339Nano.
+// 字段的format、layouts选项优先
+func WithTimeLayout(layout string) Option {
+	if named, ok := namedLayouts[layout]; ok {
+		layout = named
+	}
+	return func(p *FormParser) {
+		p.timeLayout = layout
+	}
+}
+
+// BoolLexicon bool的编解码词表: 编码时true、false分别输出为True、False,
+// 解码时只接受True、False以及TrueValues、FalseValues中的字符串(不区分大小写)
+type BoolLexicon struct {
+	True, False             string
+	TrueValues, FalseValues []string
+}
+
+// 常用的bool词表
+var (
+	BoolOnOff   = BoolLexicon{True: "on", False: "off"}
+	BoolYesNo   = BoolLexicon{True: "yes", False: "no"}
+	BoolOneZero = BoolLexicon{True: "1", False: "0"}
+)
+
+// parse 按词表解码s
+func (l *BoolLexicon) parse(s string) (bool, error) {
+	for _, t := range append([]string{l.True}, l.TrueValues...) {
+		if strings.EqualFold(s, t) {
+			return true, nil
+		}
+	}
+	for _, f := range append([]string{l.False}, l.FalseValues...) {
+		if strings.EqualFold(s, f) {
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("%s: Invalid bool %q", pkgName, s)
+}
+
+// WithBoolLexicon 设置bool的编解码词表, 如WithBoolLexicon(BoolOnOff), 未设置时按strconv.FormatBool、strconv.ParseBool处理.
+// True与False不能为空, 同一个字符串不能同时表示true和false
+func WithBoolLexicon(l BoolLexicon) Option {
+	if l.True == "" || l.False == "" {
+		panic(fmt.Sprintf("%s: Missing True or False of BoolLexicon", pkgName))
+	}
+	l.TrueValues = append([]string(nil), l.TrueValues...)
+	l.FalseValues = append([]string(nil), l.FalseValues...)
+	for _, t := range append([]string{l.True}, l.TrueValues...) {
+		for _, f := range append([]string{l.False}, l.FalseValues...) {
+			if strings.EqualFold(t, f) {
+				panic(fmt.Sprintf("%s: %q of BoolLexicon is both true and false", pkgName, t))
+			}
+		}
+	}
+	return func(p *FormParser) {
+		p.boolLexicon = &l
+	}
+}
+
+// WithRejectUintptr 设置遇到uintptr、unsafe.Pointer类型的值时返回错误, 默认忽略这些字段
+func WithRejectUintptr(reject bool) Option {
+	return func(p *FormParser) {
+		p.rejectUintptr = reject
+	}
+}
+
+// WithTimeLocation 设置time.Time在格式化之前统一转换到的时区, 如time.UTC, 字段的tz选项(如`zwf:"t,tz=UTC"`)优先
+func WithTimeLocation(loc *time.Location) Option {
+	return func(p *FormParser) {
+		p.timeLoc = loc
+	}
+}
+
+// WithRecover 设置是否将编码、解码过程中残留的panic(如自定义编码器、MarshalBinary中的panic)转换为*PanicError返回,
+// 适用于不能因异常的数据模型而崩溃的服务
+func WithRecover(enabled bool) Option {
+	return func(p *FormParser) {
+		p.recover = enabled
+	}
+}
+
+// WithOmitEmptyStructs 设置是否忽略所有零值的子struct(含指向零值struct的指针及time.Time等类型), 效果同为这些字段加上omitempty.
+// 字段均被忽略的子struct本身不会产生任何KV, 因此无需该选项
+func WithOmitEmptyStructs(omit bool) Option {
+	return func(p *FormParser) {
+		p.omitEmptyStructs = omit
+	}
+}
+
+// WithJSONMarshaler 设置是否将实现了json.Marshaler的类型按MarshalJSON的结果编码, 结果为JSON字符串时取其内容.
+// 优先级低于RegisterType、driver.Valuer、encoding.BinaryMarshaler等规则, 便于复用已为JSON实现的类型
+func WithJSONMarshaler(enabled bool) Option {
+	return func(p *FormParser) {
+		p.jsonMarshaler = enabled
+	}
+}
+
+// WithEncodeCache 设置编码结果的缓存, 参见EncodeCache. 为nil时不缓存
+func WithEncodeCache(c *EncodeCache) Option {
+	return func(p *FormParser) {
+		p.cache, p.cacheScope = c, new(cacheScope)
+	}
+}
+
+// WithSpaceAsPercent20 设置query转义时空格是否输出为"%20"(默认输出为"+"),
+// 作用于WithEscapeValues、BuildURL/BuildURLTemplate的query部分、EncodeBody及QueryEscape
+func WithSpaceAsPercent20(enabled bool) Option {
+	return func(p *FormParser) {
+		p.spacePercent20 = enabled
+	}
+}
+
+// WithEscapeChars 设置query转义时额外需要转义的字符, 如"~"; 作用范围同WithSpaceAsPercent20
+func WithEscapeChars(chars string) Option {
+	checkEscapeChars("WithEscapeChars", chars)
+	return func(p *FormParser) {
+		p.escapeChars = chars
+	}
+}
+
+// WithKeepChars 设置query转义时保持原样的字符, 如",:"; 不允许包含"%"、空格及"&=+#", 作用范围同WithSpaceAsPercent20.
+// 与WithEscapeChars同时包含的字符保持原样
+func WithKeepChars(chars string) Option {
+	checkEscapeChars("WithKeepChars", chars)
+	return func(p *FormParser) {
+		p.keepChars = chars
+	}
+}
+
+// withRedact 开启ToMapRedacted的脱敏
+func withRedact() Option {
+	return func(p *FormParser) {
+		p.redact = true
+	}
+}
+
+// withSink 设置EncodeTo的sink
+func withSink(sink Sink) Option {
+	return func(p *FormParser) {
+		p.sink = sink
+		p.depth = 0
+		p.sent = 0
+	}
+}
+
+// withContext 设置EncodeContext的ctx
+func withContext(ctx context.Context) Option {
+	return func(p *FormParser) {
+		p.ctx = ctx
+		p.steps = 0
+	}
+}
@@ -1,19 +1,40 @@
 package formparser
 
 import (
+	"context"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 const pkgName = "formparser"
 
+// ErrTooManyKVs 编码产生的KV数量超过WithMaxKVs设置的上限
+var ErrTooManyKVs = errors.New(pkgName + ": Too many KVs")
+
+// ErrValueTooLong 编码产生的value长度超过WithMaxValueLen设置的上限
+var ErrValueTooLong = errors.New(pkgName + ": Value too long")
+
+// ErrMissingTag 开启WithRequireTags时, 导出的字段没有设置标签名
+var ErrMissingTag = errors.New(pkgName + ": Missing tag")
+
+// defaultInlineKeyword 默认的"不继承父辈标签"关键字
+const defaultInlineKeyword = "..."
+
 // FormParser 将结构体对象转换成HTTP请求所需的KV形式, 只处理struct及*struct类型
 //
-// > 关键字"..." 表示该字段的子字段不继承父辈的标签, 该方式可用于struct，map类型
+// > 关键字"..." 表示该字段的子字段不继承父辈的标签, 该方式可用于struct，map，slice类型.
+//   用于slice时每个元素仅以下标作为前缀, 如"0.cpu"; 用于map时每个值仅以map的key作为前缀,
+//   如map[string]Info产生"m1.cpu"而不是"tag.m1.cpu".
+//   可通过WithInlineKeywords追加同义的关键字, 如"inline"; 也可以使用等价且更易读的选项`zwf:",inline"`或`zwf:",flatten"`
 // 	 例如:
 // 	 type Demo1 struct {
 //	 		Auth 		`zwf:"..."`
@@ -31,7 +52,44 @@ const pkgName = "formparser"
 // 	 Demo2: "auth.ak"="xxx"
 //
 //
-// > 关键字"join" 可以将[]string进行按英文逗号join操作, 参见parser_test.go的TestParse例子
+// > 默认以"."拼接父子字段的key, 如"h.0.cpu"; 可通过WithKeyStyle(KeyStyleBracket)改为Rails风格的"h[0][cpu]".
+//   [][]T等多维slice逐层展开, 如"s.0.1"或"s[0][1]", 解码时按同样的方式还原
+//
+// > 关键字"join" 可以将[]string进行按英文逗号join操作, 参见parser_test.go的TestParse例子; 自定义的选项参见RegisterTagOption
+//
+// > 选项"repeat" 将slice的每个元素以同一个key重复输出, 如"e=1&e=2", 解码时只接受重复的key;
+//   WithRepeatedKeys对所有未设置idxfmt、index_pad的基础类型slice生效. ToMap等按key去重的接口只保留其中一个值, 应使用Encode、EncodeTo、BuildURL或ToFlatJSON
+//
+// > 选项"slice" 选用其它的展开方式, 如`zwf:"tags,slice=joined"`输出"tags=a,b", 参见SliceStrategy及RegisterSliceStrategy
+//
+// > 选项"style=form"及"explode=true|false" 遵循OpenAPI 3的query参数序列化方式, explode默认为true:
+//   slice输出"id=3&id=4"或"id=3,4", struct、map输出"R=100&G=200"(同inline)或"color=R,100,G,200";
+//   选项"style=deepObject" 使struct、map不论WithKeyStyle如何都输出"filter[color]=red&filter[size]=L", 解码时需使用KeyStyleAuto或KeyStyleBracket
+//
+// > []byte默认按base64编码, 选项"raw"将其原样作为字符串输出, 适用于存放文本的[]byte
+//
+// > 选项"runes" 将[]rune、[N]rune按UTF-8字符串输出, 解码时同样按字符串还原, 如`zwf:"name,runes"`.
+//   注意: 这里没有按类型自动识别[]rune. rune是int32的别名, 反射无法区分[]rune与[]int32, 自动识别会改变已有[]int32字段的输出,
+//   因此需显式带上该选项; 未带选项时[]rune与[]int32一样逐个输出数值, 如"name.0=20320&name.1=22909"
+//
+// > 选项"omitempty" 忽略空值(false、0、nil指针、nil接口、长度为0的array/slice/map/string、零值的struct),
+//   选项"omitzero" 仅忽略真正的零值, 非nil的空slice、map会被保留. 用法如`zwf:"name,omitempty"`
+//
+// > 选项"omitunless" 仅当同级字段等于指定值时才输出, 如`zwf:"level,omitunless=type=advanced"`,
+//   也可以通过WithFieldPredicate以代码的方式决定
+//
+// > struct实现了BeforeEncode() error时在编码其字段前调用, 实现了AfterEncode(kvs []KV) ([]KV, error)时
+//   以其编码结果(key不含父辈前缀)调用, 可用于规范化数据或追加校验和等参数
+//
+// > 选项"alias" 同时以别名输出同一个值, 解码时也接受别名, 多个别名以"|"分隔, 如`zwf:"access_key,alias=ak"`;
+//   选项"accept" 声明只在解码时接受的别名, 编码时不输出, 如`zwf:"page_size,accept=pageSize"`
+//
+// > 通过WithVersion选用版本后, 优先使用名为"<tag>_<version>"的标签, 使同一个struct可以对应多个API版本的参数,
+//   如`zwf:"name" zwf_v2:"Name"`; 某个版本中不存在的字段可以用忽略标志去掉, 如`zwf_v2:"-"`
+//
+// > 选项"sensitive" 标记敏感字段, ToMapRedacted将其值替换为"******", 或在sensitive=drop时去掉, 其余接口不受影响
+//
+// > 以"/"开头的标签为绝对key, 不论嵌套多深都输出在顶层, 如`zwf:"/Signature"`得到"Signature", 仅作用于编码
 //
 type FormParser struct {
 	// 用于转换的tag名字, 类似于json序列化的json tag
@@ -40,15 +98,169 @@ type FormParser struct {
 	// 用于忽略转换的符号, 类似于json序列化的"-"
 	ignoreFlag string
 
+	// 表示子字段不继承父辈标签的关键字集合
+	inlineKeywords map[string]struct{}
+
+	// 重复key的处理策略
+	onConflict ConflictPolicy
+
+	// 单次编码允许产生的KV数量上限, <=0表示不限制
+	maxKVs int
+
+	// 单个value允许的最大字节数, <=0表示不限制
+	maxValueLen int
+	// 超过上限时是否截断(否则返回错误), 以及截断后追加的标记
+	truncateValue  bool
+	truncateMarker string
+
+	// ToMap是否返回已做URL转义的value
+	escapeValues bool
+
+	// query转义的规则: 空格是否输出为"%20", 额外转义及保持原样的字符
+	spacePercent20 bool
+	escapeChars    string
+	keepChars      string
+
+	// 是否将最终的key统一转为小写
+	lowercaseKeys bool
+
+	// []byte的编码方式
+	bytesFormat BytesFormat
+
+	// slice下标的起始值
+	indexBase int
+
+	// 顶层对象的key, 为空时顶层struct的字段、slice的下标直接作为key
+	rootKey string
+
+	// 编码时key的拼接风格, 以及解码时key的拆分风格
+	keyStyle       KeyStyle
+	decodeKeyStyle KeyStyle
+
+	// 基础类型的slice是否默认以重复的key编解码
+	repeatedKeys bool
+
+	// DecodeBody、DecodeRequest读取body的大小上限, <=0时为默认值
+	maxBodyBytes int64
+
+	// 解码时参数的最大个数、key的最大层数及slice下标的最大值, <=0表示不限制
+	maxKeys     int
+	maxKeyDepth int
+	maxIndex    int
+
+	// 解码时是否忽略key的大小写
+	foldKeys bool
+
+	// 解码时是否同时接受";"作为KV的分隔符
+	semicolonSep bool
+
+	// 未设置标签名的字段默认key的生成方式
+	fieldNaming FieldNaming
+
+	// 是否要求所有导出的字段都设置标签名
+	requireTags bool
+
+	// 需要从最终结果中去掉的key路径, 及其拆分后的形式
+	excludePaths []string
+	exclude      []pathPattern
+
+	// 不为空时最终结果仅保留这些路径之下的key
+	onlyPaths []string
+	only      []pathPattern
+
+	// 按key路径改写或去掉KV的拦截器, 按设置的顺序执行
+	interceptors []interceptor
+
+	// ToHeader对非ASCII字符的value的处理方式
+	headerEncoding HeaderEncoding
+
+	// 决定字段是否参与编码的判断函数
+	fieldPredicate FieldPredicate
+
+	// map的key的排序方式, 为nil时按字符串升序
+	mapKeyLess func(a, b string) bool
+
+	// 复数的编码方式
+	complexFormat ComplexFormat
+
+	// 遇到uintptr、unsafe.Pointer时是否返回错误(否则忽略)
+	rejectUintptr bool
+
+	// time.Time编码前转换到的时区, 为nil时保持原样
+	timeLoc *time.Location
+
+	// 是否将编码、解码过程中的panic转换为PanicError
+	recover bool
+
+	// 是否忽略所有零值的子struct
+	omitEmptyStructs bool
+
+	// 是否按json.Marshaler的结果编码实现了该接口的类型
+	jsonMarshaler bool
+
+	// 选用的标签版本, 非空时优先读取名为"<tag>_<version>"的标签
+	version string
+
+	// 通过WithStyle选用的Style的名字
+	style string
+
+	// time.Time默认的layout, 为空时为time.RFC3339Nano
+	timeLayout string
+
+	// bool的编解码词表, 为nil时按strconv处理
+	boolLexicon *BoolLexicon
+
+	// 编码结果的缓存, 为nil时不缓存. 带有opts的单次调用不使用缓存.
+	// cacheScope在共用同一缓存的解析器之间区分各自的结果
+	cache      *EncodeCache
+	cacheScope *cacheScope
+
+	// 通过RegisterType注册的类型编码器
+	typeEncoders map[reflect.Type]TypeEncoder
+	// 与typeEncoders相对应的解码器, 目前由RegisterEnum注册
+	typeDecoders map[reflect.Type]FormatDecoder
+	// 通过RegisterLocaleType注册的类型编码器, 以及传给它们的Locale
+	localeEncoders map[reflect.Type]LocaleEncoder
+	locale         Locale
+
+	// 通过RegisterFormat注册的表示方式, 按类型及名字索引
+	formats map[reflect.Type]map[string]typeFormat
+
+	// 通过RegisterEncoder注册的编码器, 按名字索引
+	namedEncoders map[string]TypeEncoder
+
+	// 通过RegisterTagOption注册的标签选项, 按名字索引
+	tagHandlers map[string]TagOptionHandler
+
+	// 通过RegisterSliceStrategy、RegisterSliceType注册的slice展开方式, 以及WithSliceStrategy设置的默认方式
+	sliceStrategies map[string]SliceStrategy
+	sliceTypes      map[reflect.Type]SliceStrategy
+	slicePreset     SliceStrategy
+
+	// EncodeContext的ctx, 以及已追加KV的次数, 仅存在于单次调用的副本中
+	ctx   context.Context
+	steps int
+
+	// 是否按sensitive选项脱敏, 仅存在于ToMapRedacted的副本中
+	redact bool
+
+	// EncodeTo的sink, 当前所在的嵌套深度(根对象为0)以及已输出的KV数, 仅存在于单次调用的副本中
+	sink  Sink
+	depth int
+	sent  int
+
+	// Decode收集到的缺失的required参数, 仅存在于单次调用的副本中
+	missing *[]string
+
 	// 编码器
 	encoders map[reflect.Kind]kindEncoder
 }
 
-func Default() *FormParser {
-	return New("zwf", "-")
+func Default(opts ...Option) *FormParser {
+	return New("zwf", "-", opts...)
 }
 
-func New(tag, ignoreFlag string) *FormParser {
+func New(tag, ignoreFlag string, opts ...Option) *FormParser {
 	if len(tag) <= 0 {
 		panic(fmt.Sprintf("%s: Missing `tag` value", pkgName))
 	}
@@ -56,36 +268,284 @@ func New(tag, ignoreFlag string) *FormParser {
 		panic(fmt.Sprintf("%s: Missing `ignoreFlag` value", pkgName))
 	}
 	p := FormParser{
-		tag:        tag,
-		ignoreFlag: ignoreFlag,
+		tag:             tag,
+		ignoreFlag:      ignoreFlag,
+		inlineKeywords:  map[string]struct{}{defaultInlineKeyword: {}},
+		typeEncoders:    make(map[reflect.Type]TypeEncoder),
+		typeDecoders:    make(map[reflect.Type]FormatDecoder),
+		localeEncoders:  make(map[reflect.Type]LocaleEncoder),
+		formats:         make(map[reflect.Type]map[string]typeFormat),
+		namedEncoders:   make(map[string]TypeEncoder),
+		tagHandlers:     make(map[string]TagOptionHandler),
+		sliceStrategies: make(map[string]SliceStrategy),
+		sliceTypes:      make(map[reflect.Type]SliceStrategy),
+		decodeKeyStyle:  KeyStyleAuto,
+	}
+	p.registerTimeFormats()
+	p.registerNamedEncoders()
+	p.registerTagOptions()
+	p.registerSliceStrategies()
+	for _, opt := range opts {
+		opt(&p)
 	}
 	return p.init()
 }
 
-// ToMap the param v should be either reflect.ValueOf(struct) or reflect.ValueOf(*struct)
-func (p *FormParser) ToMap(v reflect.Value) (map[string]string, error) {
-	kvs, err := p.parse(v)
+// ToMap the param v should be reflect.ValueOf(struct), reflect.ValueOf(slice), reflect.ValueOf(array),
+// reflect.ValueOf(map) or a pointer to them. Top-level slice elements are keyed by index ("0.cpu") and
+// map elements by map key ("region"), or under the name set by WithRootKey ("items.0.cpu").
+// opts only apply to this call
+func (p *FormParser) ToMap(v reflect.Value, opts ...Option) (map[string]string, error) {
+	p = p.with(opts)
+	kvs, err := p.marshal(v)
 	if err != nil {
 		return nil, err
 	}
 
-	m := make(map[string]string)
-	for _, kv := range kvs {
-		m[kv.K] = kv.V
+	if kvs, err = p.resolveConflicts(kvs); err != nil {
+		return nil, err
+	}
+	m := make(map[string]string, len(kvs))
+	if err = emit(kvs, MapSink(m)); err != nil {
+		return nil, err
+	}
+	if p.escapeValues {
+		for k, v := range m {
+			m[k] = p.queryEscape(v)
+		}
 	}
 	return m, err
 }
 
+// resolveConflicts 按WithOnConflict设置的策略处理重复的key, 保留每个key首次出现的位置
+func (p *FormParser) resolveConflicts(kvs []KV) ([]KV, error) {
+	rt := make([]KV, 0, len(kvs))
+	pos := make(map[string]int, len(kvs))
+	for _, kv := range kvs {
+		i, exist := pos[kv.K]
+		if !exist {
+			pos[kv.K] = len(rt)
+			rt = append(rt, kv)
+			continue
+		}
+		switch p.onConflict {
+		case ConflictKeepFirst:
+		case ConflictError:
+			return nil, fmt.Errorf("%s: Key %q is encoded more than once, values %q and %q", pkgName, kv.K, rt[i].V, kv.V)
+		default:
+			rt[i].V = kv.V
+		}
+	}
+	return rt, nil
+}
+
 func (p *FormParser) Debug(v reflect.Value) {
-	kvs, err := p.parse(v)
+	kvs, err := p.marshal(v)
 	if err != nil {
-		panic(err.Error())
+		fmt.Printf("%s: Debug failed, %v\n", pkgName, err)
+		return
 	}
 	for _, kv := range kvs {
 		fmt.Printf("%10s : %s\n", kv.K, kv.V)
 	}
 }
 
+// with 返回应用了opts的副本, 用于仅对单次调用生效的选项, p本身不受影响
+func (p *FormParser) with(opts []Option) *FormParser {
+	if len(opts) == 0 {
+		return p
+	}
+	cp := *p
+	cp.cache = nil
+	cp.inlineKeywords = make(map[string]struct{}, len(p.inlineKeywords))
+	for k := range p.inlineKeywords {
+		cp.inlineKeywords[k] = struct{}{}
+	}
+	for _, opt := range opts {
+		opt(&cp)
+	}
+	return cp.init()
+}
+
+// Encode 编码v并返回KV列表, v的要求同ToMap, 也可以是reflect.Value. opts仅对本次调用生效.
+// KV的顺序是稳定的: struct按字段声明顺序, map按key编码后的字符串升序(可通过WithNumericMapKeys、WithMapKeyLess修改), slice按下标顺序,
+// 相同的输入总是得到相同的输出, 可直接用于签名或golden测试
+func (p *FormParser) Encode(v interface{}, opts ...Option) ([]KV, error) {
+	p = p.with(opts)
+	return p.marshal(valueOf(v))
+}
+
+// EncodeContext 同Encode, 但在遍历过程中定期检查ctx, ctx被取消或超时后立即返回ctx.Err(),
+// 使得编码巨大的结构时也能遵守请求的deadline
+func (p *FormParser) EncodeContext(ctx context.Context, v interface{}, opts ...Option) ([]KV, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	cp := p.with(append(opts[:len(opts):len(opts)], withContext(ctx)))
+	if len(opts) == 0 {
+		cp.cache = p.cache // withContext不属于调用方的opts, 仍可使用缓存
+	}
+	return cp.marshal(valueOf(v))
+}
+
+// EncodeValue 将单个值(标量、slice、map或struct)编码到调用方指定的key之下, 无需为其定义struct.
+// v也可以是reflect.Value. opts仅对本次调用生效
+func (p *FormParser) EncodeValue(key string, v interface{}, opts ...Option) (_ []KV, err error) {
+	if key == "" {
+		return nil, errors.New("Param key is empty")
+	}
+	p = p.with(opts)
+	defer p.recoverPanic(&err)
+	kvs, err := p.encode(valueOf(v), key, "")
+	if err != nil {
+		return nil, err
+	}
+	return p.finish(kvs)
+}
+
+// valueOf 将v转换为reflect.Value, v本身为reflect.Value时直接返回
+func valueOf(v interface{}) reflect.Value {
+	if rv, ok := v.(reflect.Value); ok {
+		return rv
+	}
+	return reflect.ValueOf(v)
+}
+
+// marshal 编码顶层对象, 并对最终产生的KV做统一的后置处理
+func (p *FormParser) marshal(rv reflect.Value) (_ []KV, err error) {
+	defer p.recoverPanic(&err)
+	if kvs, ok := p.cache.load(p.cacheScope, rv); ok {
+		return kvs, nil
+	}
+	kvs, err := p.encodeRoot(rv)
+	if err != nil {
+		return nil, err
+	}
+	if kvs, err = p.finish(kvs); err != nil {
+		return nil, err
+	}
+	p.cache.store(p.cacheScope, rv, kvs)
+	return kvs, nil
+}
+
+// stream 同marshal, 但根对象的每个字段、元素编码完成后立即输出到p.sink, 无需先在内存中生成全部KV.
+// 根对象实现了AfterEncoder时需要其全部KV, 只能在编码完成后一起输出
+func (p *FormParser) stream(rv reflect.Value) (err error) {
+	defer p.recoverPanic(&err)
+	if root, _, err := p.rootValue(rv); err == nil {
+		if _, ok := implements(root, afterEncoderType); ok {
+			p.depth++
+		}
+	}
+	kvs, err := p.encodeRoot(rv)
+	if err != nil {
+		return err
+	}
+	p.depth = 0
+	return p.send(kvs) // 未经appendKVs输出的KV, 如WithRootKey下整体编码的[]byte
+}
+
+// send 对根对象的一批KV做后置处理后输出到p.sink
+func (p *FormParser) send(kvs []KV) error {
+	if p.sent += len(kvs); p.maxKVs > 0 && p.sent > p.maxKVs {
+		return fmt.Errorf("%w, limit is %d", ErrTooManyKVs, p.maxKVs)
+	}
+	kvs, err := p.finish(kvs)
+	if err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		if err := p.sink.Add(kv.K, kv.V); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finish 对编码产生的全部KV做统一的后置处理
+func (p *FormParser) finish(kvs []KV) (_ []KV, err error) {
+	keep := p.pathFilter()
+	rt := kvs[:0]
+	for _, kv := range kvs {
+		if p.lowercaseKeys {
+			kv.K = strings.ToLower(kv.K)
+		}
+		if keep != nil && !keep(kv.K) {
+			continue
+		}
+		var ok bool
+		if kv, ok = p.intercept(kv); !ok {
+			continue
+		}
+		if kv.V, err = p.limitValue(kv); err != nil {
+			return nil, err
+		}
+		rt = append(rt, kv)
+	}
+	return rt, nil
+}
+
+// encodeRoot 编码顶层对象. 顶层为slice、array时各元素以下标为key, 为map时各元素以map的key为key,
+// 设置了WithRootKey时所有key都位于该名字之下
+func (p *FormParser) encodeRoot(rv reflect.Value) ([]KV, error) {
+	rv, tagK, err := p.rootValue(rv)
+	if err != nil {
+		return nil, err
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		if tagK == "" {
+			return p.parse(rv)
+		}
+		return p.encodeStruct(rv, tagK, "")
+	case reflect.Slice, reflect.Array:
+		return p.encodeSlice(rv, tagK, "")
+	default:
+		return p.encodeMap(rv, tagK, "")
+	}
+}
+
+// rootValue 校验顶层对象并确定其标签: struct默认无标签, slice、array、map默认内联
+func (p *FormParser) rootValue(rv reflect.Value) (reflect.Value, string, error) {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		return rv, p.rootKey, nil
+	case reflect.Slice, reflect.Array:
+		if p.rootKey != "" {
+			return rv, p.rootKey, nil
+		}
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv, "", errors.New("Param obj is invalid, WithRootKey is needed for []byte")
+		}
+		return rv, defaultInlineKeyword, nil
+	case reflect.Map:
+		if p.rootKey != "" {
+			return rv, p.rootKey, nil
+		}
+		return rv, defaultInlineKeyword, nil
+	}
+	return rv, "", errors.New("Param obj is invalid, struct, slice, array, map or non-nil pointer to them is needed")
+}
+
+// limitValue 检查value长度是否超过WithMaxValueLen/WithTruncateValues设置的上限
+func (p *FormParser) limitValue(kv KV) (string, error) {
+	if p.maxValueLen <= 0 || len(kv.V) <= p.maxValueLen {
+		return kv.V, nil
+	}
+	if !p.truncateValue {
+		return "", fmt.Errorf("%w, key %q has %d bytes, limit is %d", ErrValueTooLong, kv.K, len(kv.V), p.maxValueLen)
+	}
+	// 截断时保证不切开UTF-8字符, 且加上标记后仍不超过上限
+	cut := p.maxValueLen - len(p.truncateMarker)
+	for cut > 0 && !utf8.RuneStart(kv.V[cut]) {
+		cut--
+	}
+	return kv.V[:cut] + p.truncateMarker, nil
+}
+
 func (p *FormParser) parse(rv reflect.Value) ([]KV, error) {
 	for rv.Kind() != reflect.Struct {
 		if rv.Kind() == reflect.Ptr {
@@ -94,50 +554,269 @@ func (p *FormParser) parse(rv reflect.Value) ([]KV, error) {
 		}
 		return nil, errors.New("Param obj is invalid, struct or non-nil *struct is needed")
 	}
+	return p.encodeFields(rv, "")
+}
 
+// encodeFields 编码struct的各字段, 字段的key直接生成在prefix之下, 无需逐层改写
+func (p *FormParser) encodeFields(rv reflect.Value, prefix string) ([]KV, error) {
+	if err := beforeEncode(rv); err != nil {
+		return nil, err
+	}
 	var kvs []KV
+	err := p.eachField(rv, prefix, func(field reflect.Value, fieldK string, opts tagOptions) error {
+		// 获取字段值
+		fieldKVs, err := p.encode(field, fieldK, opts)
+		if err != nil {
+			return err
+		}
+		if p.redact {
+			if fieldKVs, err = redactKVs(fieldKVs, opts); err != nil {
+				return err
+			}
+		}
+		kvs, err = p.appendKVs(kvs, fieldKVs)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := implements(rv, afterEncoderType); !ok {
+		return kvs, nil
+	}
+	return p.relativeTo(prefix, kvs, func(kvs []KV) ([]KV, error) {
+		return afterEncode(rv, kvs)
+	})
+}
+
+// relativeTo 以相对于prefix的key调用fn, 再为fn的结果加上prefix, 使AfterEncode看到的key不含父辈前缀.
+// 不在prefix之下的key(如绝对key)原样传给fn, 也不再加上prefix
+func (p *FormParser) relativeTo(prefix string, kvs []KV, fn func([]KV) ([]KV, error)) ([]KV, error) {
+	if prefix == "" {
+		return fn(kvs)
+	}
+	abs := make(map[string]bool)
+	for i, kv := range kvs {
+		if rel, ok := p.relativeKey(prefix, kv.K); ok {
+			kvs[i].K = rel
+		} else {
+			abs[kv.K] = true
+		}
+	}
+	kvs, err := fn(kvs)
+	if err != nil {
+		return nil, err
+	}
+	for i, kv := range kvs {
+		if !abs[kv.K] {
+			kvs[i].K = p.joinKey(prefix, kv.K)
+		}
+	}
+	return kvs, nil
+}
+
+// relativeKey 是joinKey的逆过程, 由完整的key得到相对于prefix的key, ok为false表示key不在prefix之下
+func (p *FormParser) relativeKey(prefix, key string) (string, bool) {
+	if p.keyStyle != KeyStyleBracket {
+		if strings.HasPrefix(key, prefix+".") {
+			return key[len(prefix)+1:], true
+		}
+		return "", false
+	}
+	if !strings.HasPrefix(key, prefix+"[") {
+		return "", false
+	}
+	rest := key[len(prefix)+1:]
+	i := strings.IndexByte(rest, ']')
+	if i < 0 {
+		return "", false
+	}
+	return rest[:i] + rest[i+1:], true
+}
+
+// eachField 依次处理struct中需要编码的字段, 跳过指定标签、omitempty/omitzero以及缺省(nil)的字段,
+// 传给fn的字段已消除指针及接口, key为字段在prefix之下的完整key; 带有alias选项的字段对每个别名再调用一次fn
+func (p *FormParser) eachField(rv reflect.Value, prefix string, fn func(field reflect.Value, fieldK string, opts tagOptions) error) error {
 	for i := 0; i < rv.NumField(); i++ {
-		// 过滤掉缺省的数据
+		// 过滤掉未导出的字段, 与encoding/json一致, 嵌入的struct仍展开其导出字段
+		sf := rv.Type().Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		// 过滤掉指定标签的数据
+		tagK, opts, drop := p.fieldTag(sf)
+		if drop {
+			continue
+		}
+		if p.requireTags && sf.PkgPath == "" && !hasTagName(p.structTag(sf)) {
+			return wrapFieldError(fmt.Errorf("%w %q", ErrMissingTag, p.tag), rv.Type(), sf.Name, p.childKey(prefix, tagK))
+		}
+		// 过滤掉omitempty/omitzero的数据
 		field := rv.Field(i)
-		for field.Kind() == reflect.Ptr {
-			field = field.Elem() // 消除指针
+		if isOmitted(field, opts) {
+			continue
+		}
+		// 过滤掉不满足条件的数据
+		if ok, err := p.included(rv, i, opts); err != nil || !ok {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		// 过滤掉缺省的数据
+		for field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface {
+			field = field.Elem() // 消除指针及接口
 		}
 		if field.Kind() == reflect.Invalid {
 			continue
 		}
-		// 过滤掉指定标签的数据
-		tagK, drop := p.fieldTag(rv.Type().Field(i))
-		if drop {
+		// 开启WithOmitEmptyStructs时, 零值的子struct视同带有omitempty
+		if p.omitEmptyStructs && field.Kind() == reflect.Struct && field.IsZero() {
 			continue
 		}
+		fieldK := p.childKey(prefix, tagK)
+		if err := fn(field, fieldK, opts); err != nil {
+			return wrapFieldError(err, rv.Type(), sf.Name, fieldK)
+		}
+		// 同一个值在每个别名下各编码一次
+		for _, alias := range opts.aliases() {
+			aliasK := p.childKey(prefix, alias)
+			if err := fn(field, aliasK, opts); err != nil {
+				return wrapFieldError(err, rv.Type(), sf.Name, aliasK)
+			}
+		}
+	}
+	return nil
+}
 
-		// 获取字段值
-		kvs = append(kvs, p.encode(field, tagK)...)
+func (p *FormParser) fieldTag(f reflect.StructField) (tag string, opts tagOptions, drop bool) {
+	tag = p.structTag(f)
+	if tag == p.ignoreFlag {
+		return "", "", true
 	}
-	return kvs, nil
+	tag, opts = parseTag(tag)
+	if opts.inline() || explodedObject(f.Type, opts) {
+		return defaultInlineKeyword, opts, false
+	}
+	if tag == "" {
+		tag = p.defaultName(f.Name)
+	}
+	return tag, opts, false
+}
+
+// structTag 返回字段的标签; 设置WithVersion后优先使用该版本的标签, 如`zwf_v2:"Name"`, 没有时使用`zwf:"name"`
+func (p *FormParser) structTag(f reflect.StructField) string {
+	if p.version != "" {
+		if tag, ok := f.Tag.Lookup(p.tag + "_" + p.version); ok {
+			return tag
+		}
+	}
+	return f.Tag.Get(p.tag)
+}
+
+// defaultName 按WithFieldNaming设置的方式由字段名生成默认的key
+func (p *FormParser) defaultName(name string) string {
+	switch p.fieldNaming {
+	case NamingLower:
+		return strings.ToLower(name)
+	case NamingSnake:
+		return snakeCase(name)
+	}
+	return name
+}
+
+// snakeCase 将驼峰式的字段名转为snake_case, 连续的大写视为一个单词, 如"HTTPServerID"转为"http_server_id"
+func snakeCase(name string) string {
+	rs := []rune(name)
+	var b strings.Builder
+	for i, r := range rs {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := rs[i-1]
+			nextLower := i+1 < len(rs) && unicode.IsLower(rs[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextLower {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// childKey 子字段在prefix之下的完整key. 标签以"/"开头时为绝对key, 忽略prefix; 标签为inline关键字时不增加层级, 直接沿用prefix;
+// prefix为空时保留该关键字, 以便其子字段同样不带前缀
+func (p *FormParser) childKey(prefix, tagK string) string {
+	if strings.HasPrefix(tagK, "/") { // 绝对key, 不论嵌套多深都位于顶层
+		return tagK[1:]
+	}
+	if prefix != "" && p.isInline(tagK) {
+		return prefix
+	}
+	return p.joinKey(prefix, tagK)
 }
 
-func (p *FormParser) fieldTag(f reflect.StructField) (tag string, drop bool) {
-	tag = f.Tag.Get(p.tag)
-	switch tag {
-	case p.ignoreFlag:
-		drop = true
-	case "":
-		tag = f.Name
+// isInline 判断标签是否为"不继承父辈标签"的关键字
+func (p *FormParser) isInline(tagK string) bool {
+	_, ok := p.inlineKeywords[tagK]
+	return ok
+}
+
+// ctxCheckInterval EncodeContext每追加多少次KV检查一次ctx
+const ctxCheckInterval = 256
+
+// appendKVs 追加KV, 并检查数量是否超过WithMaxKVs设置的上限, 以便尽早失败.
+// 每个字段、slice元素、map元素都会经过这里, 因此也在这里定期检查EncodeContext的ctx, 并将根对象的KV直接输出到EncodeTo的sink
+func (p *FormParser) appendKVs(rt []KV, kvs []KV) ([]KV, error) {
+	if p.ctx != nil {
+		if p.steps++; p.steps%ctxCheckInterval == 0 {
+			if err := p.ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if p.sink != nil && p.depth == 0 {
+		return rt, p.send(kvs) // send按已输出的KV数检查WithMaxKVs
+	}
+	rt = append(rt, kvs...)
+	if p.maxKVs > 0 && len(rt) > p.maxKVs {
+		return nil, fmt.Errorf("%w, limit is %d", ErrTooManyKVs, p.maxKVs)
+	}
+	return rt, nil
+}
+
+func (p *FormParser) encode(v reflect.Value, tagK string, opts tagOptions) ([]KV, error) {
+	if p.sink != nil {
+		p.depth++
+		defer func() { p.depth-- }()
 	}
-	return tag, drop
+	for {
+		// 实现了特定接口的类型优先按接口编码
+		if rt, ok, err := p.encodeMarshaler(v, tagK, opts); ok {
+			return rt, err
+		}
+		if v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface {
+			break
+		}
+		v = v.Elem() // 消除指针及接口, 接口类型按其动态类型编码
+	}
+	return p.encodeKind(v, tagK, opts)
 }
 
-func (p *FormParser) encode(v reflect.Value, tagK string) []KV {
-	for v.Kind() == reflect.Ptr {
-		v = v.Elem() // 消除指针
+// encodeKind 按v的kind编码, 不再检查v实现的接口
+func (p *FormParser) encodeKind(v reflect.Value, tagK string, opts tagOptions) ([]KV, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if rt, ok, err := p.encodeStyle(v, tagK, opts); ok {
+		return rt, err
+	}
+	if rt, ok, err := p.encodeTagOption(v, tagK, opts); ok {
+		return rt, err
 	}
 
 	e, ok := p.encoders[v.Kind()]
 	if !ok || e == nil {
-		panic(fmt.Sprintf("Unknown type %v", v.Kind()))
+		return nil, fmt.Errorf("%s: Unsupported kind %v of key %q", pkgName, v.Kind(), tagK)
 	}
-	return e(v, tagK)
+	return e(v, tagK, opts)
 }
 
 func (p *FormParser) init() *FormParser {
@@ -163,138 +842,376 @@ func (p *FormParser) init() *FormParser {
 		reflect.Struct:     p.encodeStruct,
 		reflect.Map:        p.encodeMap,
 		reflect.Invalid:    p.encodeInvalid,
+
+		reflect.Uintptr:       p.encodePointer,
+		reflect.UnsafePointer: p.encodePointer,
 	}
 	return p
 }
 
-func (p *FormParser) encodeString(v reflect.Value, tagK string) (rt []KV) {
-	return append(rt, KV{tagK, v.Interface().(string)})
+func (p *FormParser) encodeString(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	return append(rt, KV{tagK, v.String()}), nil
 }
 
-func (p *FormParser) encodeBool(v reflect.Value, tagK string) (rt []KV) {
-	return append(rt, KV{tagK, strconv.FormatBool(v.Interface().(bool))})
+func (p *FormParser) encodeBool(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	if l := p.boolLexicon; l != nil {
+		s := l.False
+		if v.Bool() {
+			s = l.True
+		}
+		return append(rt, KV{tagK, s}), nil
+	}
+	return append(rt, KV{tagK, strconv.FormatBool(v.Bool())}), nil
 }
 
-func (p *FormParser) encodeInt(v reflect.Value, tagK string) (rt []KV) {
-	return append(rt, KV{tagK, strconv.Itoa(v.Interface().(int))})
+func (p *FormParser) encodeInt(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	return append(rt, KV{tagK, strconv.FormatInt(v.Int(), 10)}), nil
 }
 
-func (p *FormParser) encodeInt8(v reflect.Value, tagK string) (rt []KV) {
-	return append(rt, KV{tagK, strconv.FormatInt(int64(v.Interface().(int8)), 10)})
+func (p *FormParser) encodeInt8(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	return append(rt, KV{tagK, strconv.FormatInt(v.Int(), 10)}), nil
 }
 
-func (p *FormParser) encodeInt16(v reflect.Value, tagK string) (rt []KV) {
-	return append(rt, KV{tagK, strconv.FormatInt(int64(v.Interface().(int16)), 10)})
+func (p *FormParser) encodeInt16(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	return append(rt, KV{tagK, strconv.FormatInt(v.Int(), 10)}), nil
 }
 
-func (p *FormParser) encodeInt32(v reflect.Value, tagK string) (rt []KV) {
-	return append(rt, KV{tagK, strconv.FormatInt(int64(v.Interface().(int32)), 10)})
+func (p *FormParser) encodeInt32(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	return append(rt, KV{tagK, strconv.FormatInt(v.Int(), 10)}), nil
 }
 
-func (p *FormParser) encodeInt64(v reflect.Value, tagK string) (rt []KV) {
-	return append(rt, KV{tagK, strconv.FormatInt(v.Interface().(int64), 10)})
+func (p *FormParser) encodeInt64(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	return append(rt, KV{tagK, strconv.FormatInt(v.Int(), 10)}), nil
 }
 
-func (p *FormParser) encodeUint(v reflect.Value, tagK string) (rt []KV) {
-	return append(rt, KV{tagK, strconv.FormatUint(uint64(v.Interface().(uint)), 10)})
+func (p *FormParser) encodeUint(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	return append(rt, KV{tagK, strconv.FormatUint(v.Uint(), 10)}), nil
 }
 
-func (p *FormParser) encodeUint8(v reflect.Value, tagK string) (rt []KV) {
-	return append(rt, KV{tagK, strconv.FormatUint(uint64(v.Interface().(uint8)), 10)})
+func (p *FormParser) encodeUint8(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	return append(rt, KV{tagK, strconv.FormatUint(v.Uint(), 10)}), nil
 }
 
-func (p *FormParser) encodeUint16(v reflect.Value, tagK string) (rt []KV) {
-	return append(rt, KV{tagK, strconv.FormatUint(uint64(v.Interface().(uint16)), 10)})
+func (p *FormParser) encodeUint16(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	return append(rt, KV{tagK, strconv.FormatUint(v.Uint(), 10)}), nil
 }
 
-func (p *FormParser) encodeUint32(v reflect.Value, tagK string) (rt []KV) {
-	return append(rt, KV{tagK, strconv.FormatUint(uint64(v.Interface().(uint32)), 10)})
+func (p *FormParser) encodeUint32(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	return append(rt, KV{tagK, strconv.FormatUint(v.Uint(), 10)}), nil
 }
 
-func (p *FormParser) encodeUint64(v reflect.Value, tagK string) (rt []KV) {
-	return append(rt, KV{tagK, strconv.FormatUint(v.Interface().(uint64), 10)})
+func (p *FormParser) encodeUint64(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	return append(rt, KV{tagK, strconv.FormatUint(v.Uint(), 10)}), nil
 }
 
-func (p *FormParser) encodeFloat32(v reflect.Value, tagK string) (rt []KV) {
-	return append(rt, KV{tagK, fmt.Sprintf("%v", v.Interface().(float32))})
+func (p *FormParser) encodeFloat32(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	return append(rt, KV{tagK, strconv.FormatFloat(v.Float(), 'g', -1, 32)}), nil
 }
 
-func (p *FormParser) encodeFloat64(v reflect.Value, tagK string) (rt []KV) {
-	return append(rt, KV{tagK, fmt.Sprintf("%v", v.Interface().(float64))})
+func (p *FormParser) encodeFloat64(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	return append(rt, KV{tagK, strconv.FormatFloat(v.Float(), 'g', -1, 64)}), nil
 }
 
-func (p *FormParser) encodeComplex64(v reflect.Value, tagK string) (rt []KV) {
-	return append(rt, KV{tagK, fmt.Sprintf("%v", v.Interface().(complex64))})
+func (p *FormParser) encodeComplex64(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	return p.encodeComplex(v.Complex(), 32, tagK)
 }
 
-func (p *FormParser) encodeComplex128(v reflect.Value, tagK string) (rt []KV) {
-	return append(rt, KV{tagK, fmt.Sprintf("%v", v.Interface().(complex128))})
+func (p *FormParser) encodeComplex128(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	return p.encodeComplex(v.Complex(), 64, tagK)
 }
 
-func (p *FormParser) encodeSlice(v reflect.Value, tagK string) (rt []KV) {
-	// 如果是[]byte，则进行base64后做成KV
-	b, isBytes := v.Interface().([]byte)
-	if isBytes == true {
-		return append(rt, KV{tagK, base64.StdEncoding.EncodeToString(b)})
+// encodeComplex 按WithComplexFormat设置的方式编码复数c, bitSize为其实部、虚部的精度
+func (p *FormParser) encodeComplex(c complex128, bitSize int, tagK string) (rt []KV, err error) {
+	if p.complexFormat == ComplexError {
+		return nil, fmt.Errorf("%s: Complex value of key %q is not allowed", pkgName, tagK)
+	}
+	re := strconv.FormatFloat(real(c), 'g', -1, bitSize)
+	im := strconv.FormatFloat(imag(c), 'g', -1, bitSize)
+	switch p.complexFormat {
+	case ComplexPair:
+		return append(rt, KV{tagK, re + "," + im}), nil
+	case ComplexSplit:
+		return append(rt, KV{p.joinKey(tagK, "re"), re}, KV{p.joinKey(tagK, "im"), im}), nil
+	}
+	// 与fmt的"%v"一致, 如"(1+2i)"
+	if im[0] != '+' && im[0] != '-' {
+		im = "+" + im
 	}
-	// 如果是[]string,并且tagList[1]为“join”
-	strList, isStrList := v.Interface().([]string)
-	if isStrList {
-		tagList := strings.Split(tagK, ",")
-		if len(tagList) > 1 && tagList[1] == "join" {
-			return append(rt, KV{tagList[0], strings.Join(strList, ",")})
+	return append(rt, KV{tagK, "(" + re + im + "i)"}), nil
+}
+
+func (p *FormParser) encodeSlice(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	if kv, ok := p.encodeSliceValue(v, tagK, opts); ok {
+		return append(rt, kv), nil
+	}
+	// 如果是非以上情况，则按选出的SliceStrategy展开
+	idxFmt, err := parseIndexFormat(opts)
+	if err != nil {
+		return nil, err
+	}
+	s, err := p.sliceStrategy(v.Type(), opts)
+	if err != nil {
+		return nil, err
+	}
+	// 策略通常需要拿到各元素的KV, 不能在此期间输出到sink; 根对象的KV原样拼接时则逐个元素输出
+	e := &SliceEncoder{p: p, opts: opts, idxFmt: idxFmt, stream: p.sink != nil && p.depth == 0 && passThrough(s)}
+	if p.sink != nil { // 仅EncodeTo的副本带有sink, 共用的解析器本身不能被修改
+		p.depth++
+	}
+	kvs, err := s.EncodeSlice(e, v, tagK)
+	if p.sink != nil {
+		p.depth--
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p.appendKVs(rt, kvs)
+}
+
+// encodeSliceValue 处理整体编码为单个KV的slice, ok为false表示需将每个元素单独做成KV
+func (p *FormParser) encodeSliceValue(v reflect.Value, tagK string, opts tagOptions) (kv KV, ok bool) {
+	// 如果是[]byte(包括以[]byte为底层类型的自定义类型)，则按WithBytesFormat设置的方式(默认base64)编码后做成KV, 与解码保持一致
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		return KV{tagK, p.encodeBytes(v.Bytes(), opts)}, true
+	}
+	// 如果是带有"runes"选项的[]rune或[N]rune, 则作为UTF-8字符串
+	if isRunes(v.Type()) && opts.Contains("runes") {
+		rs := make([]rune, v.Len())
+		for i := range rs {
+			rs[i] = rune(v.Index(i).Int())
+		}
+		if v.Kind() == reflect.Array { // 数组末尾未使用的0不输出
+			for len(rs) > 0 && rs[len(rs)-1] == 0 {
+				rs = rs[:len(rs)-1]
+			}
+		}
+		return KV{tagK, string(rs)}, true
+	}
+	return kv, false
+}
+
+// indexFormat slice下标在key中的渲染方式, 由index_pad、idxfmt选项决定
+type indexFormat struct {
+	// 下标用0补齐的位数, 如`zwf:"h,index_pad=3"`使下标渲染为"h.001"
+	pad int
+	// 下标部分(含分隔符)的格式模板, 如`zwf:"h,idxfmt=[%d]"`渲染为"h[0]",
+	// `zwf:"h,idxfmt=.member.%d"`渲染为"h.member.0"; 只接受%d或补零的%0Nd. 设置后index_pad不再生效
+	layout string
+	// layout中的动词恰为"%d"时其前后的部分, 渲染时无需fmt.Sprintf
+	plain          bool
+	prefix, suffix string
+}
+
+// parseIndexFormat 解析index_pad、idxfmt选项
+func parseIndexFormat(opts tagOptions) (f indexFormat, err error) {
+	if s, ok := opts.Get("index_pad"); ok {
+		if f.pad, err = strconv.Atoi(s); err != nil || f.pad < 0 {
+			return f, fmt.Errorf("%s: Invalid index_pad %q", pkgName, s)
+		}
+	}
+	if s, ok := opts.Get("idxfmt"); ok {
+		i, j, ok := indexVerb(s)
+		if !ok {
+			return f, fmt.Errorf("%s: Invalid idxfmt %q, exactly one %%d or zero padded %%0Nd is needed", pkgName, s)
+		}
+		f.layout = s
+		if s[i:j] == "%d" {
+			f.plain, f.prefix, f.suffix = true, s[:i], s[j:]
+		}
+	}
+	return f, nil
+}
+
+// indexVerb 返回idxfmt中唯一的动词所在的区间[i, j). 只接受"%d"及补零的"%0Nd",
+// 其它动词(如%c、%x、%q)会产生含控制字符或无法解码的key
+func indexVerb(s string) (i, j int, ok bool) {
+	if strings.Count(s, "%") != 1 {
+		return 0, 0, false
+	}
+	i = strings.IndexByte(s, '%')
+	j = i + 1
+	if j < len(s) && s[j] == '0' {
+		width := j + 1
+		for j = width; j < len(s) && isDigit(s[j]); j++ {
+		}
+		if j == width {
+			return 0, 0, false
+		}
+	}
+	if j >= len(s) || s[j] != 'd' {
+		return 0, 0, false
+	}
+	return i, j + 1, true
+}
+
+// indexKey 生成slice第i个元素的key, 下标从WithIndexBase设置的值开始, 按f渲染.
+// 标签为inline关键字时不继承父辈标签, 仅以下标作为key
+func (p *FormParser) indexKey(tagK string, i int, f indexFormat) string {
+	if f.layout != "" {
+		var idx string
+		if f.plain {
+			idx = f.prefix + strconv.Itoa(i+p.indexBase) + f.suffix
+		} else {
+			idx = fmt.Sprintf(f.layout, i+p.indexBase)
+		}
+		if p.isInline(tagK) {
+			return strings.TrimPrefix(idx, ".")
 		}
+		return tagK + idx
 	}
-	// 如果是非以上情况，则将每个元素单独做成KV
-	for i := 0; i < v.Len(); i++ {
-		rt = append(rt, p.encode(v.Index(i), fmt.Sprintf("%s.%d", tagK, i))...)
+	idx := strconv.Itoa(i + p.indexBase)
+	if len(idx) < f.pad {
+		idx = strings.Repeat("0", f.pad-len(idx)) + idx
 	}
-	return rt
+	if p.isInline(tagK) {
+		return idx
+	}
+	if p.keyStyle == KeyStyleBracket {
+		return tagK + "[" + idx + "]"
+	}
+	return tagK + "." + idx
+}
+
+// joinKey 将子字段的key拼接到父辈标签之下. child可能已是拼接过的key, 如"b[0][c]",
+// 方括号风格下其首段会被改写为"[b]", 从而得到"a[b][0][c]"
+func (p *FormParser) joinKey(parent, child string) string {
+	if parent == "" {
+		return child
+	}
+	if p.keyStyle != KeyStyleBracket {
+		return parent + "." + child
+	}
+	if i := strings.IndexByte(child, '['); i > 0 {
+		return parent + "[" + child[:i] + "]" + child[i:]
+	}
+	return parent + "[" + child + "]"
+}
+
+// encodeBytes 按WithBytesFormat设置的方式将[]byte编码成字符串, 带有"raw"选项时原样输出
+func (p *FormParser) encodeBytes(b []byte, opts tagOptions) string {
+	if opts.Contains("raw") { // 按原样作为字符串
+		return string(b)
+	}
+	switch p.bytesFormat {
+	case BytesBase64URL:
+		return base64.URLEncoding.EncodeToString(b)
+	case BytesHex:
+		return hex.EncodeToString(b)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func (p *FormParser) encodeStruct(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	return p.encodeFields(v, p.prefixOf(tagK))
 }
 
-func (p *FormParser) encodeStruct(v reflect.Value, tagK string) (rt []KV) {
-	kvs, err := p.parse(v)
+func (p *FormParser) encodeMap(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	keys, err := p.mapKeys(v)
 	if err != nil {
-		panic(fmt.Sprintf("Parse value for tagK(%s) failed, %v", tagK, err))
+		return nil, err
 	}
-	for i, kv := range kvs {
-		if tagK != "..." { // 不继承父辈标签
-			kv.K = tagK + "." + kv.K
+	prefix := p.prefixOf(tagK)
+	for _, key := range keys {
+		// 以map的key作为value的标签递归编码, 使得value为map、struct、interface{}时也能得到完整的key
+		valPair, err := p.encode(v.MapIndex(key.v), p.joinKey(prefix, key.s), opts)
+		if err != nil {
+			return nil, err
+		}
+		if rt, err = p.appendKVs(rt, valPair); err != nil {
+			return nil, err
 		}
-		kvs[i] = kv
 	}
-	rt = kvs
-	return rt
+	return rt, nil
+}
+
+// prefixOf struct、map的子元素所在的前缀, 标签为inline关键字时不继承父辈标签
+func (p *FormParser) prefixOf(tagK string) string {
+	if p.isInline(tagK) {
+		return ""
+	}
+	return tagK
+}
+
+// mapKey map的key及其编码结果
+type mapKey struct {
+	v reflect.Value
+	s string
 }
 
-func (p *FormParser) encodeMap(v reflect.Value, tagK string) (rt []KV) {
-	keys := v.MapKeys()
-	for _, k := range keys {
-		keyPair := p.encode(k, "")
-		valPair := p.encode(v.MapIndex(k), "")
+// mapKeys 编码map的所有key并按编码结果排序, 保证输出顺序稳定
+func (p *FormParser) mapKeys(v reflect.Value) ([]mapKey, error) {
+	keys := make([]mapKey, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keyPair, err := p.encode(k, "", "")
+		if err != nil {
+			return nil, err
+		}
 		for _, key := range keyPair {
-			for _, val := range valPair {
-				var a KV
-				if tagK == "..." { // 不继承父辈标签
-					a.K = key.V
-					a.V = val.V
-				} else {
-					a.K = tagK + "." + key.V
-					a.V = val.V
-				}
-				rt = append(rt, a)
+			keys = append(keys, mapKey{k, key.V})
+		}
+	}
+	less := p.mapKeyLess
+	if less == nil {
+		less = func(a, b string) bool { return a < b }
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return less(keys[i].s, keys[j].s) })
+	return keys, nil
+}
+
+// numericLess 按数值比较连续的数字, 其余部分按字节比较, 如"2" < "10", "item2" < "item10"
+func numericLess(a, b string) bool {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			i, j := digitEnd(a), digitEnd(b)
+			// 去掉前导0后, 位数少的数值小, 位数相同时按字节比较即可
+			x, y := strings.TrimLeft(a[:i], "0"), strings.TrimLeft(b[:j], "0")
+			if len(x) != len(y) {
+				return len(x) < len(y)
+			}
+			if x != y {
+				return x < y
 			}
+			if i != j { // 数值相同时前导0少的在前
+				return i < j
+			}
+			a, b = a[i:], b[j:]
+			continue
 		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// digitEnd 返回s开头连续数字的长度
+func digitEnd(s string) int {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
 	}
-	return rt
+	return i
 }
 
-func (p *FormParser) encodeInvalid(v reflect.Value, tagK string) (rt []KV) {
+// encodePointer uintptr、unsafe.Pointer没有稳定的含义, 默认忽略, 开启WithRejectUintptr时返回错误
+func (p *FormParser) encodePointer(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
+	if p.rejectUintptr {
+		return nil, fmt.Errorf("%s: Kind %v of key %q is not allowed", pkgName, v.Kind(), tagK)
+	}
+	return nil, nil
+}
+
+func (p *FormParser) encodeInvalid(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error) {
 	// do nothing
-	return nil
+	return nil, nil
 }
 
-type kindEncoder func(v reflect.Value, tagK string) (rt []KV)
+type kindEncoder func(v reflect.Value, tagK string, opts tagOptions) (rt []KV, err error)
 
 type KV struct {
 	K string
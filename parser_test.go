@@ -1,9 +1,18 @@
 package formparser
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"net/url"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unsafe"
 )
 
 var h = Hello{
@@ -32,11 +41,78 @@ func TestParse(t *testing.T) {
 	p := New("a", "-")
 	_, err := p.parse(reflect.ValueOf(h))
 	if err != nil {
-		t.Fatalf(err.Error())
+		t.Fatal(err)
 	}
 	p.Debug(reflect.ValueOf(h))
 }
 
+func TestParseInterfaceMap(t *testing.T) {
+	type Payload struct {
+		M map[string]interface{} `a:"m"`
+		N interface{}            `a:"n"`
+	}
+	v := Payload{
+		M: map[string]interface{}{
+			"s":   "str",
+			"i":   1,
+			"f":   2.5,
+			"b":   true,
+			"nil": nil,
+			"l":   []interface{}{"x", 2},
+			"sub": map[string]interface{}{"k": "v"},
+			"ptr": StringPtr("p"),
+		},
+		N: Info{CPU: StringPtr("1核")},
+	}
+	want := map[string]string{
+		"m.s":     "str",
+		"m.i":     "1",
+		"m.f":     "2.5",
+		"m.b":     "true",
+		"m.l.0":   "x",
+		"m.l.1":   "2",
+		"m.sub.k": "v",
+		"m.ptr":   "p",
+		"n.cpu":   "1核",
+	}
+	m, err := New("a", "-").ToMap(reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("Unexpected result %v, want %v", m, want)
+	}
+}
+
+func TestStructInMap(t *testing.T) {
+	type Payload struct {
+		M map[string]Info   `a:"m"`
+		N map[string]*Info  `a:"..."`
+		S map[string][]Info `a:"s"`
+	}
+	v := Payload{
+		M: map[string]Info{"m1": {CPU: StringPtr("1核")}},
+		N: map[string]*Info{"n1": {CPU: StringPtr("2核")}, "n2": nil},
+		S: map[string][]Info{"s1": {{CPU: StringPtr("3核")}}},
+	}
+	cases := []struct {
+		style KeyStyle
+		want  []KV
+	}{
+		{KeyStyleDotted, []KV{{"m.m1.cpu", "1核"}, {"n1.cpu", "2核"}, {"s.s1.0.cpu", "3核"}}},
+		{KeyStyleBracket, []KV{{"m[m1][cpu]", "1核"}, {"n1[cpu]", "2核"}, {"s[s1][0][cpu]", "3核"}}},
+	}
+	for _, c := range cases {
+		got, err := New("a", "-", WithKeyStyle(c.style)).Encode(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("Got %v, want %v", got, c.want)
+		}
+	}
+}
+
 func BenchmarkParse(b *testing.B) {
 	p := New("a", "-")
 	for i := 0; i < b.N; i++ {
@@ -67,3 +143,1081 @@ type Hello struct {
 type Info struct {
 	CPU *string `a:"cpu"`
 }
+
+func TestNestedInlineKeys(t *testing.T) {
+	type Inner struct {
+		S Info           `a:"..."`
+		L []Info         `a:"..."`
+		M map[string]int `a:"...,alias=m"`
+	}
+	type Outer struct {
+		P Inner   `a:"p"`
+		Q []Inner `a:"q"`
+	}
+	v := Outer{
+		P: Inner{S: Info{CPU: StringPtr("1核")}, L: []Info{{CPU: StringPtr("2核")}}, M: map[string]int{"x": 1}},
+		Q: []Inner{{M: map[string]int{"y": 2}}},
+	}
+	cases := []struct {
+		style KeyStyle
+		want  []KV
+	}{
+		{KeyStyleDotted, []KV{{"p.cpu", "1核"}, {"p.0.cpu", "2核"}, {"p.x", "1"}, {"p.m.x", "1"}, {"q.0.y", "2"}, {"q.0.m.y", "2"}}},
+		{KeyStyleBracket, []KV{{"p[cpu]", "1核"}, {"p[0][cpu]", "2核"}, {"p[x]", "1"}, {"p[m][x]", "1"}, {"q[0][y]", "2"}, {"q[0][m][y]", "2"}}},
+	}
+	p := New("a", "-")
+	for _, c := range cases {
+		got, err := p.Encode(v, WithKeyStyle(c.style))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("Style %d: got %v, want %v", c.style, got, c.want)
+		}
+		wantBytes := 0
+		for _, kv := range c.want {
+			wantBytes += len(kv.K) + len(kv.V)
+		}
+		if n, size, _ := p.EstimateSize(v, WithKeyStyle(c.style)); n != len(c.want) || size != wantBytes {
+			t.Fatalf("Style %d: EstimateSize got (%d, %d), want (%d, %d)", c.style, n, size, len(c.want), wantBytes)
+		}
+	}
+}
+
+func BenchmarkEncodeNested(b *testing.B) {
+	type Level3 struct {
+		A string `a:"a"`
+		B int    `a:"b"`
+	}
+	type Level2 struct {
+		L []Level3 `a:"l"`
+	}
+	type Level1 struct {
+		M map[string]Level2 `a:"m"`
+	}
+	v := Level1{M: map[string]Level2{}}
+	for i := 0; i < 10; i++ {
+		l2 := Level2{L: make([]Level3, 10)}
+		for j := range l2.L {
+			l2.L[j] = Level3{A: "x", B: j}
+		}
+		v.M[strconv.Itoa(i)] = l2
+	}
+	p := New("a", "-")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Encode(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestFieldNaming(t *testing.T) {
+	type Demo struct {
+		CPUCount     int
+		HTTPServerID string `a:",omitempty"`
+		Name         string `a:"n"`
+		Disk2Size    int
+	}
+	v := Demo{CPUCount: 1, HTTPServerID: "s", Name: "x", Disk2Size: 2}
+	cases := []struct {
+		naming FieldNaming
+		want   []KV
+	}{
+		{NamingAsIs, []KV{{"CPUCount", "1"}, {"HTTPServerID", "s"}, {"n", "x"}, {"Disk2Size", "2"}}},
+		{NamingLower, []KV{{"cpucount", "1"}, {"httpserverid", "s"}, {"n", "x"}, {"disk2size", "2"}}},
+		{NamingSnake, []KV{{"cpu_count", "1"}, {"http_server_id", "s"}, {"n", "x"}, {"disk2_size", "2"}}},
+	}
+	for _, c := range cases {
+		p := New("a", "-", WithFieldNaming(c.naming))
+		got, err := p.Encode(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("Naming %d: got %v, want %v", c.naming, got, c.want)
+		}
+		values := url.Values{}
+		for _, kv := range got {
+			values.Set(kv.K, kv.V)
+		}
+		var dst Demo
+		if err := p.Decode(values, &dst); err != nil {
+			t.Fatal(err)
+		}
+		if dst != v {
+			t.Fatalf("Naming %d: decode got %+v, want %+v", c.naming, dst, v)
+		}
+	}
+}
+
+func TestRequireTags(t *testing.T) {
+	type Inner struct {
+		X int `a:"x"`
+		Y int `a:",omitempty"`
+	}
+	type Demo struct {
+		A     int   `a:"a"`
+		B     int   `a:"-"`
+		Inner Inner `a:"in"`
+		c     int
+	}
+	p := New("a", "-", WithRequireTags(true))
+	if _, err := p.Encode(struct {
+		A int `a:"a"`
+		B int `a:"-"`
+		c int
+	}{}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := p.Encode(Demo{c: 1})
+	var fe *FieldError
+	if !errors.Is(err, ErrMissingTag) || !errors.As(err, &fe) || fe.Field != "Y" || fe.Key != "in.Y" {
+		t.Fatalf("Got %v, want ErrMissingTag of field Y", err)
+	}
+	if _, err := New("a", "-").Encode(Demo{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// signedAuth AfterEncode看到的key应相对于自身, 绝对key保持原样
+type signedAuth struct {
+	AK  string `a:"ak"`
+	Sig string `a:"/Signature"`
+}
+
+func (s signedAuth) AfterEncode(kvs []KV) ([]KV, error) {
+	var keys []string
+	for _, kv := range kvs {
+		keys = append(keys, kv.K)
+	}
+	return append(kvs, KV{"keys", strings.Join(keys, "|")}), nil
+}
+
+func TestAbsoluteKeys(t *testing.T) {
+	type Auth struct {
+		AK  string `a:"ak"`
+		Sig string `a:"/Signature"`
+	}
+	type Demo struct {
+		A Auth       `a:"a"`
+		L []Auth     `a:"l"`
+		S signedAuth `a:"s"`
+		T string     `a:"/t,alias=/T"`
+	}
+	v := Demo{A: Auth{"1", "x"}, L: []Auth{{"2", "y"}}, S: signedAuth{"3", "z"}, T: "t"}
+	cases := []struct {
+		style KeyStyle
+		want  []KV
+	}{
+		{KeyStyleDotted, []KV{{"a.ak", "1"}, {"Signature", "x"}, {"l.0.ak", "2"}, {"Signature", "y"}, {"s.ak", "3"}, {"Signature", "z"}, {"s.keys", "ak|Signature"}, {"t", "t"}, {"T", "t"}}},
+		{KeyStyleBracket, []KV{{"a[ak]", "1"}, {"Signature", "x"}, {"l[0][ak]", "2"}, {"Signature", "y"}, {"s[ak]", "3"}, {"Signature", "z"}, {"s[keys]", "ak|Signature"}, {"t", "t"}, {"T", "t"}}},
+	}
+	p := New("a", "-")
+	for _, c := range cases {
+		got, err := p.Encode(v, WithKeyStyle(c.style))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("Style %d: got %v, want %v", c.style, got, c.want)
+		}
+		if n, _, _ := p.EstimateSize(v, WithKeyStyle(c.style)); n != len(c.want) {
+			t.Fatalf("Style %d: EstimateSize got %d, want %d", c.style, n, len(c.want))
+		}
+	}
+
+	got, err := p.Encode(Auth{"1", "x"}, WithRootKey("r"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []KV{{"r.ak", "1"}, {"Signature", "x"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"A":          "a",
+		"ID":         "id",
+		"UserID":     "user_id",
+		"HTTPServer": "http_server",
+		"getHTTP":    "get_http",
+		"IPv6Addr":   "i_pv6_addr",
+		"Already_x":  "already_x",
+		"名字Name":     "名字name",
+	} {
+		if got := snakeCase(name); got != want {
+			t.Fatalf("snakeCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestInlineOptions(t *testing.T) {
+	type Keyword struct {
+		A Info           `a:"..."`
+		B map[string]int `a:"..."`
+		C []Info         `a:"..."`
+	}
+	type Option struct {
+		A Info           `a:",inline"`
+		B map[string]int `a:"b,flatten"`
+		C []Info         `a:",flatten"`
+	}
+	a, b, c := Info{CPU: StringPtr("1核")}, map[string]int{"x": 1}, []Info{{CPU: StringPtr("2核")}}
+	p := New("a", "-", WithRequireTags(true))
+	want, err := p.Encode(Keyword{a, b, c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.Encode(Option{a, b, c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) || len(got) != 3 {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+}
+
+func TestInlineKeywords(t *testing.T) {
+	type Demo struct {
+		A Info            `a:"inline"`
+		B map[string]int  `a:"squash"`
+		C Info            `a:"..."`
+		D map[string]bool `a:"d"`
+	}
+	v := Demo{
+		A: Info{CPU: StringPtr("1核")},
+		B: map[string]int{"b": 1},
+		C: Info{CPU: StringPtr("2核")},
+		D: map[string]bool{"x": true},
+	}
+	p := New("a", "-", WithInlineKeywords("inline", "squash"))
+	kvs, err := p.parse(reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KV{{"cpu", "1核"}, {"b", "1"}, {"cpu", "2核"}, {"d.x", "true"}}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Unexpected result %v, want %v", kvs, want)
+	}
+}
+
+func TestInlineSlice(t *testing.T) {
+	type Demo struct {
+		H []*Info `a:"..."`
+		E []int   `a:"..."`
+	}
+	v := Demo{
+		H: []*Info{{CPU: StringPtr("2核")}, nil, {CPU: StringPtr("4核")}},
+		E: []int{7},
+	}
+	kvs, err := New("a", "-").parse(reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KV{{"0.cpu", "2核"}, {"2.cpu", "4核"}, {"0", "7"}}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Unexpected result %v, want %v", kvs, want)
+	}
+}
+
+func TestOnConflict(t *testing.T) {
+	type Demo struct {
+		A string            `a:"a"`
+		M map[string]string `a:"..."`
+	}
+	v := Demo{A: "field", M: map[string]string{"a": "map"}}
+
+	cases := []struct {
+		policy  ConflictPolicy
+		want    string
+		wantErr bool
+	}{
+		{ConflictKeepLast, "map", false},
+		{ConflictKeepFirst, "field", false},
+		{ConflictError, "", true},
+	}
+	for _, c := range cases {
+		m, err := New("a", "-", WithOnConflict(c.policy)).ToMap(reflect.ValueOf(v))
+		if c.wantErr {
+			if err == nil {
+				t.Fatalf("Policy %d: expect error, got %v", c.policy, m)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Policy %d: %v", c.policy, err)
+		}
+		if m["a"] != c.want {
+			t.Fatalf("Policy %d: got %q, want %q", c.policy, m["a"], c.want)
+		}
+	}
+}
+
+func TestMaxKVs(t *testing.T) {
+	type Demo struct {
+		E []int          `a:"e"`
+		M map[string]int `a:"m"`
+	}
+	v := Demo{E: make([]int, 100), M: map[string]int{"x": 1}}
+
+	if _, err := New("a", "-", WithMaxKVs(101)).ToMap(reflect.ValueOf(v)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New("a", "-", WithMaxKVs(100)).ToMap(reflect.ValueOf(v)); !errors.Is(err, ErrTooManyKVs) {
+		t.Fatalf("Expect ErrTooManyKVs, got %v", err)
+	}
+	if _, err := New("a", "-", WithMaxKVs(10)).ToMap(reflect.ValueOf(v)); !errors.Is(err, ErrTooManyKVs) {
+		t.Fatalf("Expect ErrTooManyKVs, got %v", err)
+	}
+}
+
+func TestValueLimit(t *testing.T) {
+	type Demo struct {
+		A string `a:"a"`
+		B string `a:"b"`
+	}
+	v := Demo{A: "short", B: "中文字符串"}
+
+	if _, err := New("a", "-", WithMaxValueLen(15)).ToMap(reflect.ValueOf(v)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New("a", "-", WithMaxValueLen(10)).ToMap(reflect.ValueOf(v)); !errors.Is(err, ErrValueTooLong) {
+		t.Fatalf("Expect ErrValueTooLong, got %v", err)
+	}
+
+	m, err := New("a", "-", WithTruncateValues(10, "...")).ToMap(reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["a"] != "short" || m["b"] != "中文..." {
+		t.Fatalf("Unexpected result %v", m)
+	}
+}
+
+func TestEscapeValues(t *testing.T) {
+	type Demo struct {
+		A string `a:"a"`
+	}
+	v := Demo{A: "a b&c=d/中"}
+
+	m, err := New("a", "-", WithEscapeValues(true)).ToMap(reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a+b%26c%3Dd%2F%E4%B8%AD"; m["a"] != want {
+		t.Fatalf("Got %q, want %q", m["a"], want)
+	}
+}
+
+func TestLowercaseKeys(t *testing.T) {
+	type Demo struct {
+		Name  string            `a:"UserName"`
+		Info  Info              `a:"Info"`
+		M     map[string]string `a:"M"`
+		NoTag string
+	}
+	v := Demo{Name: "n", Info: Info{CPU: StringPtr("1核")}, M: map[string]string{"Key": "v"}, NoTag: "x"}
+
+	m, err := New("a", "-", WithLowercaseKeys(true)).ToMap(reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"username": "n", "info.cpu": "1核", "m.key": "v", "notag": "x"}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("Unexpected result %v, want %v", m, want)
+	}
+}
+
+func TestOmitEmptyAndOmitZero(t *testing.T) {
+	type Demo struct {
+		A  int               `a:"a,omitempty"`
+		B  string            `a:"b,omitempty"`
+		C  []int             `a:"c,omitempty"`
+		D  map[string]string `a:"d,omitempty"`
+		E  *int              `a:"e,omitempty"`
+		F  Info              `a:"f,omitempty"`
+		ZA int               `a:"za,omitzero"`
+		ZC []int             `a:"zc,omitzero"`
+		ZD map[string]string `a:"zd,omitzero"`
+		ZE *int              `a:"ze,omitzero"`
+		ZF [2]int            `a:"zf,omitzero"`
+		L  []string          `a:"l,join,omitempty"`
+	}
+	v := Demo{
+		C:  []int{},
+		D:  map[string]string{},
+		E:  IntPtr(0),
+		F:  Info{CPU: StringPtr("1核")},
+		ZC: []int{},
+		ZD: map[string]string{},
+		ZE: IntPtr(0),
+		L:  []string{"x", "y"},
+	}
+	kvs, err := New("a", "-").parse(reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KV{{"e", "0"}, {"f.cpu", "1核"}, {"ze", "0"}, {"l", "x,y"}}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Unexpected result %v, want %v", kvs, want)
+	}
+}
+
+func TestIndexBase(t *testing.T) {
+	type Demo struct {
+		H []*Info `a:"h"`
+		E []int   `a:"..."`
+	}
+	v := Demo{H: []*Info{{CPU: StringPtr("2核")}, {CPU: StringPtr("4核")}}, E: []int{7}}
+
+	kvs, err := New("a", "-", WithIndexBase(1)).parse(reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KV{{"h.1.cpu", "2核"}, {"h.2.cpu", "4核"}, {"1", "7"}}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Unexpected result %v, want %v", kvs, want)
+	}
+}
+
+func TestIndexPad(t *testing.T) {
+	type Demo struct {
+		H []*Info `a:"h,index_pad=3"`
+		E []int   `a:"e,index_pad=1"`
+	}
+	v := Demo{H: []*Info{{CPU: StringPtr("2核")}, {CPU: StringPtr("4核")}}, E: make([]int, 11)}
+
+	kvs, err := New("a", "-", WithIndexBase(1)).parse(reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kvs[0].K != "h.001.cpu" || kvs[1].K != "h.002.cpu" || kvs[2].K != "e.1" || kvs[12].K != "e.11" {
+		t.Fatalf("Unexpected result %v", kvs)
+	}
+
+	type Bad struct {
+		E []int `a:"e,index_pad=x"`
+	}
+	if _, err := New("a", "-").parse(reflect.ValueOf(Bad{E: []int{1}})); err == nil {
+		t.Fatal("Expect error for invalid index_pad")
+	}
+}
+
+func TestIndexLayout(t *testing.T) {
+	type Demo struct {
+		A []int `a:"a,idxfmt=[%d]"`
+		B []int `a:"b,idxfmt=.member.%d"`
+		C []int `a:"c,idxfmt=.%03d"`
+		D []int `a:"...,idxfmt=.item%d"`
+	}
+	v := Demo{A: []int{1}, B: []int{2}, C: []int{3}, D: []int{4}}
+
+	kvs, err := New("a", "-", WithIndexBase(1)).parse(reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KV{{"a[1]", "1"}, {"b.member.1", "2"}, {"c.001", "3"}, {"item1", "4"}}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Unexpected result %v, want %v", kvs, want)
+	}
+
+	for _, layout := range []string{"[]", "%d%d", "%s", ".%c", ".%x", ".%q", ".%U", ".%v", ".%0d", ".%3d", ".%-3d", "%"} {
+		f, err := parseIndexFormat(tagOptions("idxfmt=" + layout))
+		if err == nil {
+			t.Fatalf("Expect error for idxfmt %q, got %+v", layout, f)
+		}
+	}
+}
+
+func TestBracketKeyStyle(t *testing.T) {
+	type User struct {
+		Name  string             `a:"name"`
+		Roles []string           `a:"roles"`
+		Hosts []*Info            `a:"hosts"`
+		Meta  map[string]*string `a:"meta"`
+	}
+	type Order struct {
+		User  User     `a:"user"`
+		Tags  []string `a:"tags,idxfmt=.%d"`
+		Group [][]int  `a:"group"`
+		Auth  Info     `a:"..."`
+	}
+	v := Order{
+		User: User{
+			Name:  "x",
+			Roles: []string{"admin"},
+			Hosts: []*Info{{CPU: StringPtr("2核")}},
+			Meta:  map[string]*string{"m1": StringPtr("m1")},
+		},
+		Tags:  []string{"t"},
+		Group: [][]int{{0, 1}},
+		Auth:  Info{CPU: StringPtr("1核")},
+	}
+	kvs, err := New("a", "-", WithKeyStyle(KeyStyleBracket)).parse(reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KV{
+		{"user[name]", "x"},
+		{"user[roles][0]", "admin"},
+		{"user[hosts][0][cpu]", "2核"},
+		{"user[meta][m1]", "m1"},
+		{"tags.0", "t"},
+		{"group[0][0]", "0"},
+		{"group[0][1]", "1"},
+		{"cpu", "1核"},
+	}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Unexpected result %v, want %v", kvs, want)
+	}
+}
+
+func TestTopLevelSlice(t *testing.T) {
+	items := []*Info{{CPU: StringPtr("1核")}, {CPU: StringPtr("2核")}}
+	p := New("a", "-")
+
+	m, err := p.ToMap(reflect.ValueOf(items))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"0.cpu": "1核", "1.cpu": "2核"}; !reflect.DeepEqual(m, want) {
+		t.Fatalf("Unexpected result %v, want %v", m, want)
+	}
+
+	m, err = p.ToMap(reflect.ValueOf(&[2]int{3, 4}), WithRootKey("ids"), WithIndexBase(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"ids.1": "3", "ids.2": "4"}; !reflect.DeepEqual(m, want) {
+		t.Fatalf("Unexpected result %v, want %v", m, want)
+	}
+
+	// 单次调用的选项不影响parser本身
+	m, err = p.ToMap(reflect.ValueOf(Info{CPU: StringPtr("1核")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"cpu": "1核"}; !reflect.DeepEqual(m, want) {
+		t.Fatalf("Unexpected result %v, want %v", m, want)
+	}
+
+	if _, err := p.ToMap(reflect.ValueOf([]byte("x"))); err == nil {
+		t.Fatal("Expect error for top-level []byte without root key")
+	}
+	if _, err := p.ToMap(reflect.ValueOf(1)); err == nil {
+		t.Fatal("Expect error for top-level int")
+	}
+}
+
+func TestTopLevelMap(t *testing.T) {
+	params := map[string]interface{}{
+		"Region": "cn-hangzhou",
+		"Limit":  10,
+		"Filter": map[string]interface{}{"Name": "x"},
+		"Ids":    []string{"a", "b"},
+	}
+	p := New("a", "-")
+
+	m, err := p.ToMap(reflect.ValueOf(params))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"Region": "cn-hangzhou", "Limit": "10", "Filter.Name": "x", "Ids.0": "a", "Ids.1": "b"}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("Unexpected result %v, want %v", m, want)
+	}
+
+	m, err = p.ToMap(reflect.ValueOf(&map[int]bool{1: true}), WithRootKey("flags"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"flags.1": "true"}; !reflect.DeepEqual(m, want) {
+		t.Fatalf("Unexpected result %v, want %v", m, want)
+	}
+}
+
+func TestEncodeValue(t *testing.T) {
+	p := New("a", "-")
+	cases := []struct {
+		key  string
+		v    interface{}
+		want []KV
+	}{
+		{"region", "cn-hangzhou", []KV{{"region", "cn-hangzhou"}}},
+		{"limit", IntPtr(10), []KV{{"limit", "10"}}},
+		{"ids", []int{1, 2}, []KV{{"ids.0", "1"}, {"ids.1", "2"}}},
+		{"tags", map[string]string{"k": "v"}, []KV{{"tags.k", "v"}}},
+		{"info", Info{CPU: StringPtr("1核")}, []KV{{"info.cpu", "1核"}}},
+		{"rv", reflect.ValueOf(true), []KV{{"rv", "true"}}},
+		{"nil", nil, nil},
+	}
+	for _, c := range cases {
+		kvs, err := p.EncodeValue(c.key, c.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(kvs, c.want) {
+			t.Fatalf("Key %q: got %v, want %v", c.key, kvs, c.want)
+		}
+	}
+
+	kvs, err := p.EncodeValue("Ids", []int{1}, WithLowercaseKeys(true), WithIndexBase(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []KV{{"ids.1", "1"}}; !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Got %v, want %v", kvs, want)
+	}
+	if _, err := p.EncodeValue("", 1); err == nil {
+		t.Fatal("Expect error for empty key")
+	}
+}
+
+// cancelAfter 在第n次编码时取消ctx
+type cancelAfter struct {
+	n      *int
+	cancel context.CancelFunc
+}
+
+func (c cancelAfter) String() string {
+	if *c.n--; *c.n == 0 {
+		c.cancel()
+	}
+	return "x"
+}
+
+func TestEncodeContext(t *testing.T) {
+	p := New("a", "-")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n := 10
+	items := make([]cancelAfter, 100000)
+	for i := range items {
+		items[i] = cancelAfter{&n, cancel}
+	}
+	p.RegisterType(reflect.TypeOf(cancelAfter{}), StringerEncoder)
+
+	kvs, err := p.EncodeContext(ctx, items)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expect context.Canceled, got %v", err)
+	}
+	if n > 0 || n < -ctxCheckInterval {
+		t.Fatalf("Encoding should stop soon after cancel, %d extra items encoded", -n)
+	}
+	if kvs != nil {
+		t.Fatalf("Unexpected result %v", kvs)
+	}
+
+	kvs, err = p.EncodeContext(context.Background(), items[:2], WithRootKey("items"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []KV{{"items.0", "x"}, {"items.1", "x"}}; !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Got %v, want %v", kvs, want)
+	}
+	if _, err := p.EncodeContext(ctx, items[:1]); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expect context.Canceled, got %v", err)
+	}
+
+	// 嵌套在struct字段中的slice同样需要定期检查ctx
+	type Nested struct {
+		Inner struct {
+			Items []cancelAfter `a:"items"`
+		} `a:"inner"`
+	}
+	for _, opts := range [][]Option{nil, {WithRootKey("r")}} {
+		ctx, cancel := context.WithCancel(context.Background())
+		n = 10
+		var v Nested
+		v.Inner.Items = make([]cancelAfter, 100000)
+		for i := range v.Inner.Items {
+			v.Inner.Items[i] = cancelAfter{&n, cancel}
+		}
+		kvs, err := p.EncodeContext(ctx, &v, opts...)
+		cancel()
+		if !errors.Is(err, context.Canceled) || kvs != nil {
+			t.Fatalf("Expect context.Canceled, got %d KVs and %v", len(kvs), err)
+		}
+		if n < -ctxCheckInterval {
+			t.Fatalf("Encoding should stop soon after cancel, %d extra items encoded", -n)
+		}
+	}
+}
+
+func TestEncodeOrder(t *testing.T) {
+	type Demo struct {
+		Z string         `a:"z"`
+		M map[string]int `a:"m"`
+		N map[int]string `a:"n"`
+		A []int          `a:"a"`
+	}
+	v := Demo{
+		Z: "z",
+		M: map[string]int{"c": 3, "a": 1, "b": 2},
+		N: map[int]string{2: "x", 1: "y"},
+		A: []int{9, 8},
+	}
+	want := []KV{
+		{"z", "z"},
+		{"m.a", "1"}, {"m.b", "2"}, {"m.c", "3"},
+		{"n.1", "y"}, {"n.2", "x"},
+		{"a.0", "9"}, {"a.1", "8"},
+	}
+	p := New("a", "-")
+	for i := 0; i < 20; i++ {
+		kvs, err := p.Encode(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(kvs, want) {
+			t.Fatalf("Got %v, want %v", kvs, want)
+		}
+	}
+}
+
+func TestAlias(t *testing.T) {
+	type Demo struct {
+		AK   string `a:"access_key,alias=ak|accessKey"`
+		Info Info   `a:"info,alias=i"`
+	}
+	kvs, err := New("a", "-").Encode(Demo{AK: "x", Info: Info{CPU: StringPtr("1")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KV{{"access_key", "x"}, {"ak", "x"}, {"accessKey", "x"}, {"info.cpu", "1"}, {"i.cpu", "1"}}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Got %v, want %v", kvs, want)
+	}
+}
+
+func TestNoPanic(t *testing.T) {
+	type embedded struct {
+		E string `a:"e"`
+	}
+	type Demo struct {
+		embedded `a:"..."`
+		A        string    `a:"a"`
+		b        string    `a:"b"`
+		t        time.Time `a:"t"`
+		l        []string  `a:"l,join"`
+	}
+	p := New("a", "-")
+	got, err := p.Encode(Demo{embedded: embedded{E: "e"}, A: "a", b: "b", l: []string{"x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []KV{{"e", "e"}, {"a", "a"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+
+	type Unsupported struct {
+		C chan int `a:"c"`
+	}
+	if _, err := p.Encode(Unsupported{C: make(chan int)}); err == nil {
+		t.Fatal("Expect error for unsupported kind")
+	}
+	p.Debug(reflect.ValueOf(Unsupported{C: make(chan int)}))
+}
+
+func FuzzEncode(f *testing.F) {
+	f.Add("a.b=1&a.c[0]=x&d=", 0)
+	f.Add("x[y][z]=1&x[y][w]=2&0=a", 1)
+	f.Fuzz(func(t *testing.T, query string, style int) {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return
+		}
+		// 以解析得到的任意嵌套map/slice作为编码的输入
+		p := New("a", "-", WithKeyStyle(KeyStyle(style&1)))
+		v := map[string]interface{}{"y": p.buildTree(values).toInterface(), "z": values}
+		for _, opts := range [][]Option{nil, {WithRootKey("r")}, {WithTruncateValues(3, ".")}, {WithIndexBase(1), WithLowercaseKeys(true)}} {
+			kvs, err := p.Encode(v, opts...)
+			if err != nil {
+				continue
+			}
+			n, _, err := p.EstimateSize(v, opts...)
+			if err != nil || n != len(kvs) {
+				t.Fatalf("EstimateSize got %d, %v, Encode got %d KVs", n, err, len(kvs))
+			}
+		}
+	})
+}
+
+func TestNumericMapKeys(t *testing.T) {
+	v := map[string]int{"10": 1, "2": 2, "item10": 3, "item2": 4, "item02": 5, "b": 6, "a1b": 7}
+	kvs, err := New("a", "-").Encode(v, WithNumericMapKeys(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, kv := range kvs {
+		keys = append(keys, kv.K)
+	}
+	if want := []string{"2", "10", "a1b", "b", "item2", "item02", "item10"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("Got %v, want %v", keys, want)
+	}
+}
+
+func TestMapKeyLess(t *testing.T) {
+	v := map[string]string{"sign": "x", "b": "2", "a": "1"}
+	signLast := func(a, b string) bool {
+		if a == "sign" || b == "sign" {
+			return b == "sign" && a != "sign"
+		}
+		return a < b
+	}
+	kvs, err := New("a", "-").Encode(v, WithMapKeyLess(signLast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []KV{{"a", "1"}, {"b", "2"}, {"sign", "x"}}; !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Got %v, want %v", kvs, want)
+	}
+}
+
+// TestFloatFormat 用strconv编码的浮点数、复数须与原先的fmt.Sprintf("%v")保持一致
+func TestFloatFormat(t *testing.T) {
+	floats := []float64{0, math.Copysign(0, -1), 1, -1, 0.1, 1.5e-7, 123456789, 1e20, 1e21, 3.4e38, math.MaxFloat64,
+		math.SmallestNonzeroFloat64, math.Inf(1), math.Inf(-1), math.NaN(), 1.0 / 3}
+	p := New("a", "-")
+	for _, f := range floats {
+		for _, c := range []interface{}{f, float32(f), complex(f, f), complex(1, -f), complex64(complex(f, 2))} {
+			kvs, err := p.EncodeValue("k", c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := fmt.Sprintf("%v", c); kvs[0].V != want {
+				t.Fatalf("Encode %T %v got %s, want %s", c, c, kvs[0].V, want)
+			}
+		}
+	}
+}
+
+func BenchmarkEncodeSlices(b *testing.B) {
+	type Demo struct {
+		F []float64    `a:"f"`
+		C []complex128 `a:"c"`
+		M []int        `a:"m,idxfmt=.member.%d"`
+	}
+	v := Demo{F: make([]float64, 100), C: make([]complex128, 100), M: make([]int, 100)}
+	for i := range v.F {
+		v.F[i], v.C[i], v.M[i] = float64(i)/3, complex(float64(i), 1), i
+	}
+	p := New("a", "-")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Encode(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestComplexFormat(t *testing.T) {
+	type Demo struct {
+		C  complex128  `a:"c"`
+		C6 *complex64  `a:"c6"`
+		L  []complex64 `a:"l"`
+	}
+	c6 := complex64(complex(0.5, -1))
+	v := Demo{C: complex(1, 2), C6: &c6, L: []complex64{complex(3, 4)}}
+	cases := []struct {
+		opts []Option
+		want []KV
+	}{
+		{nil, []KV{{"c", "(1+2i)"}, {"c6", "(0.5-1i)"}, {"l.0", "(3+4i)"}}},
+		{[]Option{WithComplexFormat(ComplexPair)}, []KV{{"c", "1,2"}, {"c6", "0.5,-1"}, {"l.0", "3,4"}}},
+		{[]Option{WithComplexFormat(ComplexSplit)}, []KV{{"c.re", "1"}, {"c.im", "2"}, {"c6.re", "0.5"}, {"c6.im", "-1"}, {"l.0.re", "3"}, {"l.0.im", "4"}}},
+		{[]Option{WithComplexFormat(ComplexSplit), WithKeyStyle(KeyStyleBracket), WithRootKey("r")}, []KV{{"r[c][re]", "1"}, {"r[c][im]", "2"}, {"r[c6][re]", "0.5"}, {"r[c6][im]", "-1"}, {"r[l][0][re]", "3"}, {"r[l][0][im]", "4"}}},
+	}
+	p := New("a", "-")
+	for _, c := range cases {
+		got, err := p.Encode(v, c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("Got %v, want %v", got, c.want)
+		}
+		if n, _, _ := p.EstimateSize(v, c.opts...); n != len(c.want) {
+			t.Fatalf("EstimateSize got %d, want %d", n, len(c.want))
+		}
+	}
+	if _, err := p.Encode(v, WithComplexFormat(ComplexError)); err == nil {
+		t.Fatal("Expect error for complex value")
+	}
+}
+
+func TestUintptr(t *testing.T) {
+	type Demo struct {
+		A string         `a:"a"`
+		U uintptr        `a:"u"`
+		P unsafe.Pointer `a:"p"`
+	}
+	x := 1
+	v := Demo{A: "a", U: 1, P: unsafe.Pointer(&x)}
+	p := New("a", "-")
+	got, err := p.Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []KV{{"a", "a"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+	if _, err := p.Encode(v, WithRejectUintptr(true)); err == nil {
+		t.Fatal("Expect error for uintptr")
+	}
+}
+
+func TestRunes(t *testing.T) {
+	type Demo struct {
+		R []rune  `a:"r,runes"`
+		A [4]rune `a:"a,runes"`
+		N []int32 `a:"n"`
+		D []rune  `a:"d"`
+	}
+	v := Demo{R: []rune("中文"), A: [4]rune{'a', 'b'}, N: []int32{1, 2}, D: []rune("ab")}
+	p := New("a", "-")
+	got, err := p.Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []KV{{"r", "中文"}, {"a", "ab"}, {"n.0", "1"}, {"n.1", "2"}, {"d.0", "97"}, {"d.1", "98"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+}
+
+func TestOmitEmptyStructs(t *testing.T) {
+	type Inner struct {
+		N int     `a:"n"`
+		S *string `a:"s,omitempty"`
+	}
+	type Demo struct {
+		A Inner     `a:"a,omitempty"`
+		B Inner     `a:"b"`
+		C *Inner    `a:"c"`
+		D time.Time `a:"d"`
+		E Inner     `a:"e"`
+		F struct {
+			S *string `a:"s,omitempty"`
+		} `a:"f"`
+	}
+	v := Demo{C: &Inner{}, E: Inner{N: 1}}
+	p := New("a", "-")
+	cases := []struct {
+		opts []Option
+		want []KV
+	}{
+		{nil, []KV{{"b.n", "0"}, {"c.n", "0"}, {"d", "0001-01-01T00:00:00Z"}, {"e.n", "1"}}},
+		{[]Option{WithOmitEmptyStructs(true)}, []KV{{"e.n", "1"}}},
+	}
+	for _, c := range cases {
+		got, err := p.Encode(v, c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("Got %v, want %v", got, c.want)
+		}
+	}
+}
+
+func TestSliceMapCombinations(t *testing.T) {
+	type Item struct {
+		Name string `a:"name"`
+	}
+	type Demo struct {
+		K  []map[string]string         `a:"k"`
+		M  map[string][]Item           `a:"m"`
+		N  []map[string][]Item         `a:"n"`
+		P  map[string][]map[string]int `a:"p"`
+		I  []map[string]Item           `a:"..."`
+		MI map[string][]*Item          `a:"mi"`
+	}
+	v := Demo{
+		K:  []map[string]string{{"name": "a"}, {"name": "b"}},
+		M:  map[string][]Item{"key": {{"x"}, {"y"}}},
+		N:  []map[string][]Item{{"k": {{"z"}}}},
+		P:  map[string][]map[string]int{"q": {{"r": 1}}},
+		I:  []map[string]Item{{"i": {"w"}}},
+		MI: map[string][]*Item{"j": {nil, {"v"}}},
+	}
+	cases := []struct {
+		style KeyStyle
+		want  []KV
+	}{
+		{KeyStyleDotted, []KV{
+			{"k.0.name", "a"}, {"k.1.name", "b"},
+			{"m.key.0.name", "x"}, {"m.key.1.name", "y"},
+			{"n.0.k.0.name", "z"},
+			{"p.q.0.r", "1"},
+			{"0.i.name", "w"},
+			{"mi.j.1.name", "v"},
+		}},
+		{KeyStyleBracket, []KV{
+			{"k[0][name]", "a"}, {"k[1][name]", "b"},
+			{"m[key][0][name]", "x"}, {"m[key][1][name]", "y"},
+			{"n[0][k][0][name]", "z"},
+			{"p[q][0][r]", "1"},
+			{"0[i][name]", "w"},
+			{"mi[j][1][name]", "v"},
+		}},
+	}
+	for _, c := range cases {
+		p := New("a", "-", WithKeyStyle(c.style))
+		got, err := p.Encode(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("Got %v, want %v", got, c.want)
+		}
+		size := 0
+		for _, kv := range got {
+			size += len(kv.K) + len(kv.V)
+		}
+		if n, bytes, err := p.EstimateSize(v); err != nil || n != len(got) || bytes != size {
+			t.Fatalf("EstimateSize got (%d, %d, %v), want (%d, %d)", n, bytes, err, len(got), size)
+		}
+	}
+}
+
+// TestConcurrentEncode 同一个解析器可被并发使用, 需配合go test -race运行
+func TestConcurrentEncode(t *testing.T) {
+	type Demo struct {
+		L []int            `a:"l"`
+		H []Info           `a:"h"`
+		M map[string][]int `a:"m"`
+	}
+	p := New("a", "-")
+	v := Demo{L: []int{1, 2}, H: []Info{{CPU: StringPtr("1")}}, M: map[string][]int{"x": {3}}}
+	want, err := p.Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				kvs, err := p.Encode(v)
+				if err != nil || !reflect.DeepEqual(kvs, want) {
+					t.Errorf("Got %v, %v, want %v", kvs, err, want)
+					return
+				}
+				if _, err := p.ToMap(reflect.ValueOf([]Demo{v})); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := p.EncodeTo(v, MapSink{}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
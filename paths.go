@@ -0,0 +1,85 @@
+package formparser
+
+// pathPattern 拆分后的key路径, "*"匹配任意一段
+type pathPattern []string
+
+func compilePaths(paths []string) []pathPattern {
+	patterns := make([]pathPattern, len(paths))
+	for i, path := range paths {
+		patterns[i] = splitKeyStyle(path, KeyStyleAuto)
+	}
+	return patterns
+}
+
+// covers 判断路径为segs的key是否位于该路径之下(含路径本身)
+func (pp pathPattern) covers(segs []string) bool {
+	if len(segs) < len(pp) {
+		return false
+	}
+	for i, seg := range pp {
+		if seg != "*" && seg != segs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pathFilter 根据WithOnlyPaths、WithExcludePaths生成判断key是否保留的函数, 均未设置时返回nil
+func (p *FormParser) pathFilter() func(key string) bool {
+	if len(p.exclude) == 0 && len(p.only) == 0 {
+		return nil
+	}
+	return func(key string) bool {
+		segs := splitKeyStyle(key, KeyStyleAuto)
+		if len(p.only) > 0 && !anyCovers(p.only, segs) {
+			return false
+		}
+		return !anyCovers(p.exclude, segs)
+	}
+}
+
+func anyCovers(patterns []pathPattern, segs []string) bool {
+	for _, pp := range patterns {
+		if pp.covers(segs) {
+			return true
+		}
+	}
+	return false
+}
+
+// interceptor 由WithInterceptor设置
+type interceptor struct {
+	path    string
+	pattern pathPattern
+	fn      func(kv KV) (KV, bool)
+}
+
+// intercept 依次以匹配kv的拦截器改写kv, 返回false表示去掉该KV
+func (p *FormParser) intercept(kv KV) (KV, bool) {
+	if len(p.interceptors) == 0 {
+		return kv, true
+	}
+	segs := splitKeyStyle(kv.K, KeyStyleAuto)
+	for _, it := range p.interceptors {
+		if !it.pattern.covers(segs) {
+			continue
+		}
+		k := kv.K
+		var ok bool
+		if kv, ok = it.fn(kv); !ok {
+			return kv, false
+		}
+		if kv.K != k {
+			segs = splitKeyStyle(kv.K, KeyStyleAuto)
+		}
+	}
+	return kv, true
+}
+
+func (p *FormParser) interceptorPaths() []string {
+	var paths []string
+	for _, it := range p.interceptors {
+		paths = append(paths, it.path)
+	}
+	return paths
+}
@@ -0,0 +1,130 @@
+package formparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExcludePaths(t *testing.T) {
+	type Demo struct {
+		H []*Info            `a:"h"`
+		I map[string]*string `a:"i"`
+		K Info               `a:"K"`
+	}
+	v := Demo{
+		H: []*Info{{CPU: StringPtr("2核")}, {CPU: StringPtr("3核")}},
+		I: map[string]*string{"m1": StringPtr("m1"), "m2": StringPtr("m2")},
+		K: Info{CPU: StringPtr("4核")},
+	}
+	cases := []struct {
+		opts []Option
+		want map[string]string
+	}{
+		{[]Option{WithExcludePaths("h.*.cpu", "i.m2")}, map[string]string{"i.m1": "m1", "K.cpu": "4核"}},
+		{[]Option{WithExcludePaths("h"), WithExcludePaths("i")}, map[string]string{"K.cpu": "4核"}},
+		{[]Option{WithExcludePaths("h[1]", "i[*]"), WithKeyStyle(KeyStyleBracket)}, map[string]string{"h[0][cpu]": "2核", "K[cpu]": "4核"}},
+		{[]Option{WithExcludePaths("k"), WithLowercaseKeys(true)}, map[string]string{"h.0.cpu": "2核", "h.1.cpu": "3核", "i.m1": "m1", "i.m2": "m2"}},
+	}
+	p := New("a", "-")
+	for i, c := range cases {
+		got, err := p.ToMap(reflect.ValueOf(v), c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("Case %d: got %v, want %v", i, got, c.want)
+		}
+		if n, _, _ := p.EstimateSize(v, c.opts...); n != len(c.want) {
+			t.Fatalf("Case %d: EstimateSize got %d, want %d", i, n, len(c.want))
+		}
+	}
+	if p.Options().ExcludePaths != nil {
+		t.Fatal("Expect no ExcludePaths by default")
+	}
+}
+
+func TestOnlyPaths(t *testing.T) {
+	type Address struct {
+		City   string `a:"city"`
+		Street string `a:"street"`
+	}
+	type Update struct {
+		ID      int      `a:"id"`
+		Name    string   `a:"name"`
+		Address Address  `a:"address"`
+		Tags    []string `a:"tags"`
+	}
+	v := Update{ID: 7, Name: "x", Address: Address{"hz", "wl"}, Tags: []string{"a", "b"}}
+	cases := []struct {
+		opts []Option
+		want []KV
+	}{
+		{[]Option{WithOnlyPaths("id", "address.city")}, []KV{{"id", "7"}, {"address.city", "hz"}}},
+		{[]Option{WithOnlyPaths("id"), WithOnlyPaths("tags")}, []KV{{"id", "7"}, {"tags.0", "a"}, {"tags.1", "b"}}},
+		{[]Option{WithOnlyPaths("address", "tags"), WithExcludePaths("address.street", "tags.0")}, []KV{{"address.city", "hz"}, {"tags.1", "b"}}},
+		{[]Option{WithOnlyPaths("*.city"), WithKeyStyle(KeyStyleBracket)}, []KV{{"address[city]", "hz"}}},
+		{[]Option{WithOnlyPaths("missing")}, []KV{}},
+	}
+	p := New("a", "-")
+	for i, c := range cases {
+		got, err := p.Encode(v, c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("Case %d: got %v, want %v", i, got, c.want)
+		}
+		if n, _, _ := p.EstimateSize(v, c.opts...); n != len(c.want) {
+			t.Fatalf("Case %d: EstimateSize got %d, want %d", i, n, len(c.want))
+		}
+	}
+	if got := New("a", "-", WithOnlyPaths("id")).Options().OnlyPaths; !reflect.DeepEqual(got, []string{"id"}) {
+		t.Fatalf("Got %v, want [id]", got)
+	}
+}
+
+func TestInterceptor(t *testing.T) {
+	type Auth struct {
+		User     string `a:"user"`
+		Internal string `a:"internal"`
+	}
+	type Req struct {
+		ID   int  `a:"id"`
+		Auth Auth `a:"auth"`
+	}
+	v := Req{ID: 7, Auth: Auth{User: "u", Internal: "i"}}
+	tenant := func(kv KV) (KV, bool) {
+		kv.V = "t1:" + kv.V
+		return kv, true
+	}
+	drop := func(kv KV) (KV, bool) { return kv, false }
+	rename := func(kv KV) (KV, bool) {
+		kv.K = "x." + kv.K
+		return kv, true
+	}
+	cases := []struct {
+		opts []Option
+		want []KV
+	}{
+		{[]Option{WithInterceptor("auth.*", tenant)}, []KV{{"id", "7"}, {"auth.user", "t1:u"}, {"auth.internal", "t1:i"}}},
+		{[]Option{WithInterceptor("*.internal", drop)}, []KV{{"id", "7"}, {"auth.user", "u"}}},
+		{[]Option{WithInterceptor("id", rename), WithInterceptor("x", tenant)}, []KV{{"x.id", "t1:7"}, {"auth.user", "u"}, {"auth.internal", "i"}}},
+		{[]Option{WithExcludePaths("auth.user"), WithInterceptor("auth", drop)}, []KV{{"id", "7"}}},
+	}
+	p := New("a", "-")
+	for i, c := range cases {
+		got, err := p.Encode(v, c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("Case %d: got %v, want %v", i, got, c.want)
+		}
+		if n, _, _ := p.EstimateSize(v, c.opts...); n != len(c.want) {
+			t.Fatalf("Case %d: EstimateSize got %d, want %d", i, n, len(c.want))
+		}
+	}
+	if got := New("a", "-", WithInterceptor("auth.*", drop)).Options().Interceptors; !reflect.DeepEqual(got, []string{"auth.*"}) {
+		t.Fatalf("Got %v, want [auth.*]", got)
+	}
+}
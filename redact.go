@@ -0,0 +1,37 @@
+package formparser
+
+import (
+	"fmt"
+)
+
+// redactMask 脱敏后的值, 不保留原值的长度
+const redactMask = "******"
+
+// ToMapRedacted 同ToMap, 但按字段的sensitive选项脱敏, 得到可以安全写入日志的结果:
+// `zwf:"secret,sensitive"`(或sensitive=mask)的值替换为"******", `zwf:"token,sensitive=drop"`的KV被去掉.
+// sensitive作用于字段的整个值, 如struct、slice字段之下的所有KV; 其余接口不受该选项影响
+func (p *FormParser) ToMapRedacted(v interface{}, opts ...Option) (map[string]string, error) {
+	return p.ToMap(valueOf(v), append(opts[:len(opts):len(opts)], withRedact())...)
+}
+
+// redactKVs 按字段的sensitive选项对其编码结果脱敏
+func redactKVs(kvs []KV, opts tagOptions) ([]KV, error) {
+	mode, ok := opts.Get("sensitive")
+	if !ok {
+		if !opts.Contains("sensitive") {
+			return kvs, nil
+		}
+		mode = "mask"
+	}
+	switch mode {
+	case "mask":
+		rt := make([]KV, len(kvs))
+		for i, kv := range kvs {
+			rt[i] = KV{kv.K, redactMask}
+		}
+		return rt, nil
+	case "drop":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%s: Invalid sensitive %q, mask or drop is needed", pkgName, mode)
+}
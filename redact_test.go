@@ -0,0 +1,50 @@
+package formparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToMapRedacted(t *testing.T) {
+	type Cred struct {
+		AK string `a:"ak"`
+		SK string `a:"sk,sensitive"`
+	}
+	type Demo struct {
+		User  string   `a:"user"`
+		Cred  Cred     `a:"cred"`
+		Token string   `a:"token,sensitive=drop"`
+		Keys  []string `a:"keys,sensitive=mask"`
+		Pass  *Cred    `a:"pass,sensitive"`
+	}
+	v := Demo{User: "u", Cred: Cred{AK: "ak", SK: "sk"}, Token: "t", Keys: []string{"k1", "k2"}, Pass: &Cred{AK: "a"}}
+	p := New("a", "-")
+
+	m, err := p.ToMapRedacted(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"user": "u", "cred.ak": "ak", "cred.sk": redactMask, "keys.0": redactMask, "keys.1": redactMask,
+		"pass.ak": redactMask, "pass.sk": redactMask,
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("Got %v, want %v", m, want)
+	}
+
+	// 其余接口不受sensitive影响
+	m, err = p.ToMap(reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["cred.sk"] != "sk" || m["token"] != "t" {
+		t.Fatalf("Got %v", m)
+	}
+
+	type Bad struct {
+		S string `a:"s,sensitive=hash"`
+	}
+	if _, err := p.ToMapRedacted(Bad{S: "x"}); err == nil {
+		t.Fatal("Expect error for invalid sensitive")
+	}
+}
@@ -0,0 +1,338 @@
+package formparser
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TypeEncoder 将指定类型的值编码为单个字符串, 用于RegisterType
+type TypeEncoder func(v reflect.Value) (string, error)
+
+// RegisterType 为类型t注册编码器, 该类型的值(及指向它的指针)将编码为enc返回的字符串, 优先级高于其它所有规则.
+// 适用于decimal、money一类不希望被按float格式化或按struct展开的类型, 例如shopspring/decimal:
+//
+//	p := formparser.Default()
+//	p.RegisterType(reflect.TypeOf(decimal.Decimal{}), formparser.DecimalEncoder)
+//
+// 需在开始编码前完成注册, 注册过程非并发安全
+func (p *FormParser) RegisterType(t reflect.Type, enc TypeEncoder) {
+	if t == nil || enc == nil {
+		panic(fmt.Sprintf("%s: Missing type or encoder", pkgName))
+	}
+	p.typeEncoders[t] = enc
+}
+
+// RegisterEncoder 注册名为name的编码器, 字段可通过`zwf:"payload,encoder=name"`选用, 对任意类型生效,
+// 以便个别字段不同于该类型的默认表示. 内置了以下编码器:
+//
+//	hex         []byte、string或encoding.BinaryMarshaler的小写十六进制编码
+//	base64      同上, 标准base64编码
+//	gob+base64  encoding/gob序列化后的标准base64编码, 适用于任意gob支持的类型
+//
+// encoder选项作用于字段的整个值, 优先级高于format选项. 需在开始编码前完成注册, 注册过程非并发安全.
+// 内置编码器的结果可由Decode解码回来, 自行注册的编码器仅用于编码
+func (p *FormParser) RegisterEncoder(name string, enc TypeEncoder) {
+	if len(name) <= 0 || enc == nil {
+		panic(fmt.Sprintf("%s: Missing name or encoder", pkgName))
+	}
+	p.namedEncoders[name] = enc
+}
+
+// lookupEncoder 查找字段encoder选项指定的编码器, 未注册该名字时返回错误
+func (p *FormParser) lookupEncoder(opts tagOptions) (enc TypeEncoder, ok bool, err error) {
+	name, has := opts.Get("encoder")
+	if !has {
+		return nil, false, nil
+	}
+	if enc, ok = p.namedEncoders[name]; !ok {
+		return nil, false, fmt.Errorf("%s: Unknown encoder %q", pkgName, name)
+	}
+	return enc, true, nil
+}
+
+// TagOptionHandler 处理字段标签中通过RegisterTagOption注册的选项, 用于编码.
+// v为消除指针及接口后的值, key为其key, arg为形如"name=arg"的选项的值(不带值时为空); ok为false表示不处理该值, 继续按其余规则编码
+type TagOptionHandler func(v reflect.Value, key, arg string) (kvs []KV, ok bool, err error)
+
+// RegisterTagOption 注册名为name的标签选项, 如`zwf:"name,trim"`、`zwf:"card,mask=4"`, 同名时覆盖已有的选项.
+// 选项作用于字段的值及其下slice的元素、map的值, 按标签中的顺序依次尝试, 第一个处理了该值的选项生效;
+// 实现了特定接口或注册了编码器、表示方式的类型不经过这些选项. 内置的"join"即通过它注册, 以英文逗号拼接[]string.
+// 仅用于编码("join"的解码由Decode内置处理), 需在开始编码前完成注册, 注册过程非并发安全
+func (p *FormParser) RegisterTagOption(name string, h TagOptionHandler) {
+	if len(name) <= 0 || h == nil {
+		panic(fmt.Sprintf("%s: Missing name or handler", pkgName))
+	}
+	p.tagHandlers[name] = h
+}
+
+// encodeTagOption 以字段标签中第一个处理了v的注册选项编码v
+func (p *FormParser) encodeTagOption(v reflect.Value, key string, opts tagOptions) (kvs []KV, ok bool, err error) {
+	if !v.IsValid() {
+		return nil, false, nil
+	}
+	s := string(opts)
+	for s != "" {
+		var next string
+		if i := strings.Index(s, ","); i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+		name, arg, _ := strings.Cut(s, "=")
+		if h, has := p.tagHandlers[name]; has {
+			if kvs, ok, err = h(v, key, arg); ok || err != nil {
+				return kvs, true, err
+			}
+		}
+		s = next
+	}
+	return nil, false, nil
+}
+
+// registerTagOptions 注册内置的标签选项
+func (p *FormParser) registerTagOptions() {
+	p.RegisterTagOption("join", func(v reflect.Value, key, _ string) ([]KV, bool, error) {
+		if v.Type() != stringsType {
+			return nil, false, nil
+		}
+		strList := make([]string, v.Len())
+		for i := range strList {
+			strList[i] = v.Index(i).String()
+		}
+		return []KV{{key, strings.Join(strList, ",")}}, true, nil
+	})
+}
+
+// registerNamedEncoders 注册内置的编码器
+func (p *FormParser) registerNamedEncoders() {
+	p.RegisterEncoder("hex", bytesEncoder(hex.EncodeToString))
+	p.RegisterEncoder("base64", bytesEncoder(base64.StdEncoding.EncodeToString))
+	p.RegisterEncoder("gob+base64", func(v reflect.Value) (string, error) {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).EncodeValue(v); err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+	})
+}
+
+// bytesEncoder 将[]byte、string或encoding.BinaryMarshaler的字节按fn编码
+func bytesEncoder(fn func([]byte) string) TypeEncoder {
+	return func(v reflect.Value) (string, error) {
+		if i, ok := implements(v, binaryMarshalerType); ok {
+			b, err := i.(encoding.BinaryMarshaler).MarshalBinary()
+			if err != nil {
+				return "", err
+			}
+			return fn(b), nil
+		}
+		switch {
+		case v.Kind() == reflect.String:
+			return fn([]byte(v.String())), nil
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			return fn(v.Bytes()), nil
+		}
+		return "", fmt.Errorf("%s: Type %v can not be encoded as bytes", pkgName, v.Type())
+	}
+}
+
+// namedDecoders 内置编码器对应的解码器, 使得以encoder选项编码的值可以原样解码回来.
+// RegisterEncoder注册的编码器没有对应的解码器, 解码时按类型处理
+var namedDecoders = map[string]FormatDecoder{
+	"hex":    bytesDecoder(hex.DecodeString),
+	"base64": bytesDecoder(base64.StdEncoding.DecodeString),
+	"gob+base64": func(s string, v reflect.Value) error {
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return err
+		}
+		return gob.NewDecoder(bytes.NewReader(b)).DecodeValue(v)
+	},
+}
+
+// bytesDecoder 与bytesEncoder相对应, 将按fn解码得到的字节写入[]byte、string或encoding.BinaryUnmarshaler
+func bytesDecoder(fn func(string) ([]byte, error)) FormatDecoder {
+	return func(s string, v reflect.Value) error {
+		b, err := fn(s)
+		if err != nil {
+			return err
+		}
+		if i, ok := implements(v, binaryUnmarshalerType); ok {
+			return i.(encoding.BinaryUnmarshaler).UnmarshalBinary(b)
+		}
+		switch {
+		case v.Kind() == reflect.String:
+			v.SetString(string(b))
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			v.SetBytes(b)
+		default:
+			return fmt.Errorf("%s: Type %v can not be decoded from bytes", pkgName, v.Type())
+		}
+		return nil
+	}
+}
+
+// FormatDecoder 将字符串s解码到可寻址的值v中, 与TypeEncoder相对应
+type FormatDecoder func(s string, v reflect.Value) error
+
+// typeFormat 通过RegisterFormat注册的一种表示方式
+type typeFormat struct {
+	enc TypeEncoder
+	dec FormatDecoder
+}
+
+// RegisterFormat 为类型t注册名为name的表示方式, 字段可通过`zwf:"key,format=name"`选用,
+// 从而同一Go类型可以有多种表示, 如time.Time的rfc3339/date, 金额类型的cents/yuan.
+// format选项作用于字段本身以及其slice元素、map值. dec用于解码, 可为nil.
+// 内置了time.Time的rfc3339、rfc3339nano、date、datetime格式, 以及Unix时间戳unix、unixmilli、unixmicro、unixnano
+//
+// 需在开始编码前完成注册, 注册过程非并发安全
+func (p *FormParser) RegisterFormat(t reflect.Type, name string, enc TypeEncoder, dec FormatDecoder) {
+	if t == nil || len(name) <= 0 || enc == nil {
+		panic(fmt.Sprintf("%s: Missing type, name or encoder", pkgName))
+	}
+	if p.formats[t] == nil {
+		p.formats[t] = make(map[string]typeFormat)
+	}
+	p.formats[t][name] = typeFormat{enc: enc, dec: dec}
+}
+
+// lookupFormat 查找字段format选项对v生效的表示方式.
+// v的类型未注册任何表示方式时ok为false(如slice, 选项继续作用于其元素), 已注册但缺少该名字时返回错误
+func (p *FormParser) lookupFormat(v reflect.Value, opts tagOptions) (f typeFormat, ok bool, err error) {
+	name, has := opts.Get("format")
+	if !has {
+		return f, false, nil
+	}
+	formats, registered := p.formats[v.Type()]
+	if !registered {
+		return f, false, nil
+	}
+	if f, ok = formats[name]; !ok {
+		return f, false, fmt.Errorf("%s: Unknown format %q for type %v", pkgName, name, v.Type())
+	}
+	return f, true, nil
+}
+
+// registerTimeFormats 注册time.Time的内置表示方式
+func (p *FormParser) registerTimeFormats() {
+	for name, layout := range map[string]string{
+		"rfc3339":     time.RFC3339,
+		"rfc3339nano": time.RFC3339Nano,
+		"date":        "2006-01-02",
+		"datetime":    "2006-01-02 15:04:05",
+	} {
+		p.RegisterFormat(timeType, name, timeLayoutEncoder(layout), timeLayoutDecoder(layout))
+	}
+	for name, unit := range map[string]time.Duration{
+		"unix":      time.Second,
+		"unixmilli": time.Millisecond,
+		"unixmicro": time.Microsecond,
+		"unixnano":  time.Nanosecond,
+	} {
+		p.RegisterFormat(timeType, name, unixEncoder(unit), unixDecoder(unit))
+	}
+}
+
+func timeLayoutEncoder(layout string) TypeEncoder {
+	return func(v reflect.Value) (string, error) {
+		return v.Interface().(time.Time).Format(layout), nil
+	}
+}
+
+func timeLayoutDecoder(layout string) FormatDecoder {
+	return func(s string, v reflect.Value) error {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+}
+
+// unixEncoder 将time.Time编码为以unit为单位的Unix时间戳, 不足一个unit的部分被舍去
+func unixEncoder(unit time.Duration) TypeEncoder {
+	return func(v reflect.Value) (string, error) {
+		t := v.Interface().(time.Time)
+		switch unit {
+		case time.Second:
+			return strconv.FormatInt(t.Unix(), 10), nil
+		case time.Millisecond:
+			return strconv.FormatInt(t.UnixMilli(), 10), nil
+		case time.Microsecond:
+			return strconv.FormatInt(t.UnixMicro(), 10), nil
+		}
+		return strconv.FormatInt(t.UnixNano(), 10), nil
+	}
+}
+
+// unixDecoder 解码以unit为单位的Unix时间戳, 结果为UTC时间
+func unixDecoder(unit time.Duration) FormatDecoder {
+	return func(s string, v reflect.Value) error {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		var t time.Time
+		switch unit {
+		case time.Second:
+			t = time.Unix(n, 0)
+		case time.Millisecond:
+			t = time.UnixMilli(n)
+		case time.Microsecond:
+			t = time.UnixMicro(n)
+		default:
+			t = time.Unix(0, n)
+		}
+		v.Set(reflect.ValueOf(t.UTC()))
+		return nil
+	}
+}
+
+// StringerEncoder 按fmt.Stringer的结果编码, 可用于RegisterType
+func StringerEncoder(v reflect.Value) (string, error) {
+	i, ok := implements(v, stringerType)
+	if !ok {
+		return "", fmt.Errorf("%s: Type %v does not implement fmt.Stringer", pkgName, v.Type())
+	}
+	return i.(fmt.Stringer).String(), nil
+}
+
+// DecimalEncoder 适用于shopspring/decimal等decimal类型的编码器, 按String()的结果编码,
+// 并要求结果为不带指数的精确十进制数(如"-12.3400"), 以免服务端收到"1e-7"、"NaN"一类无法识别的值
+func DecimalEncoder(v reflect.Value) (string, error) {
+	s, err := StringerEncoder(v)
+	if err != nil {
+		return "", err
+	}
+	if !isDecimalString(s) {
+		return "", fmt.Errorf("%s: %q of type %v is not a plain decimal", pkgName, s, v.Type())
+	}
+	return s, nil
+}
+
+// isDecimalString 判断s是否形如"-123.45"
+func isDecimalString(s string) bool {
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		s = s[1:]
+	}
+	digits, dot := 0, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			digits++
+		case c == '.' && !dot:
+			dot = true
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
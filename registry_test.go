@@ -0,0 +1,238 @@
+package formparser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decimalStub 模拟shopspring/decimal: 内部字段未导出, 通过String()输出精确值
+type decimalStub struct {
+	value int64
+	exp   int32
+}
+
+func (d decimalStub) String() string {
+	s := strconv.FormatInt(d.value, 10)
+	if d.exp >= 0 {
+		return s
+	}
+	n := int(-d.exp)
+	for len(s) <= n {
+		s = "0" + s
+	}
+	return s[:len(s)-n] + "." + s[len(s)-n:]
+}
+
+func TestRegisterType(t *testing.T) {
+	type Order struct {
+		Price  decimalStub   `a:"price"`
+		Fee    *decimalStub  `a:"fee"`
+		Prices []decimalStub `a:"prices"`
+	}
+	v := Order{
+		Price:  decimalStub{12340, -3},
+		Fee:    &decimalStub{5, -2},
+		Prices: []decimalStub{{1, 0}, {-25, -1}},
+	}
+	p := New("a", "-")
+	p.RegisterType(reflect.TypeOf(decimalStub{}), DecimalEncoder)
+	kvs, err := p.parse(reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KV{{"price", "12.340"}, {"fee", "0.05"}, {"prices.0", "1"}, {"prices.1", "-2.5"}}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Unexpected result %v, want %v", kvs, want)
+	}
+}
+
+func TestIsDecimalString(t *testing.T) {
+	for s, want := range map[string]bool{
+		"1": true, "-1.50": true, "+0.1": true, ".5": true,
+		"": false, "-": false, "1e-7": false, "NaN": false, "1.2.3": false,
+	} {
+		if got := isDecimalString(s); got != want {
+			t.Fatalf("isDecimalString(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+type cents int64
+
+func TestRegisterFormat(t *testing.T) {
+	type Demo struct {
+		T     time.Time   `a:"t"`
+		D     time.Time   `a:"d,format=date"`
+		DT    *time.Time  `a:"dt,format=datetime"`
+		Days  []time.Time `a:"days,format=date"`
+		Cents cents       `a:"cents"`
+		Yuan  cents       `a:"yuan,format=yuan"`
+	}
+	tm := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	v := Demo{T: tm, D: tm, DT: &tm, Days: []time.Time{tm, tm.AddDate(0, 0, 1)}, Cents: 12345, Yuan: 12345}
+
+	p := New("a", "-")
+	p.RegisterFormat(reflect.TypeOf(cents(0)), "yuan", func(v reflect.Value) (string, error) {
+		c := v.Int()
+		return fmt.Sprintf("%d.%02d", c/100, c%100), nil
+	}, nil)
+	kvs, err := p.parse(reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KV{
+		{"t", "2020-01-02T03:04:05Z"},
+		{"d", "2020-01-02"},
+		{"dt", "2020-01-02 03:04:05"},
+		{"days.0", "2020-01-02"},
+		{"days.1", "2020-01-03"},
+		{"cents", "12345"},
+		{"yuan", "123.45"},
+	}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Unexpected result %v, want %v", kvs, want)
+	}
+
+	type Bad struct {
+		T time.Time `a:"t,format=unknown"`
+	}
+	if _, err := p.parse(reflect.ValueOf(Bad{})); err == nil {
+		t.Fatal("Expect error for unknown format")
+	}
+}
+
+func TestRegisterEncoder(t *testing.T) {
+	type Demo struct {
+		A []byte         `a:"a,encoder=hex"`
+		B string         `a:"b,encoder=base64"`
+		C map[string]int `a:"c,encoder=gob+base64"`
+		D cents          `a:"d,encoder=plain"`
+		E []byte         `a:"e"`
+	}
+	p := New("a", "-")
+	p.RegisterEncoder("plain", func(v reflect.Value) (string, error) {
+		return strconv.FormatInt(v.Int(), 10), nil
+	})
+	v := Demo{A: []byte("Go"), B: "Go", C: map[string]int{"x": 1}, D: 150, E: []byte("Go")}
+	kvs, err := p.Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v.C); err != nil {
+		t.Fatal(err)
+	}
+	want := []KV{{"a", "476f"}, {"b", "R28="}, {"c", base64.StdEncoding.EncodeToString(buf.Bytes())}, {"d", "150"}, {"e", "R28="}}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Got %v, want %v", kvs, want)
+	}
+
+	type Bad struct {
+		A int `a:"a,encoder=hex"`
+		B int `a:"b,encoder=unknown"`
+	}
+	if _, err := p.Encode(Bad{}); err == nil {
+		t.Fatal("Expect error for unsupported encoder")
+	}
+}
+
+func TestUnixFormats(t *testing.T) {
+	type Demo struct {
+		S  time.Time   `a:"s,format=unix"`
+		Ms time.Time   `a:"ms,format=unixmilli"`
+		Us *time.Time  `a:"us,format=unixmicro"`
+		Ns []time.Time `a:"ns,format=unixnano"`
+	}
+	tm := time.Date(2020, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	p := New("a", "-")
+	kvs, err := p.Encode(Demo{S: tm, Ms: tm, Us: &tm, Ns: []time.Time{tm}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KV{{"s", "1577934245"}, {"ms", "1577934245123"}, {"us", "1577934245123456"}, {"ns.0", "1577934245123456789"}}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("Got %v, want %v", kvs, want)
+	}
+
+	m := make(map[string]string)
+	for _, kv := range kvs {
+		m[kv.K] = kv.V
+	}
+	var got Demo
+	if err := p.DecodeMap(m, &got); err != nil {
+		t.Fatal(err)
+	}
+	us := tm.Truncate(time.Microsecond)
+	if exp := (Demo{S: tm.Truncate(time.Second), Ms: tm.Truncate(time.Millisecond), Us: &us, Ns: []time.Time{tm}}); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("Got %+v, want %+v", got, exp)
+	}
+	if err := p.DecodeMap(map[string]string{"s": "soon"}, &got); err == nil {
+		t.Fatal("Expect error for invalid timestamp")
+	}
+}
+
+func TestRegisterTagOption(t *testing.T) {
+	type Demo struct {
+		Name  string            `a:"name,trim"`
+		Card  *string           `a:"card,mask=4"`
+		Tags  []string          `a:"tags,trim,join"`
+		Names []string          `a:"names,trim"`
+		M     map[string]string `a:"m,trim"`
+		N     int               `a:"n,trim"`
+	}
+	p := New("a", "-")
+	p.RegisterTagOption("trim", func(v reflect.Value, key, _ string) ([]KV, bool, error) {
+		if v.Kind() != reflect.String {
+			return nil, false, nil
+		}
+		return []KV{{key, strings.TrimSpace(v.String())}}, true, nil
+	})
+	p.RegisterTagOption("mask", func(v reflect.Value, key, arg string) ([]KV, bool, error) {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid mask %q", arg)
+		}
+		s := v.String()
+		if len(s) > n {
+			s = strings.Repeat("*", len(s)-n) + s[len(s)-n:]
+		}
+		return []KV{{key, s}}, true, nil
+	})
+	v := Demo{
+		Name:  " x ",
+		Card:  StringPtr("12345678"),
+		Tags:  []string{" a", "b "},
+		Names: []string{" c "},
+		M:     map[string]string{"k": " v "},
+		N:     1,
+	}
+	got, err := p.Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// trim对[]string不生效, 由之后的join处理
+	want := []KV{{"name", "x"}, {"card", "****5678"}, {"tags", " a,b "}, {"names.0", "c"}, {"m.k", "v"}, {"n", "1"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+	if n, _, _ := p.EstimateSize(v); n != len(want) {
+		t.Fatalf("EstimateSize got %d, want %d", n, len(want))
+	}
+	if got := p.Options().TagOptions; !reflect.DeepEqual(got, []string{"join", "mask", "trim"}) {
+		t.Fatalf("Got %v", got)
+	}
+
+	type Bad struct {
+		S string `a:"s,mask=x"`
+	}
+	if _, err := p.Encode(Bad{S: "abc"}); err == nil {
+		t.Fatal("Expect error from handler")
+	}
+}
@@ -0,0 +1,181 @@
+package formparser
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+// Sink 接收编码得到的KV, 用于扩展新的输出格式而无需改动遍历逻辑.
+// 若实现了Flush() error, 则在所有KV输出完后调用
+type Sink interface {
+	Add(key, value string) error
+}
+
+// flusher 需要在输出结束时收尾的Sink
+type flusher interface {
+	Flush() error
+}
+
+// EncodeTo 编码v并依次输出到sink, 重复key的处理由sink决定.
+// 顶层struct的每个字段、顶层slice及map的每个元素编码完成后即输出到sink, 无需先在内存中生成全部KV; 出错时sink可能已收到部分KV
+func (p *FormParser) EncodeTo(v interface{}, sink Sink, opts ...Option) error {
+	p = p.with(append(opts[:len(opts):len(opts)], withSink(sink)))
+	if err := p.stream(valueOf(v)); err != nil {
+		return err
+	}
+	if f, ok := sink.(flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// EncodeToContext 同EncodeTo, 但在遍历过程中定期检查ctx, ctx被取消或超时后立即返回ctx.Err(), 参见EncodeContext
+func (p *FormParser) EncodeToContext(ctx context.Context, v interface{}, sink Sink, opts ...Option) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return p.EncodeTo(v, sink, append(opts[:len(opts):len(opts)], withContext(ctx))...)
+}
+
+// emit 将kvs输出到sink
+func emit(kvs []KV, sink Sink) error {
+	for _, kv := range kvs {
+		if err := sink.Add(kv.K, kv.V); err != nil {
+			return err
+		}
+	}
+	if f, ok := sink.(flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// MapSink 输出到map, 重复的key保留最后一个值
+type MapSink map[string]string
+
+func (s MapSink) Add(key, value string) error {
+	s[key] = value
+	return nil
+}
+
+// ValuesSink 输出到url.Values, 重复的key保留所有值
+type ValuesSink url.Values
+
+func (s ValuesSink) Add(key, value string) error {
+	url.Values(s).Add(key, value)
+	return nil
+}
+
+// MultipartSink 以表单字段的形式输出到multipart.Writer, 不会关闭该Writer
+type MultipartSink struct {
+	W *multipart.Writer
+}
+
+func (s MultipartSink) Add(key, value string) error {
+	return s.W.WriteField(key, value)
+}
+
+// WriterSink 以application/x-www-form-urlencoded格式("a=1&b=2")流式写入io.Writer.
+// 通过NewChunkedWriterSink创建时按块写出, 每写出一块, 若w实现了http.Flusher则随即调用其Flush
+type WriterSink struct {
+	w     io.Writer
+	first bool
+	size  int
+	buf   bytes.Buffer
+
+	// escape 为nil时使用url.QueryEscape
+	escape func(string) string
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w, first: true}
+}
+
+// NewChunkedWriterSink 创建按块写出的WriterSink, 缓存的数据达到size字节时写出一块, size<=0时不缓存
+func NewChunkedWriterSink(w io.Writer, size int) *WriterSink {
+	return &WriterSink{w: w, first: true, size: size}
+}
+
+func (s *WriterSink) Add(key, value string) error {
+	sep := "&"
+	if s.first {
+		sep, s.first = "", false
+	}
+	escape := s.escape
+	if escape == nil {
+		escape = url.QueryEscape
+	}
+	pair := sep + escape(key) + "=" + escape(value)
+	if s.size <= 0 {
+		_, err := io.WriteString(s.w, pair)
+		return err
+	}
+	s.buf.WriteString(pair)
+	if s.buf.Len() < s.size {
+		return nil
+	}
+	return s.writeChunk()
+}
+
+// Flush 写出剩余的数据, 之后s可用于输出下一个body
+func (s *WriterSink) Flush() error {
+	s.first = true
+	if s.buf.Len() == 0 {
+		return nil
+	}
+	return s.writeChunk()
+}
+
+func (s *WriterSink) writeChunk() error {
+	_, err := s.w.Write(s.buf.Bytes())
+	s.buf.Reset()
+	if err != nil {
+		return err
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// Signer 根据按key排序后的全部KV计算签名
+type Signer interface {
+	Sign(kvs []KV) (string, error)
+}
+
+// SignerSink 缓存所有KV, Flush时按key排序后计算签名, 再将原KV与签名一起输出到Next
+type SignerSink struct {
+	Next   Sink
+	Key    string // 签名的key, 如"signature"
+	Signer Signer
+
+	kvs []KV
+}
+
+func (s *SignerSink) Add(key, value string) error {
+	s.kvs = append(s.kvs, KV{key, value})
+	return nil
+}
+
+func (s *SignerSink) Flush() error {
+	sig, err := sign(s.kvs, s.Signer)
+	if err != nil {
+		return err
+	}
+	kvs := append(s.kvs, KV{s.Key, sig})
+	s.kvs = nil
+	return emit(kvs, s.Next)
+}
+
+// sign 将kvs的副本按key排序后交给signer计算签名
+func sign(kvs []KV, signer Signer) (string, error) {
+	sorted := make([]KV, len(kvs))
+	copy(sorted, kvs)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].K < sorted[j].K })
+	return signer.Sign(sorted)
+}
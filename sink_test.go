@@ -0,0 +1,205 @@
+package formparser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type joinSigner struct{}
+
+func (joinSigner) Sign(kvs []KV) (string, error) {
+	var parts []string
+	for _, kv := range kvs {
+		parts = append(parts, kv.K+"="+kv.V)
+	}
+	return strings.Join(parts, ";"), nil
+}
+
+func TestEncodeTo(t *testing.T) {
+	type Demo struct {
+		B string `a:"b"`
+		A []int  `a:"a"`
+	}
+	v := Demo{B: "x y", A: []int{1, 2}}
+	p := New("a", "-")
+
+	m := MapSink{}
+	if err := p.EncodeTo(v, m); err != nil {
+		t.Fatal(err)
+	}
+	if want := (MapSink{"b": "x y", "a.0": "1", "a.1": "2"}); !reflect.DeepEqual(m, want) {
+		t.Fatalf("Got %v, want %v", m, want)
+	}
+
+	values := ValuesSink{}
+	if err := p.EncodeTo(Demo{A: []int{1, 2}}, values, WithRootKey("r")); err != nil {
+		t.Fatal(err)
+	}
+	if want := (ValuesSink{"r.b": {""}, "r.a.0": {"1"}, "r.a.1": {"2"}}); !reflect.DeepEqual(values, want) {
+		t.Fatalf("Got %v, want %v", values, want)
+	}
+
+	var buf bytes.Buffer
+	if err := p.EncodeTo(v, NewWriterSink(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	if want := "b=x+y&a.0=1&a.1=2"; buf.String() != want {
+		t.Fatalf("Got %s, want %s", buf.String(), want)
+	}
+
+	signed := MapSink{}
+	if err := p.EncodeTo(v, &SignerSink{Next: signed, Key: "sig", Signer: joinSigner{}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "a.0=1;a.1=2;b=x y"; signed["sig"] != want || len(signed) != 4 {
+		t.Fatalf("Got %v, want sig %s", signed, want)
+	}
+
+	buf.Reset()
+	w := multipart.NewWriter(&buf)
+	if err := p.EncodeTo(v, MultipartSink{W: w}); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	form, err := multipart.NewReader(&buf, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (url.Values{"b": {"x y"}, "a.0": {"1"}, "a.1": {"2"}}); !reflect.DeepEqual(url.Values(form.Value), want) {
+		t.Fatalf("Got %v, want %v", form.Value, want)
+	}
+}
+
+// chunkRecorder 记录每次Write及Flush的调用
+type chunkRecorder struct {
+	chunks  []string
+	flushes int
+}
+
+func (r *chunkRecorder) Write(b []byte) (int, error) {
+	r.chunks = append(r.chunks, string(b))
+	return len(b), nil
+}
+
+func (r *chunkRecorder) Flush() { r.flushes++ }
+
+func TestChunkedWriterSink(t *testing.T) {
+	type Demo struct {
+		A []int `a:"a"`
+	}
+	v := Demo{A: []int{1, 2, 3, 4, 5}}
+	p := New("a", "-")
+
+	var r chunkRecorder
+	if err := p.EncodeTo(v, NewChunkedWriterSink(&r, 11)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.0=1&a.1=2", "&a.2=3&a.3=4", "&a.4=5"}
+	if !reflect.DeepEqual(r.chunks, want) || r.flushes != len(want) {
+		t.Fatalf("Got %q with %d flushes, want %q", r.chunks, r.flushes, want)
+	}
+
+	var buf bytes.Buffer
+	if err := p.EncodeTo(v, NewChunkedWriterSink(&buf, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Join(want, ""); buf.String() != want {
+		t.Fatalf("Got %s, want %s", buf.String(), want)
+	}
+}
+
+// streamProbe 编码时记录sink已写出的块数
+type streamProbe struct {
+	r    *chunkRecorder
+	seen *int
+}
+
+func TestEncodeToStreams(t *testing.T) {
+	type Demo struct {
+		A string            `a:"a"`
+		B streamProbe       `a:"b"`
+		C map[string]string `a:"c"`
+	}
+	p := New("a", "-")
+	p.RegisterType(reflect.TypeOf(streamProbe{}), func(v reflect.Value) (string, error) {
+		probe := v.Interface().(streamProbe)
+		*probe.seen = len(probe.r.chunks)
+		return "2", nil
+	})
+
+	var r chunkRecorder
+	seen := -1
+	v := Demo{A: "1", B: streamProbe{&r, &seen}, C: map[string]string{"x": "3"}}
+	if err := p.EncodeTo(v, NewChunkedWriterSink(&r, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a=1", "&b=2", "&c.x=3"}; seen != 1 || !reflect.DeepEqual(r.chunks, want) {
+		t.Fatalf("Got %q with %d chunks flushed before b, want %q with 1", r.chunks, seen, want)
+	}
+
+	// 输出结束后sink可以复用, 第二个body不以"&"开头
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+	for i := 0; i < 2; i++ {
+		if err := p.EncodeTo(struct {
+			A []int `a:"a"`
+		}{[]int{1, 2}}, sink); err != nil {
+			t.Fatal(err)
+		}
+		buf.WriteString("\n")
+	}
+	if want := "a.0=1&a.1=2\na.0=1&a.1=2\n"; buf.String() != want {
+		t.Fatalf("Got %q, want %q", buf.String(), want)
+	}
+
+	// 输出的KV同样受WithMaxKVs限制
+	m := MapSink{}
+	if err := p.EncodeTo(v, m, WithMaxKVs(2)); !errors.Is(err, ErrTooManyKVs) {
+		t.Fatalf("Got %v, want ErrTooManyKVs", err)
+	}
+}
+
+func TestEncodeToStreamsRoot(t *testing.T) {
+	p := New("a", "-")
+
+	// 顶层slice、map的元素逐个输出, 超过WithMaxKVs时sink只收到上限以内的KV
+	items := make([]int, 100)
+	for _, v := range []interface{}{items, map[string]int{"a": 1, "b": 2, "c": 3, "d": 4}} {
+		m := MapSink{}
+		if err := p.EncodeTo(v, m, WithMaxKVs(3)); !errors.Is(err, ErrTooManyKVs) {
+			t.Fatalf("Got %v, want ErrTooManyKVs", err)
+		}
+		if len(m) != 3 {
+			t.Fatalf("Got %v, want the first 3 KVs streamed before the limit", m)
+		}
+	}
+
+	// 顶层slice同样定期检查ctx, 取消前编码的元素已输出到sink
+	p.RegisterType(reflect.TypeOf(cancelAfter{}), StringerEncoder)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := 10
+	probes := make([]cancelAfter, 100000)
+	for i := range probes {
+		probes[i] = cancelAfter{&n, cancel}
+	}
+	values := ValuesSink{}
+	if err := p.EncodeToContext(ctx, probes, values); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Got %v, want context.Canceled", err)
+	}
+	if n > 0 || n < -ctxCheckInterval {
+		t.Fatalf("Encoding should stop soon after cancel, %d extra items encoded", -n)
+	}
+	if len(values) == 0 || len(values) > len(probes)/2 {
+		t.Fatalf("Got %d KVs in sink, want only those encoded before cancel", len(values))
+	}
+	if err := p.EncodeToContext(ctx, probes[:1], ValuesSink{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Got %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,255 @@
+package formparser
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SliceStrategy 决定slice、array如何展开为KV, 可通过WithSliceStrategy对整个解析器、RegisterSliceType对某种元素类型、
+// `zwf:"tags,slice=name"`对单个字段(名字由RegisterSliceStrategy注册)选用, 优先级为字段>元素类型>解析器.
+// []byte、带有"runes"选项的[]rune等整体编码为单个值的slice不经过该接口
+type SliceStrategy interface {
+	// EncodeSlice 编码key为key的slice v, 元素通过e编码
+	EncodeSlice(e *SliceEncoder, v reflect.Value, key string) ([]KV, error)
+}
+
+// SliceEncoder 供SliceStrategy编码元素及生成key, 沿用当前解析器的配置及字段的选项
+type SliceEncoder struct {
+	p      *FormParser
+	opts   tagOptions
+	idxFmt indexFormat
+	n      int // 已编码的KV总数
+
+	// 是否将各元素的KV直接输出到EncodeTo的sink, 仅用于根对象且策略原样拼接元素的KV时
+	stream bool
+}
+
+// Encode 以key编码元素v, 元素同样可以是slice、struct、map.
+// 已编码的KV总数超过WithMaxKVs设置的上限时立即返回ErrTooManyKVs
+func (e *SliceEncoder) Encode(v reflect.Value, key string) ([]KV, error) {
+	kvs, err := e.encode(v, key)
+	if err != nil {
+		return nil, err
+	}
+	if e.n += len(kvs); e.p.maxKVs > 0 && e.n > e.p.maxKVs {
+		return nil, fmt.Errorf("%w, limit is %d", ErrTooManyKVs, e.p.maxKVs)
+	}
+	if e.stream {
+		return nil, e.p.send(kvs)
+	}
+	return kvs, nil
+}
+
+// encode 编码元素v但不计入KV总数, 用于将多个元素合并为单个值的策略
+func (e *SliceEncoder) encode(v reflect.Value, key string) ([]KV, error) {
+	kvs, err := e.p.encode(v, key, e.opts)
+	if err != nil {
+		return nil, err
+	}
+	return e.p.appendKVs(nil, kvs) // 逐个元素检查ctx
+}
+
+// IndexKey 返回第i个元素的key, 遵循WithKeyStyle、WithIndexBase及字段的idxfmt、index_pad选项
+func (e *SliceEncoder) IndexKey(key string, i int) string {
+	return e.p.indexKey(key, i, e.idxFmt)
+}
+
+// JoinKey 按WithKeyStyle将child拼接到parent之下
+func (e *SliceEncoder) JoinKey(parent, child string) string {
+	return e.p.joinKey(parent, child)
+}
+
+// RelativeKey 是JoinKey的逆过程, ok为false表示key不在prefix之下
+func (e *SliceEncoder) RelativeKey(prefix, key string) (string, bool) {
+	return e.p.relativeKey(prefix, key)
+}
+
+var (
+	// SliceIndexed 各元素以下标区分, 如"tags.0=a&tags.1=b", 默认策略
+	SliceIndexed SliceStrategy = sliceIndexed{}
+	// SliceRepeated 各元素使用相同的key, 如"tags=a&tags=b", 同"repeat"选项
+	SliceRepeated SliceStrategy = sliceRepeated{}
+	// SliceExploded 将元素的各个字段分别展开为带下标的列, 如"items.name.0=a&items.age.0=1&items.name.1=b", 适用于元素为struct、map的slice
+	SliceExploded SliceStrategy = sliceExploded{}
+)
+
+// SliceJoined 将各元素的值以sep拼接为单个值, 如"tags=a,b", 元素需编码为以slice的key为key的单个KV
+func SliceJoined(sep string) SliceStrategy {
+	return sliceJoined{sep}
+}
+
+type sliceIndexed struct{}
+
+func (sliceIndexed) EncodeSlice(e *SliceEncoder, v reflect.Value, key string) (rt []KV, err error) {
+	for i := 0; i < v.Len(); i++ {
+		kvs, err := e.Encode(v.Index(i), e.IndexKey(key, i))
+		if err != nil {
+			return nil, err
+		}
+		rt = append(rt, kvs...)
+	}
+	return rt, nil
+}
+
+type sliceRepeated struct{}
+
+func (sliceRepeated) EncodeSlice(e *SliceEncoder, v reflect.Value, key string) (rt []KV, err error) {
+	for i := 0; i < v.Len(); i++ {
+		kvs, err := e.Encode(v.Index(i), key)
+		if err != nil {
+			return nil, err
+		}
+		rt = append(rt, kvs...)
+	}
+	return rt, nil
+}
+
+type sliceJoined struct {
+	sep string
+}
+
+func (s sliceJoined) EncodeSlice(e *SliceEncoder, v reflect.Value, key string) ([]KV, error) {
+	values := make([]string, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		kvs, err := e.encode(v.Index(i), key)
+		if err != nil {
+			return nil, err
+		}
+		if len(kvs) != 1 || kvs[0].K != key {
+			return nil, fmt.Errorf("%s: Element %d of key %q can not be joined, a single value is needed", pkgName, i, key)
+		}
+		values = append(values, kvs[0].V)
+	}
+	return []KV{{key, strings.Join(values, s.sep)}}, nil
+}
+
+type sliceExploded struct{}
+
+func (sliceExploded) EncodeSlice(e *SliceEncoder, v reflect.Value, key string) (rt []KV, err error) {
+	for i := 0; i < v.Len(); i++ {
+		kvs, err := e.Encode(v.Index(i), key)
+		if err != nil {
+			return nil, err
+		}
+		for _, kv := range kvs {
+			if rel, ok := e.RelativeKey(key, kv.K); ok {
+				kv.K = e.IndexKey(e.JoinKey(key, rel), i)
+			} else {
+				kv.K = e.IndexKey(kv.K, i)
+			}
+			rt = append(rt, kv)
+		}
+	}
+	return rt, nil
+}
+
+// passThrough s是否原样拼接各元素的KV, 此时元素的KV可以逐个输出而不改变结果
+func passThrough(s SliceStrategy) bool {
+	return s == SliceIndexed || s == SliceRepeated
+}
+
+// WithSliceStrategy 设置slice默认的展开方式, 字段的slice、repeat选项及RegisterSliceType注册的元素类型优先
+func WithSliceStrategy(s SliceStrategy) Option {
+	return func(p *FormParser) {
+		p.slicePreset = s
+	}
+}
+
+// RegisterSliceStrategy 注册名为name的展开方式, 字段可通过`zwf:"tags,slice=name"`选用. 内置了以下名字:
+//
+//	indexed   SliceIndexed
+//	repeated  SliceRepeated
+//	joined    SliceJoined(",")
+//	exploded  SliceExploded
+//
+// 需在开始编码前完成注册, 注册过程非并发安全. Decode支持indexed、repeated及joined, 其余方式仅用于编码
+func (p *FormParser) RegisterSliceStrategy(name string, s SliceStrategy) {
+	if len(name) <= 0 || s == nil {
+		panic(fmt.Sprintf("%s: Missing name or slice strategy", pkgName))
+	}
+	p.sliceStrategies[name] = s
+}
+
+// RegisterSliceType 为元素类型为elem的slice、array指定展开方式, 如所有[]Tag以SliceJoined(";")编码.
+// 需在开始编码前完成注册, 注册过程非并发安全
+func (p *FormParser) RegisterSliceType(elem reflect.Type, s SliceStrategy) {
+	if elem == nil || s == nil {
+		panic(fmt.Sprintf("%s: Missing type or slice strategy", pkgName))
+	}
+	p.sliceTypes[elem] = s
+}
+
+// registerSliceStrategies 注册内置的展开方式
+func (p *FormParser) registerSliceStrategies() {
+	p.RegisterSliceStrategy("indexed", SliceIndexed)
+	p.RegisterSliceStrategy("repeated", SliceRepeated)
+	p.RegisterSliceStrategy("joined", SliceJoined(","))
+	p.RegisterSliceStrategy("exploded", SliceExploded)
+}
+
+// sliceStrategy 选出类型为t的slice的展开方式: 依次为slice选项、style选项、repeat选项、RegisterSliceType、WithSliceStrategy,
+// 最后在设置了WithRepeatedKeys、未设置idxfmt及index_pad且元素(解引用后)不是struct、slice、array、map、interface时为SliceRepeated,
+// 否则为SliceIndexed
+func (p *FormParser) sliceStrategy(t reflect.Type, opts tagOptions) (SliceStrategy, error) {
+	if name, ok := opts.Get("slice"); ok {
+		s, ok := p.sliceStrategies[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: Unknown slice strategy %q", pkgName, name)
+		}
+		return s, nil
+	}
+	if style, explode, err := styleOf(opts); err != nil {
+		return nil, err
+	} else if style == "deepObject" {
+		return nil, fmt.Errorf("%s: Style deepObject is only for struct and map, not %v", pkgName, t)
+	} else if style == "form" {
+		if explode {
+			return SliceRepeated, nil
+		}
+		return SliceJoined(","), nil
+	}
+	if opts.Contains("repeat") {
+		return SliceRepeated, nil
+	}
+	if s, ok := p.sliceTypes[t.Elem()]; ok {
+		return s, nil
+	}
+	if p.slicePreset != nil {
+		return p.slicePreset, nil
+	}
+	if !p.repeatedKeys {
+		return SliceIndexed, nil
+	}
+	if _, ok := opts.Get("idxfmt"); ok {
+		return SliceIndexed, nil
+	}
+	if _, ok := opts.Get("index_pad"); ok {
+		return SliceIndexed, nil
+	}
+	e := t.Elem()
+	for e.Kind() == reflect.Ptr {
+		e = e.Elem()
+	}
+	switch e.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map, reflect.Interface:
+		return SliceIndexed, nil
+	}
+	return SliceRepeated, nil
+}
+
+// repeatSlice slice的元素是否以重复的key编解码, 参见sliceStrategy
+func (p *FormParser) repeatSlice(t reflect.Type, opts tagOptions) bool {
+	s, _ := p.sliceStrategy(t, opts)
+	return s == SliceRepeated
+}
+
+func (p *FormParser) sliceStrategyNames() []string {
+	var names []string
+	for name := range p.sliceStrategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
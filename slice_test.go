@@ -0,0 +1,156 @@
+package formparser
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// reversed 逆序输出各元素, 用于测试自定义的SliceStrategy
+type reversed struct{}
+
+func (reversed) EncodeSlice(e *SliceEncoder, v reflect.Value, key string) (rt []KV, err error) {
+	for i := v.Len() - 1; i >= 0; i-- {
+		kvs, err := e.Encode(v.Index(i), e.IndexKey(key, v.Len()-1-i))
+		if err != nil {
+			return nil, err
+		}
+		rt = append(rt, kvs...)
+	}
+	return rt, nil
+}
+
+func TestSliceStrategy(t *testing.T) {
+	type Item struct {
+		Name string `a:"name"`
+		Age  int    `a:"age"`
+	}
+	type Demo struct {
+		A []string `a:"a"`
+		B []int    `a:"b,slice=joined"`
+		C []Item   `a:"c,slice=exploded"`
+		D []int    `a:"d,slice=indexed"`
+		E []string `a:"e,slice=reversed"`
+	}
+	v := Demo{
+		A: []string{"x", "y"},
+		B: []int{1, 2},
+		C: []Item{{"n1", 1}, {"n2", 2}},
+		D: []int{3},
+		E: []string{"p", "q"},
+	}
+	p := New("a", "-")
+	p.RegisterSliceStrategy("reversed", reversed{})
+	cases := []struct {
+		opts []Option
+		want []KV
+	}{
+		{nil, []KV{
+			{"a.0", "x"}, {"a.1", "y"}, {"b", "1,2"},
+			{"c.name.0", "n1"}, {"c.age.0", "1"}, {"c.name.1", "n2"}, {"c.age.1", "2"},
+			{"d.0", "3"}, {"e.0", "q"}, {"e.1", "p"},
+		}},
+		{[]Option{WithSliceStrategy(SliceRepeated), WithKeyStyle(KeyStyleBracket)}, []KV{
+			{"a", "x"}, {"a", "y"}, {"b", "1,2"},
+			{"c[name][0]", "n1"}, {"c[age][0]", "1"}, {"c[name][1]", "n2"}, {"c[age][1]", "2"},
+			{"d[0]", "3"}, {"e[0]", "q"}, {"e[1]", "p"},
+		}},
+	}
+	for i, c := range cases {
+		got, err := p.Encode(v, c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("Case %d: got %v, want %v", i, got, c.want)
+		}
+		if n, _, _ := p.EstimateSize(v, c.opts...); n != len(c.want) {
+			t.Fatalf("Case %d: EstimateSize got %d, want %d", i, n, len(c.want))
+		}
+	}
+
+	// 按元素类型选用
+	p.RegisterSliceType(reflect.TypeOf(""), SliceJoined(";"))
+	got, err := p.Encode(struct {
+		A []string `a:"a"`
+		B []string `a:"b,repeat"`
+	}{[]string{"x", "y"}, []string{"z"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []KV{{"a", "x;y"}, {"b", "z"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+
+	type Bad struct {
+		A []Item `a:"a,slice=joined"`
+		B []int  `a:"b,slice=unknown"`
+	}
+	if _, err := p.Encode(Bad{A: []Item{{}}}); err == nil {
+		t.Fatal("Expect error for joining structs")
+	}
+	if _, err := p.Encode(Bad{B: []int{1}}); err == nil {
+		t.Fatal("Expect error for unknown strategy")
+	}
+	if got := New("a", "-").Options().SliceStrategies; !reflect.DeepEqual(got, []string{"exploded", "indexed", "joined", "repeated"}) {
+		t.Fatalf("Got %v", got)
+	}
+}
+
+func TestDecodeSliceStrategy(t *testing.T) {
+	type Demo struct {
+		A []int    `a:"a,slice=joined"`
+		B []string `a:"b"`
+	}
+	p := New("a", "-")
+	p.RegisterSliceType(reflect.TypeOf(""), SliceJoined("|"))
+	var d Demo
+	if err := p.Decode(url.Values{"a": {"1,2"}, "b": {"x|y"}}, &d); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Demo{A: []int{1, 2}, B: []string{"x", "y"}}); !reflect.DeepEqual(d, want) {
+		t.Fatalf("Got %+v, want %+v", d, want)
+	}
+}
+
+// countedInt 编码时计数, 用于检查WithMaxKVs能否尽早失败
+type countedInt struct {
+	n *int
+}
+
+func TestSliceStrategyMaxKVs(t *testing.T) {
+	p := New("a", "-", WithMaxKVs(10))
+	p.RegisterType(reflect.TypeOf(countedInt{}), func(v reflect.Value) (string, error) {
+		*v.Interface().(countedInt).n++
+		return "1", nil
+	})
+	p.RegisterSliceStrategy("reversed", reversed{})
+	for _, tag := range []string{"indexed", "repeated", "joined", "exploded", "reversed"} {
+		n := 0
+		items := make([]countedInt, 100000)
+		for i := range items {
+			items[i] = countedInt{&n}
+		}
+		v := reflect.New(reflect.StructOf([]reflect.StructField{{
+			Name: "Items",
+			Type: reflect.TypeOf(items),
+			Tag:  reflect.StructTag(`a:"items,slice=` + tag + `"`),
+		}})).Elem()
+		v.Field(0).Set(reflect.ValueOf(items))
+		_, err := p.Encode(v)
+		if tag == "joined" {
+			// 拼接后只有一个KV, 不受上限影响
+			if err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrTooManyKVs) {
+			t.Fatalf("Strategy %s: expect ErrTooManyKVs, got %v", tag, err)
+		}
+		if n > 11 {
+			t.Fatalf("Strategy %s: %d elements encoded, expect to stop right after the limit", tag, n)
+		}
+	}
+}
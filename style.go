@@ -0,0 +1,83 @@
+package formparser
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Style 一套成体系的参数约定, 通过RegisterStyle以名字注册后, 即可在各处以WithStyle统一选用
+type Style struct {
+	KeyStyle    KeyStyle     // 父子字段key的拼接风格, 同WithKeyStyle
+	IndexBase   int          // slice下标的起始值, 同WithIndexBase
+	BoolLexicon *BoolLexicon // bool的编解码词表, 为nil时按strconv处理, 同WithBoolLexicon
+	TimeLayout  string       // time.Time默认的layout, 为空时为RFC3339Nano, 同WithTimeLayout
+	Options     []Option     // 其余一并生效的选项, 如WithFieldNaming(NamingSnake)
+}
+
+var (
+	stylesMu sync.RWMutex
+	styles   = make(map[string]Style)
+)
+
+// RegisterStyle 以name注册全局的Style, 通常在init中调用. name为空、已被注册或者style无效时panic
+func RegisterStyle(name string, style Style) {
+	if name == "" {
+		panic(fmt.Sprintf("%s: Missing style name", pkgName))
+	}
+	if style.KeyStyle == KeyStyleAuto {
+		panic(fmt.Sprintf("%s: KeyStyleAuto is only for decoding", pkgName))
+	}
+	if style.IndexBase < 0 {
+		panic(fmt.Sprintf("%s: Negative index base %d", pkgName, style.IndexBase))
+	}
+	if style.BoolLexicon != nil {
+		WithBoolLexicon(*style.BoolLexicon) // 提前校验
+		l := *style.BoolLexicon
+		style.BoolLexicon = &l
+	}
+	style.Options = append([]Option(nil), style.Options...)
+
+	stylesMu.Lock()
+	defer stylesMu.Unlock()
+	if _, dup := styles[name]; dup {
+		panic(fmt.Sprintf("%s: Style %q is already registered", pkgName, name))
+	}
+	styles[name] = style
+}
+
+// RegisteredStyles 返回所有已注册的Style的名字, 按名字升序
+func RegisteredStyles() []string {
+	stylesMu.RLock()
+	defer stylesMu.RUnlock()
+	names := make([]string, 0, len(styles))
+	for name := range styles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithStyle 选用通过RegisterStyle注册的Style, 一次性设置其中的全部约定, 之后的选项可以覆盖其中的个别设置.
+// name未注册时panic
+func WithStyle(name string) Option {
+	stylesMu.RLock()
+	style, ok := styles[name]
+	stylesMu.RUnlock()
+	if !ok {
+		panic(fmt.Sprintf("%s: Unknown style %q", pkgName, name))
+	}
+
+	opts := []Option{WithKeyStyle(style.KeyStyle), WithIndexBase(style.IndexBase), WithTimeLayout(style.TimeLayout)}
+	if style.BoolLexicon != nil {
+		opts = append(opts, WithBoolLexicon(*style.BoolLexicon))
+	}
+	opts = append(opts, style.Options...)
+	return func(p *FormParser) {
+		p.style = name
+		p.boolLexicon = nil
+		for _, opt := range opts {
+			opt(p)
+		}
+	}
+}
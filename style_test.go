@@ -0,0 +1,87 @@
+package formparser
+
+import (
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// registerTestHouse 注册测试用的风格, 全局的注册表不允许重复注册, 因此在go test -count=N下也只注册一次
+var registerTestHouse sync.Once
+
+func TestStyle(t *testing.T) {
+	registerTestHouse.Do(func() {
+		RegisterStyle("test-house", Style{
+			KeyStyle:    KeyStyleBracket,
+			IndexBase:   1,
+			BoolLexicon: &BoolOneZero,
+			TimeLayout:  "DateOnly",
+			Options:     []Option{WithFieldNaming(NamingSnake)},
+		})
+	})
+	type Demo struct {
+		UserName string
+		Tags     []string
+		Enabled  bool
+		Since    time.Time
+	}
+	v := Demo{UserName: "x", Tags: []string{"a", "b"}, Enabled: true, Since: time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)}
+	p := New("a", "-", WithStyle("test-house"))
+
+	m, err := p.ToMap(reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"user_name": "x", "tags[1]": "a", "tags[2]": "b", "enabled": "1", "since": "2024-05-06"}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("Got %v, want %v", m, want)
+	}
+	var dst Demo
+	if err := p.DecodeMap(m, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dst, v) {
+		t.Fatalf("Got %+v, want %+v", dst, v)
+	}
+	if cfg := p.Options(); cfg.Style != "test-house" || cfg.IndexBase != 1 {
+		t.Fatalf("Got %+v", cfg)
+	}
+
+	// 之后的选项覆盖Style中的设置
+	m, err = p.ToMap(reflect.ValueOf(v), WithKeyStyle(KeyStyleDotted), WithBoolLexicon(BoolYesNo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["tags.1"] != "a" || m["enabled"] != "yes" {
+		t.Fatalf("Got %v", m)
+	}
+	if err := New("a", "-", WithStyle("test-house")).Decode(url.Values{"enabled": {"true"}}, &dst); err == nil {
+		t.Fatal("Expect error for bool out of the lexicon")
+	}
+
+	found := false
+	for _, name := range RegisteredStyles() {
+		found = found || name == "test-house"
+	}
+	if !found {
+		t.Fatalf("Got %v", RegisteredStyles())
+	}
+
+	for _, f := range []func(){
+		func() { RegisterStyle("test-house", Style{}) },
+		func() { RegisterStyle("", Style{}) },
+		func() { RegisterStyle("test-auto", Style{KeyStyle: KeyStyleAuto}) },
+		func() { WithStyle("test-unknown") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("Expect panic")
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,141 @@
+package formparser
+
+import (
+	"reflect"
+	"strings"
+)
+
+// tagOptions 标签中名字之后以英文逗号分隔的选项, 如`zwf:"l,join,omitempty"`中的"join,omitempty"
+type tagOptions string
+
+// parseTag 将标签拆分为名字和选项两部分
+func parseTag(tag string) (string, tagOptions) {
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[:i], tagOptions(tag[i+1:])
+	}
+	return tag, ""
+}
+
+// Contains 判断选项中是否包含指定的选项name
+func (o tagOptions) Contains(name string) bool {
+	s := string(o)
+	for s != "" {
+		var next string
+		if i := strings.Index(s, ","); i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+		if s == name {
+			return true
+		}
+		s = next
+	}
+	return false
+}
+
+// Get 获取形如"name=value"的选项的值
+func (o tagOptions) Get(name string) (string, bool) {
+	s := string(o)
+	for s != "" {
+		var next string
+		if i := strings.Index(s, ","); i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+		if strings.HasPrefix(s, name) && len(s) > len(name) && s[len(name)] == '=' {
+			return s[len(name)+1:], true
+		}
+		s = next
+	}
+	return "", false
+}
+
+// aliases 返回alias选项指定的别名, 多个别名以"|"分隔, 如"access_key,alias=ak|accessKey"
+func (o tagOptions) aliases() []string {
+	a, ok := o.Get("alias")
+	if !ok || a == "" {
+		return nil
+	}
+	return strings.Split(a, "|")
+}
+
+// decodeAliases 返回解码时依次尝试的别名: alias选项的别名, 之后是只用于解码的accept选项, 如"page_size,accept=pageSize"
+func (o tagOptions) decodeAliases() []string {
+	aliases := o.aliases()
+	if a, ok := o.Get("accept"); ok && a != "" {
+		aliases = append(aliases, strings.Split(a, "|")...)
+	}
+	return aliases
+}
+
+// isEmptyValue 判断是否为omitempty意义上的空值, 在encoding/json的基础上增加了零值的struct:
+// false、0、nil指针、nil接口、长度为0的array、slice、map、string, 以及所有字段均为零值的struct,
+// 从而omitempty可以忽略整个零值的子struct
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Struct:
+		return v.IsZero()
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Complex64, reflect.Complex128:
+		return v.Complex() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// zeroer 自行定义零值的类型, 如time.Time、各类decimal类型
+type zeroer interface {
+	IsZero() bool
+}
+
+var zeroerType = reflect.TypeOf((*zeroer)(nil)).Elem()
+
+// customZero 若v实现了IsZero() bool, 则返回其结果; nil指针、nil接口不会调用该方法
+func customZero(v reflect.Value) (isZero bool, ok bool) {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if i, ok := implements(v, zeroerType); ok {
+		return i.(zeroer).IsZero(), true
+	}
+	return false, false
+}
+
+// isOmitted 根据omitempty/omitzero选项判断字段是否应被忽略.
+// omitzero只忽略真正的零值(reflect.Value.IsZero), 因此非nil的空slice、map会被保留.
+// 类型实现了IsZero() bool时, 两个选项都以该方法的结果为准
+func isOmitted(v reflect.Value, opts tagOptions) bool {
+	omitEmpty, omitZero := opts.Contains("omitempty"), opts.Contains("omitzero")
+	if !omitEmpty && !omitZero {
+		return false
+	}
+	if isZero, ok := customZero(v); ok {
+		return isZero
+	}
+	if omitEmpty && isEmptyValue(v) {
+		return true
+	}
+	if omitZero && v.IsZero() {
+		return true
+	}
+	return false
+}
+
+// inline 判断是否带有"inline"或"flatten"选项, 效果同标签名为"..."
+func (o tagOptions) inline() bool {
+	return o.Contains("inline") || o.Contains("flatten")
+}
+
+// hasTagName 判断标签是否明确设置了名字(或内联选项), 如`zwf:",omitempty"`没有设置
+func hasTagName(tag string) bool {
+	name, opts := parseTag(tag)
+	return name != "" || opts.inline()
+}
@@ -0,0 +1,128 @@
+package formparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTagOptionsGet(t *testing.T) {
+	opts := tagOptions("join,format=date,formats=x,omitempty")
+	if v, ok := opts.Get("format"); !ok || v != "date" {
+		t.Fatalf("Get(format) = %q, %v", v, ok)
+	}
+	if v, ok := opts.Get("formats"); !ok || v != "x" {
+		t.Fatalf("Get(formats) = %q, %v", v, ok)
+	}
+	if _, ok := opts.Get("join"); ok {
+		t.Fatal("join has no value")
+	}
+	if _, ok := opts.Get("form"); ok {
+		t.Fatal("form is not an option")
+	}
+}
+
+func TestParseTag(t *testing.T) {
+	cases := []struct {
+		tag     string
+		name    string
+		opts    tagOptions
+		has     []string
+		hasNone []string
+	}{
+		{"a", "a", "", nil, []string{"join"}},
+		{"a,join", "a", "join", []string{"join"}, []string{"omitempty", ""}},
+		{",omitempty", "", "omitempty", []string{"omitempty"}, []string{"omit"}},
+		{"l,join,omitzero", "l", "join,omitzero", []string{"join", "omitzero"}, []string{"joi"}},
+	}
+	for _, c := range cases {
+		name, opts := parseTag(c.tag)
+		if name != c.name || opts != c.opts {
+			t.Fatalf("parseTag(%q) = %q, %q, want %q, %q", c.tag, name, opts, c.name, c.opts)
+		}
+		for _, o := range c.has {
+			if !opts.Contains(o) {
+				t.Fatalf("%q should contain %q", opts, o)
+			}
+		}
+		for _, o := range c.hasNone {
+			if opts.Contains(o) {
+				t.Fatalf("%q should not contain %q", opts, o)
+			}
+		}
+	}
+}
+
+type zeroStub struct {
+	N int
+}
+
+func (z zeroStub) IsZero() bool { return z.N < 0 }
+
+type ptrZeroStub struct {
+	N int
+}
+
+func (z *ptrZeroStub) IsZero() bool { return z.N == 42 }
+
+func TestIsOmittedWithIsZero(t *testing.T) {
+	type Demo struct {
+		V  zeroStub    `a:"v,omitzero"`
+		PV *zeroStub   `a:"pv,omitempty"`
+		P  ptrZeroStub `a:"p,omitempty"`
+		I  interface{} `a:"i,omitzero"`
+	}
+	cases := []struct {
+		v    Demo
+		want []bool
+	}{
+		{Demo{}, []bool{false, true, false, true}},
+		{Demo{V: zeroStub{-1}, PV: &zeroStub{-1}, P: ptrZeroStub{42}, I: zeroStub{-1}}, []bool{true, true, true, true}},
+		{Demo{V: zeroStub{0}, PV: &zeroStub{0}, P: ptrZeroStub{0}, I: zeroStub{0}}, []bool{false, false, false, false}},
+	}
+	for i, c := range cases {
+		rv := reflect.ValueOf(&c.v).Elem()
+		for j, want := range c.want {
+			_, opts := parseTag(rv.Type().Field(j).Tag.Get("a"))
+			if got := isOmitted(rv.Field(j), opts); got != want {
+				t.Fatalf("Case %d field %s: got %v, want %v", i, rv.Type().Field(j).Name, got, want)
+			}
+		}
+	}
+}
+
+func TestVersionedTags(t *testing.T) {
+	type Demo struct {
+		Name  string `a:"name" a_v2:"Name"`
+		Old   string `a:"old" a_v2:"-"`
+		New   string `a:"-" a_v2:"New,omitempty"`
+		Same  int    `a:"same"`
+		Inner Info   `a:"inner" a_v2:"..."`
+	}
+	v := Demo{Name: "n", Old: "o", New: "x", Same: 1, Inner: Info{CPU: StringPtr("1核")}}
+	p := New("a", "-")
+	cases := []struct {
+		version string
+		want    map[string]string
+	}{
+		{"", map[string]string{"name": "n", "old": "o", "same": "1", "inner.cpu": "1核"}},
+		{"v1", map[string]string{"name": "n", "old": "o", "same": "1", "inner.cpu": "1核"}},
+		{"v2", map[string]string{"Name": "n", "New": "x", "same": "1", "cpu": "1核"}},
+	}
+	for _, c := range cases {
+		m, err := p.ToMap(reflect.ValueOf(v), WithVersion(c.version))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(m, c.want) {
+			t.Fatalf("Version %q: got %v, want %v", c.version, m, c.want)
+		}
+	}
+
+	var dst Demo
+	if err := New("a", "-", WithVersion("v2")).DecodeMap(cases[2].want, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Demo{Name: "n", New: "x", Same: 1, Inner: Info{CPU: StringPtr("1核")}}); !reflect.DeepEqual(dst, want) {
+		t.Fatalf("Got %+v, want %+v", dst, want)
+	}
+}
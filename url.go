@@ -0,0 +1,154 @@
+package formparser
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// BuildURL 将v编码后合并到base的query中并返回完整的URL, 与base中已有参数同名的key会被覆盖, base的query无法解析时返回错误,
+// 编码结果中重复的key(如repeat选项、style=form)保留全部的值, 如"id=3&id=4".
+// 参数由url.Values统一转义并按key排序, 因此不受WithEscapeValues影响.
+// v为struct时带有in=header、in=path等选项的顶层字段不属于query, 不会出现在URL中
+func (p *FormParser) BuildURL(base string, v interface{}, opts ...Option) (string, error) {
+	p = p.with(opts)
+	rv := valueOf(v)
+	if root, _, err := p.rootValue(rv); err == nil && root.Kind() == reflect.Struct {
+		_, query, err := p.partition(rv, "path")
+		if err != nil {
+			return "", err
+		}
+		return p.buildURL(base, query)
+	}
+	kvs, err := p.marshal(rv)
+	if err != nil {
+		return "", err
+	}
+	return p.buildURL(base, kvs)
+}
+
+// BuildURLTemplate 用v中带有in=path选项的顶层字段填充tmpl中的{name}占位符, 未设置in选项的字段作为query,
+// 带有in=header等其它选项的字段被忽略,
+// 例如:
+//
+//	type Req struct {
+//		Region string `zwf:"region,in=path"`
+//		Limit  int    `zwf:"limit"`
+//	}
+//	BuildURLTemplate("https://api.example.com/v1/{region}/instances", Req{"cn", 10})
+//	=> https://api.example.com/v1/cn/instances?limit=10
+//
+// 占位符的值经过url.PathEscape转义, v必须是struct或指向struct的指针
+func (p *FormParser) BuildURLTemplate(tmpl string, v interface{}, opts ...Option) (string, error) {
+	p = p.with(opts)
+	path, query, err := p.partition(valueOf(v), "path")
+	if err != nil {
+		return "", err
+	}
+	values := make(map[string]string, len(path))
+	for _, kv := range path {
+		values[kv.K] = kv.V
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(tmpl, '{')
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(tmpl[i:], '}')
+		if j < 0 {
+			return "", fmt.Errorf("%s: Placeholder in %q is not closed", pkgName, tmpl)
+		}
+		name := tmpl[i+1 : i+j]
+		val, ok := values[name]
+		if !ok {
+			return "", fmt.Errorf("%s: Placeholder {%s} has no matching in=path field", pkgName, name)
+		}
+		b.WriteString(tmpl[:i])
+		b.WriteString(url.PathEscape(val))
+		tmpl = tmpl[i+j+1:]
+	}
+	b.WriteString(tmpl)
+	return p.buildURL(b.String(), query)
+}
+
+// buildURL 将kvs合并到base的query中: kvs中的key替换base中的同名参数, kvs自身重复的key全部保留
+func (p *FormParser) buildURL(base string, kvs []KV) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("%s: Param base is invalid, %w", pkgName, err)
+	}
+	q, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return "", fmt.Errorf("%s: Query of param base is invalid, %w", pkgName, err)
+	}
+	replaced := make(map[string]bool, len(kvs))
+	for _, kv := range kvs {
+		if !replaced[kv.K] {
+			q.Del(kv.K)
+			replaced[kv.K] = true
+		}
+		q.Add(kv.K, kv.V)
+	}
+	u.RawQuery = p.encodeQuery(q)
+	return u.String(), nil
+}
+
+// partition 编码顶层struct, 分别返回带有in=<in>选项的字段和query字段(未设置in选项)的KV,
+// 带有其它in选项(如in=header)的字段两者都不包含, 以免header中的凭据等出现在URL中.
+// in选项只对顶层字段生效, 嵌套struct中的in选项会被忽略
+func (p *FormParser) partition(rv reflect.Value, in string) (matched []KV, rest []KV, err error) {
+	defer p.recoverPanic(&err)
+	rv, tagK, err := p.rootValue(rv)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, nil, errors.New("Param obj is invalid, struct or non-nil pointer to it is needed")
+	}
+	if err := beforeEncode(rv); err != nil {
+		return nil, nil, err
+	}
+	err = p.eachField(rv, tagK, func(field reflect.Value, fieldK string, opts tagOptions) error {
+		where, _ := opts.Get("in")
+		if where != in && where != "" {
+			return nil
+		}
+		kvs, err := p.encode(field, fieldK, opts)
+		if err != nil {
+			return err
+		}
+		if where == in {
+			// 匹配的字段不在WithRootKey之下
+			for _, kv := range kvs {
+				if rel, ok := p.relativeKey(tagK, kv.K); ok {
+					kv.K = rel
+				}
+				matched = append(matched, kv)
+			}
+			return nil
+		}
+		rest, err = p.appendKVs(rest, kvs)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	// AfterEncode追加的参数归入其余字段
+	rest, err = p.relativeTo(tagK, rest, func(kvs []KV) ([]KV, error) {
+		return afterEncode(rv, kvs)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if matched, err = p.finish(matched); err != nil {
+		return nil, nil, err
+	}
+	if rest, err = p.finish(rest); err != nil {
+		return nil, nil, err
+	}
+	return matched, rest, nil
+}
@@ -0,0 +1,124 @@
+package formparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildURL(t *testing.T) {
+	type Demo struct {
+		Q    string   `a:"q"`
+		Page int      `a:"page"`
+		Tags []string `a:"tags,join"`
+	}
+	p := New("a", "-", WithEscapeValues(true))
+	cases := []struct {
+		base string
+		want string
+	}{
+		{"https://api.example.com/v1/search", "https://api.example.com/v1/search?page=2&q=a+b%26c&tags=x%2Cy"},
+		{"https://api.example.com/v1/search?page=1&lang=zh#top", "https://api.example.com/v1/search?lang=zh&page=2&q=a+b%26c&tags=x%2Cy#top"},
+	}
+	for _, c := range cases {
+		got, err := p.BuildURL(c.base, Demo{Q: "a b&c", Page: 2, Tags: []string{"x", "y"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Fatalf("Got %s, want %s", got, c.want)
+		}
+	}
+
+	// 重复的key保留全部的值, 只替换base中的同名参数
+	type Repeated struct {
+		Tags []string `a:"tags,repeat"`
+		IDs  []int    `a:"id"`
+	}
+	for _, opts := range [][]Option{nil, {WithOnConflict(ConflictError)}} {
+		got, err := p.BuildURL("https://x/y?tags=old&keep=1", Repeated{Tags: []string{"a", "b"}, IDs: []int{1, 2}}, append(opts, WithRepeatedKeys(true))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "https://x/y?id=1&id=2&keep=1&tags=a&tags=b"; got != want {
+			t.Fatalf("Got %s, want %s", got, want)
+		}
+	}
+
+	if _, err := p.BuildURL("http://[::1", Demo{}); err == nil {
+		t.Fatal("Expect error for invalid base")
+	}
+	for _, base := range []string{"http://a?x=%zz", "http://a?x=1;y=2"} {
+		if _, err := p.BuildURL(base, Demo{}); err == nil {
+			t.Fatalf("Expect error for invalid query of %s", base)
+		}
+	}
+	if _, err := p.BuildURL("http://a", 1); err == nil {
+		t.Fatal("Expect error for invalid param")
+	}
+}
+
+func TestBuildURLTemplate(t *testing.T) {
+	type Req struct {
+		Region string `a:"region,in=path"`
+		ID     int    `a:"id,in=path"`
+		Limit  int    `a:"limit"`
+		Info   Info   `a:"info"`
+	}
+	p := New("a", "-")
+	req := Req{Region: "cn/north 1", ID: 7, Limit: 10, Info: Info{CPU: StringPtr("2")}}
+	got, err := p.BuildURLTemplate("https://api.example.com/v1/{region}/instances/{id}?v=1", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://api.example.com/v1/cn%2Fnorth%201/instances/7?info.cpu=2&limit=10&v=1"; got != want {
+		t.Fatalf("Got %s, want %s", got, want)
+	}
+
+	for _, tmpl := range []string{"https://a/{zone}", "https://a/{region"} {
+		if _, err := p.BuildURLTemplate(tmpl, req); err == nil {
+			t.Fatalf("Expect error for %s", tmpl)
+		}
+	}
+	type Repeated struct {
+		Region string   `a:"region,in=path"`
+		Tags   []string `a:"tag,repeat"`
+	}
+	if got, err = p.BuildURLTemplate("https://a/{region}", Repeated{"cn", []string{"x", "y"}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://a/cn?tag=x&tag=y"; got != want {
+		t.Fatalf("Got %s, want %s", got, want)
+	}
+	if _, err := p.BuildURLTemplate("https://a", []int{1}); err == nil {
+		t.Fatal("Expect error for non-struct param")
+	}
+}
+
+func TestBuildURLSkipsHeaders(t *testing.T) {
+	type Req struct {
+		Region string `a:"region,in=path"`
+		Token  string `a:"X-Token,in=header"`
+		Limit  int    `a:"limit"`
+	}
+	p := New("a", "-")
+	req := Req{Region: "cn", Token: "secret", Limit: 10}
+	got, err := p.BuildURLTemplate("https://x/{region}", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://x/cn?limit=10"; got != want {
+		t.Fatalf("Got %s, want %s", got, want)
+	}
+	for _, opts := range [][]Option{nil, {WithRootKey("r")}} {
+		got, err := p.BuildURL("https://x/y", &req, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(got, "secret") || strings.Contains(got, "region") || !strings.Contains(got, "limit=10") {
+			t.Fatalf("Got %s, want only query fields", got)
+		}
+	}
+	if h, err := p.ToHeader(req); err != nil || h.Get("X-Token") != "secret" {
+		t.Fatalf("Got %v, %v", h, err)
+	}
+}